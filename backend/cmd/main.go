@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -10,10 +11,15 @@ import (
 	"gsm-motor/internal/handlers/auth"
 	"gsm-motor/internal/handlers/cart"
 	"gsm-motor/internal/handlers/checkout"
+	"gsm-motor/internal/handlers/payments"
 	"gsm-motor/internal/handlers/products"
 	"gsm-motor/internal/handlers/shipping"
+	"gsm-motor/internal/handlers/vehicles"
+	"gsm-motor/internal/jobs"
 	"gsm-motor/internal/middleware"
 	"gsm-motor/internal/models"
+	"gsm-motor/internal/payment"
+	"gsm-motor/internal/search"
 
 	"github.com/gin-gonic/gin"
 )
@@ -32,21 +38,73 @@ func main() {
 	// Auto migrate models
 	if err := database.AutoMigrate(
 		&models.User{},
+		&models.AuthSession{},
+		&models.PasswordResetToken{},
+		&models.EmailChangeRequest{},
 		&models.Category{},
 		&models.Product{},
 		&models.ProductImage{},
+		&models.ProductVariant{},
+		&models.Vehicle{},
+		&models.InventoryMovement{},
+		&models.Supplier{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderItem{},
+		&models.ImportJob{},
+		&models.PriceBatch{},
+		&models.PriceBatchItem{},
+		&models.ProductPriceHistory{},
+		&models.PriceSchedule{},
+		&models.UserVehicle{},
 		&models.Banner{},
 		&models.CartItem{},
 		&models.Order{},
 		&models.OrderItem{},
 		&models.PaymentProof{},
+		&models.OrderStatusHistory{},
+		&models.JobRun{},
+		&models.JobLock{},
+		&models.PaymentCharge{},
+		&models.Voucher{},
+		&models.VoucherRedemption{},
+		&models.SalesDaily{},
+		&models.SalesDailyProduct{},
+		&models.SalesDailyShipping{},
+		&models.SalesDailyCustomer{},
 	); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Cart lines are unique per variant now; the old (user_id, product_id) key blocks that
+	if err := database.DropIndexIfExists(&models.CartItem{}, "cart_items_user_id_product_id_unique"); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	// Charges gained the mismatch and refund_required statuses
+	if err := database.SyncEnumColumn(&models.PaymentCharge{}, "Status"); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	// Purchase orders gained the closed status
+	if err := database.SyncEnumColumn(&models.PurchaseOrder{}, "Status"); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	// Product search (FULLTEXT indexes are created here, not by AutoMigrate)
+	searchEngine := search.NewMySQLEngine(database.DB)
+	if err := searchEngine.Migrate(database.DB); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	search.Setup(searchEngine)
+
 	// Initialize Google OAuth
 	auth.InitGoogleOAuth()
 
+	// Register payment providers
+	payment.Setup()
+
+	// Start background jobs (unpaid order expiry, payment reminders)
+	jobs.StartBackgroundJobs(context.Background())
+
 	// Create uploads directory
 	if err := os.MkdirAll(config.AppConfig.UploadPath, 0755); err != nil {
 		log.Println("Warning: Failed to create uploads directory:", err)
@@ -86,21 +144,30 @@ func main() {
 		api.GET("/categories", products.GetCategories)
 		api.GET("/categories/:slug", products.GetProductsByCategory)
 		api.GET("/banners", getBanners)
+		api.GET("/vehicles", vehicles.ListVehicles)
+		api.GET("/vehicles/brands", vehicles.ListBrands)
 
 		// Shipping (public)
 		api.GET("/shipping/destinations", shipping.SearchDestinations)
 		api.GET("/shipping/options", middleware.OptionalAuthMiddleware(), shipping.GetShippingOptions)
 		api.GET("/shipping/store", shipping.GetStoreInfo)
 
+		// Payments (public); webhooks are authenticated by the provider signature
+		api.GET("/payments/providers", payments.ListProviders)
+		api.POST("/payments/webhook/:provider", payments.Webhook)
+
 		// Auth routes
 		authGroup := api.Group("/auth")
 		{
 			authGroup.POST("/register", middleware.StrictRateLimitMiddleware(), auth.Register)
 			authGroup.POST("/login", middleware.StrictRateLimitMiddleware(), auth.Login)
 			authGroup.POST("/logout", auth.Logout)
+			authGroup.POST("/logout-all", middleware.AuthMiddleware(), auth.LogoutAll)
 			authGroup.POST("/refresh", auth.RefreshToken)
 			authGroup.POST("/verify-otp", middleware.StrictRateLimitMiddleware(), auth.VerifyOTP)
 			authGroup.POST("/resend-otp", middleware.StrictRateLimitMiddleware(), auth.ResendOTP)
+			authGroup.POST("/forgot-password", middleware.StrictRateLimitMiddleware(), auth.ForgotPassword)
+			authGroup.POST("/reset-password", middleware.StrictRateLimitMiddleware(), auth.ResetPassword)
 			authGroup.GET("/google/redirect", auth.GoogleRedirect)
 			authGroup.GET("/google/callback", auth.GoogleCallback)
 			authGroup.GET("/me", middleware.AuthMiddleware(), auth.GetMe)
@@ -127,10 +194,21 @@ func main() {
 			protected.GET("/orders", checkout.GetOrders)
 			protected.GET("/orders/:id", checkout.GetOrder)
 			protected.POST("/orders/:id/payment", checkout.UploadPaymentProof)
+			protected.POST("/orders/:id/cancel", checkout.CancelOrder)
+			protected.POST("/orders/:id/pay", payments.CreatePayment)
 
 			// Profile
 			protected.PATCH("/profile", updateProfile)
 			protected.PATCH("/profile/address", updateAddress)
+			protected.POST("/profile/security-code", middleware.StrictRateLimitMiddleware(), auth.SendSecurityCode)
+			protected.PUT("/profile/password", middleware.StrictRateLimitMiddleware(), auth.ChangePassword)
+			protected.POST("/profile/email", middleware.StrictRateLimitMiddleware(), auth.RequestEmailChange)
+			protected.POST("/profile/email/verify", middleware.StrictRateLimitMiddleware(), auth.VerifyEmailChange)
+			protected.GET("/profile/sessions", auth.ListSessions)
+			protected.DELETE("/profile/sessions/:id", auth.RevokeSession)
+			protected.GET("/profile/vehicles", vehicles.ListMyVehicles)
+			protected.POST("/profile/vehicles", vehicles.AddMyVehicle)
+			protected.DELETE("/profile/vehicles/:id", vehicles.RemoveMyVehicle)
 		}
 
 		// Admin routes
@@ -140,6 +218,20 @@ func main() {
 			// Dashboard
 			adminGroup.GET("/dashboard", admin.AdminDashboard)
 			adminGroup.GET("/subadmin-stats", admin.GetSubadminStats)
+			adminGroup.GET("/reports/profit", admin.GetProfitReport)
+
+			// Sales analytics
+			adminGroup.GET("/analytics/sales", admin.GetSalesAnalytics)
+			adminGroup.GET("/analytics/top-products", admin.GetTopProductsAnalytics)
+			adminGroup.GET("/analytics/top-categories", admin.GetTopCategoriesAnalytics)
+			adminGroup.GET("/analytics/shipping", admin.GetShippingAnalytics)
+			adminGroup.GET("/analytics/customers", admin.GetCustomerAnalytics)
+			adminGroup.POST("/analytics/rebuild", admin.RebuildAnalytics)
+
+			// Exports for bookkeeping (?format=csv|xlsx)
+			adminGroup.GET("/exports/orders", admin.ExportOrders)
+			adminGroup.GET("/exports/products", admin.ExportProducts)
+			adminGroup.GET("/exports/customers", admin.ExportCustomers)
 
 			// Products
 			adminGroup.GET("/products", admin.AdminListProducts)
@@ -147,6 +239,49 @@ func main() {
 			adminGroup.PUT("/products/:id", admin.AdminUpdateProduct)
 			adminGroup.DELETE("/products/:id", admin.AdminDeleteProduct)
 			adminGroup.POST("/products/bulk-price", admin.BulkPriceUpdate)
+			adminGroup.GET("/products/bulk-price/batches", admin.ListPriceBatches)
+			adminGroup.GET("/products/bulk-price/batches/:id", admin.GetPriceBatch)
+			adminGroup.POST("/products/bulk-price/batches/:id/rollback", admin.RollbackPriceBatch)
+			adminGroup.PUT("/products/:id/fitments", admin.UpdateProductFitments)
+
+			// Price history and scheduled price changes or sales
+			adminGroup.GET("/products/:id/price-history", admin.ListPriceHistory)
+			adminGroup.GET("/products/:id/price-schedules", admin.ListPriceSchedules)
+			adminGroup.POST("/products/:id/price-schedules", admin.CreatePriceSchedule)
+			adminGroup.POST("/price-schedules/:id/cancel", admin.CancelPriceSchedule)
+
+			// Product imports (CSV/XLSX, processed in the background)
+			adminGroup.POST("/products/import", admin.StartProductImport)
+			adminGroup.GET("/products/import", admin.ListProductImports)
+			adminGroup.GET("/products/import/:id", admin.GetProductImport)
+			adminGroup.POST("/products/import/:id/apply", admin.ApplyProductImport)
+
+			// Inventory ledger
+			adminGroup.GET("/products/:id/inventory", admin.ListInventoryMovements)
+			adminGroup.POST("/products/:id/inventory", admin.AdjustInventory)
+			adminGroup.GET("/inventory/reconcile", admin.ReconcileInventory)
+			adminGroup.POST("/inventory/reconcile", admin.ResolveInventory)
+
+			// Suppliers and purchase orders
+			adminGroup.GET("/suppliers", admin.ListSuppliers)
+			adminGroup.POST("/suppliers", admin.CreateSupplier)
+			adminGroup.PUT("/suppliers/:id", admin.UpdateSupplier)
+			adminGroup.DELETE("/suppliers/:id", admin.DeleteSupplier)
+			adminGroup.GET("/purchase-orders", admin.ListPurchaseOrders)
+			adminGroup.POST("/purchase-orders", admin.CreatePurchaseOrder)
+			adminGroup.POST("/purchase-orders/from-low-stock", admin.GenerateLowStockPurchaseOrders)
+			adminGroup.GET("/purchase-orders/:id", admin.GetPurchaseOrder)
+			adminGroup.PUT("/purchase-orders/:id", admin.UpdatePurchaseOrder)
+			adminGroup.POST("/purchase-orders/:id/submit", admin.SubmitPurchaseOrder)
+			adminGroup.POST("/purchase-orders/:id/cancel", admin.CancelPurchaseOrder)
+			adminGroup.POST("/purchase-orders/:id/close", admin.ClosePurchaseOrder)
+			adminGroup.POST("/purchase-orders/:id/receive", admin.ReceivePurchaseOrder)
+
+			// Vehicle fitment catalog
+			adminGroup.GET("/vehicles", admin.AdminListVehicles)
+			adminGroup.POST("/vehicles", admin.CreateVehicle)
+			adminGroup.PUT("/vehicles/:id", admin.UpdateVehicle)
+			adminGroup.DELETE("/vehicles/:id", admin.DeleteVehicle)
 
 			// Categories
 			adminGroup.GET("/categories", admin.ListCategories)
@@ -160,10 +295,21 @@ func main() {
 			adminGroup.DELETE("/banners/:id", admin.DeleteBanner)
 			adminGroup.PATCH("/banners/:id/toggle", admin.ToggleBanner)
 
+			// Vouchers
+			adminGroup.GET("/vouchers", admin.ListVouchers)
+			adminGroup.POST("/vouchers", admin.CreateVoucher)
+			adminGroup.PUT("/vouchers/:id", admin.UpdateVoucher)
+			adminGroup.DELETE("/vouchers/:id", admin.DeleteVoucher)
+
+			// Accounts locked after failed logins
+			adminGroup.GET("/users/locked", middleware.SuperAdminMiddleware(), admin.ListLockedUsers)
+			adminGroup.POST("/users/:id/unlock", middleware.SuperAdminMiddleware(), admin.UnlockUser)
+
 			// Orders
 			adminGroup.GET("/orders", admin.AdminListOrders)
 			adminGroup.GET("/orders/:id", admin.AdminGetOrder)
 			adminGroup.PATCH("/orders/:id", admin.AdminUpdateOrderStatus)
+			adminGroup.POST("/orders/:id/cancel", admin.AdminCancelOrder)
 			adminGroup.POST("/orders/:id/verify-payment/:proofId", admin.AdminVerifyPayment)
 			adminGroup.GET("/orders/:id/receipt", admin.GetReceiptData)
 		}
@@ -232,7 +378,12 @@ func updateProfile(c *gin.Context) {
 		user.Phone = &req.Phone
 	}
 
-	database.DB.Save(user)
+	// Only these columns; the user was read by the middleware and saving the whole
+	// row could undo a lockout, password or email change made meanwhile
+	if err := database.DB.Model(user).Select("Name", "Phone").Updates(user).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Gagal memperbarui profil"})
+		return
+	}
 	c.JSON(200, gin.H{"message": "Profil berhasil diperbarui"})
 }
 
@@ -293,6 +444,12 @@ func updateAddress(c *gin.Context) {
 		user.AddressDetail = &req.AddressDetail
 	}
 
-	database.DB.Save(user)
+	if err := database.DB.Model(user).Select(
+		"Province", "ProvinceID", "City", "CityID", "District", "DistrictID",
+		"Subdistrict", "SubdistrictID", "PostalCode", "AddressDetail",
+	).Updates(user).Error; err != nil {
+		c.JSON(500, gin.H{"error": "Gagal memperbarui alamat"})
+		return
+	}
 	c.JSON(200, gin.H{"message": "Alamat berhasil diperbarui"})
 }
@@ -0,0 +1,291 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Range is an inclusive range of days
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+func (r Range) from() string { return r.From.Format("2006-01-02") }
+func (r Range) to() string   { return r.To.Format("2006-01-02") }
+
+// SalesPoint is one bucket of the sales series. Revenue is what customers paid:
+// gross sales minus discounts plus shipping.
+type SalesPoint struct {
+	Period            string  `json:"period"`
+	Start             string  `json:"start"`
+	Orders            int     `json:"orders"`
+	Items             int     `json:"items"`
+	GrossSales        float64 `json:"gross_sales"`
+	Discount          float64 `json:"discount"`
+	Shipping          float64 `json:"shipping"`
+	Revenue           float64 `json:"revenue"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+func (p *SalesPoint) add(d models.SalesDaily) {
+	p.Orders += d.Orders
+	p.Items += d.Items
+	p.GrossSales += d.GrossSales
+	p.Discount += d.Discount
+	p.Shipping += d.Shipping
+}
+
+func (p *SalesPoint) finish() {
+	p.Revenue = p.GrossSales - p.Discount + p.Shipping
+	p.AverageOrderValue = 0
+	if p.Orders > 0 {
+		p.AverageOrderValue = math.Round(p.Revenue/float64(p.Orders)*100) / 100
+	}
+}
+
+// periodKey returns the bucket a day belongs to for the interval (day, week or month)
+func periodKey(day time.Time, interval string) (string, time.Time) {
+	switch interval {
+	case "week":
+		year, week := day.ISOWeek()
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return fmt.Sprintf("%d-W%02d", year, week), start
+	case "month":
+		return day.Format("2006-01"), time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	default:
+		return day.Format("2006-01-02"), day
+	}
+}
+
+// ValidInterval reports whether interval is a supported series bucket
+func ValidInterval(interval string) bool {
+	return interval == "day" || interval == "week" || interval == "month"
+}
+
+// Sales returns the sales series of the range, with empty buckets filled in, and the totals
+func Sales(db *gorm.DB, r Range, interval string) ([]SalesPoint, SalesPoint, error) {
+	var days []models.SalesDaily
+	if err := db.Where("date BETWEEN ? AND ?", r.from(), r.to()).Order("date ASC").Find(&days).Error; err != nil {
+		return nil, SalesPoint{}, err
+	}
+	byDate := make(map[string]models.SalesDaily, len(days))
+	for _, d := range days {
+		byDate[d.Date.Format("2006-01-02")] = d
+	}
+
+	var series []SalesPoint
+	index := make(map[string]int)
+	total := SalesPoint{Period: "total", Start: r.from()}
+	for day := truncateDay(r.From); !day.After(r.To); day = day.AddDate(0, 0, 1) {
+		key, start := periodKey(day, interval)
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, SalesPoint{Period: key, Start: start.Format("2006-01-02")})
+		}
+		if d, ok := byDate[day.Format("2006-01-02")]; ok {
+			series[i].add(d)
+			total.add(d)
+		}
+	}
+	for i := range series {
+		series[i].finish()
+	}
+	total.finish()
+	return series, total, nil
+}
+
+// TopItem is a product or category ranked by units sold or revenue
+type TopItem struct {
+	ID       uint    `json:"id"`
+	Name     string  `json:"name"`
+	Slug     string  `json:"slug"`
+	Quantity int     `json:"quantity"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// topOrder returns the ORDER BY for ranking by "quantity" or "revenue"
+func topOrder(by string) string {
+	if by == "revenue" {
+		return "revenue DESC, quantity DESC"
+	}
+	return "quantity DESC, revenue DESC"
+}
+
+// TopProducts returns the best-selling products of the range
+func TopProducts(db *gorm.DB, r Range, by string, limit int) ([]TopItem, error) {
+	items := []TopItem{}
+	err := db.Table("sales_daily_products s").
+		Select("s.product_id AS id, COALESCE(p.name, '-') AS name, COALESCE(p.slug, '') AS slug, SUM(s.quantity) AS quantity, SUM(s.revenue) AS revenue").
+		Joins("LEFT JOIN products p ON p.id = s.product_id").
+		Where("s.date BETWEEN ? AND ?", r.from(), r.to()).
+		Group("s.product_id, p.name, p.slug").
+		Order(topOrder(by)).
+		Limit(limit).
+		Scan(&items).Error
+	return items, err
+}
+
+// TopCategories returns the best-selling categories of the range
+func TopCategories(db *gorm.DB, r Range, by string, limit int) ([]TopItem, error) {
+	items := []TopItem{}
+	err := db.Table("sales_daily_products s").
+		Select("s.category_id AS id, COALESCE(c.name, '-') AS name, COALESCE(c.slug, '') AS slug, SUM(s.quantity) AS quantity, SUM(s.revenue) AS revenue").
+		Joins("LEFT JOIN categories c ON c.id = s.category_id").
+		Where("s.date BETWEEN ? AND ?", r.from(), r.to()).
+		Group("s.category_id, c.name, c.slug").
+		Order(topOrder(by)).
+		Limit(limit).
+		Scan(&items).Error
+	return items, err
+}
+
+// ShippingRow is the share of orders of one shipping method and courier
+type ShippingRow struct {
+	ShippingMethod string  `json:"shipping_method"`
+	Courier        string  `json:"courier"`
+	Orders         int     `json:"orders"`
+	Revenue        float64 `json:"revenue"`
+	Shipping       float64 `json:"shipping"`
+	SharePercent   float64 `json:"share_percent"` // Of all orders in the range
+}
+
+// ShippingBreakdown returns orders per shipping method and courier
+func ShippingBreakdown(db *gorm.DB, r Range) ([]ShippingRow, error) {
+	rows := []ShippingRow{}
+	if err := db.Model(&models.SalesDailyShipping{}).
+		Select("shipping_method, courier, SUM(orders) AS orders, SUM(revenue) AS revenue, SUM(shipping) AS shipping").
+		Where("date BETWEEN ? AND ?", r.from(), r.to()).
+		Group("shipping_method, courier").
+		Order("orders DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, row := range rows {
+		total += row.Orders
+	}
+	for i := range rows {
+		if total > 0 {
+			rows[i].SharePercent = math.Round(float64(rows[i].Orders)/float64(total)*10000) / 100
+		}
+	}
+	return rows, nil
+}
+
+// CustomerStats describes who bought in a range. New customers placed their first
+// paid order in the range; repeat customers have at least two paid orders up to
+// the end of the range.
+type CustomerStats struct {
+	Customers          int      `json:"customers"`
+	NewCustomers       int      `json:"new_customers"`
+	ReturningCustomers int      `json:"returning_customers"`
+	RepeatCustomers    int      `json:"repeat_customers"`
+	RepeatRate         float64  `json:"repeat_rate"` // Percentage of customers
+	Cohorts            []Cohort `json:"cohorts"`
+}
+
+// Cohort is the customers whose first paid order was in Month, and how many of
+// them ordered again in each following month
+type Cohort struct {
+	Month     string        `json:"month"`
+	Size      int           `json:"size"`
+	Retention []CohortMonth `json:"retention"`
+}
+
+// CohortMonth is the activity of a cohort Offset months after its first order
+type CohortMonth struct {
+	Month     string  `json:"month"`
+	Offset    int     `json:"offset"`
+	Customers int     `json:"customers"`
+	Percent   float64 `json:"percent"`
+}
+
+// Customers returns the repeat-customer figures of the range and the monthly
+// cohorts of the customers acquired in it
+func Customers(db *gorm.DB, r Range) (CustomerStats, error) {
+	var stats CustomerStats
+
+	var counts struct {
+		Customers int
+		New       int
+		Repeat    int
+	}
+	if err := db.Raw(`SELECT COUNT(*) AS customers,
+			COALESCE(SUM(CASE WHEN first_date >= ? THEN 1 ELSE 0 END), 0) AS new,
+			COALESCE(SUM(CASE WHEN total_orders >= 2 THEN 1 ELSE 0 END), 0) AS `+"`repeat`"+`
+		FROM (
+			SELECT user_id, MIN(date) AS first_date, SUM(orders) AS total_orders
+			FROM sales_daily_customers
+			WHERE date <= ?
+			GROUP BY user_id
+			HAVING MAX(date) >= ?
+		) t`, r.from(), r.to(), r.from()).Scan(&counts).Error; err != nil {
+		return stats, err
+	}
+	stats.Customers = counts.Customers
+	stats.NewCustomers = counts.New
+	stats.ReturningCustomers = counts.Customers - counts.New
+	stats.RepeatCustomers = counts.Repeat
+	if counts.Customers > 0 {
+		stats.RepeatRate = math.Round(float64(counts.Repeat)/float64(counts.Customers)*10000) / 100
+	}
+
+	var cells []struct {
+		Cohort    string
+		Month     string
+		Customers int
+	}
+	if err := db.Raw(`SELECT DATE_FORMAT(f.first_date, '%Y-%m') AS cohort, DATE_FORMAT(d.date, '%Y-%m') AS month,
+			COUNT(DISTINCT d.user_id) AS customers
+		FROM sales_daily_customers d
+		JOIN (
+			SELECT user_id, MIN(date) AS first_date FROM sales_daily_customers GROUP BY user_id
+		) f ON f.user_id = d.user_id
+		WHERE f.first_date BETWEEN ? AND ?
+		GROUP BY cohort, month
+		ORDER BY cohort, month`, r.from(), r.to()).Scan(&cells).Error; err != nil {
+		return stats, err
+	}
+
+	stats.Cohorts = []Cohort{}
+	for _, cell := range cells {
+		if len(stats.Cohorts) == 0 || stats.Cohorts[len(stats.Cohorts)-1].Month != cell.Cohort {
+			stats.Cohorts = append(stats.Cohorts, Cohort{Month: cell.Cohort})
+		}
+		cohort := &stats.Cohorts[len(stats.Cohorts)-1]
+		offset := monthsBetween(cell.Cohort, cell.Month)
+		if offset == 0 {
+			cohort.Size = cell.Customers
+		}
+		cohort.Retention = append(cohort.Retention, CohortMonth{Month: cell.Month, Offset: offset, Customers: cell.Customers})
+	}
+	for i := range stats.Cohorts {
+		cohort := &stats.Cohorts[i]
+		for j := range cohort.Retention {
+			if cohort.Size > 0 {
+				cohort.Retention[j].Percent = math.Round(float64(cohort.Retention[j].Customers)/float64(cohort.Size)*10000) / 100
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// monthsBetween returns the number of months from one YYYY-MM month to another
+func monthsBetween(from, to string) int {
+	a, errA := time.Parse("2006-01", from)
+	b, errB := time.Parse("2006-01", to)
+	if errA != nil || errB != nil {
+		return 0
+	}
+	return (b.Year()-a.Year())*12 + int(b.Month()-a.Month())
+}
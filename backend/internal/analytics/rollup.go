@@ -0,0 +1,116 @@
+// Package analytics maintains the daily sales rollups and answers the admin
+// analytics queries from them.
+package analytics
+
+import (
+	"log"
+	"time"
+
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxBackfillDays caps the first rollup run on empty tables; older history is
+// rebuilt on demand with POST /api/admin/analytics/rebuild
+const maxBackfillDays = 90
+
+// paidOrders is the condition for orders counted as sales
+const paidOrders = "o.deleted_at IS NULL AND o.payment_status = 'verified' AND o.status <> 'cancelled'"
+
+// RollupDay recomputes every rollup of one day. It replaces the day's rows, so it
+// is safe to run again when orders of that day change status later.
+func RollupDay(db *gorm.DB, day time.Time) error {
+	from := truncateDay(day)
+	to := from.AddDate(0, 0, 1)
+	date := from.Format("2006-01-02")
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.SalesDaily{}, &models.SalesDailyProduct{}, &models.SalesDailyShipping{}, &models.SalesDailyCustomer{}} {
+			if err := tx.Where("date = ?", date).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		statements := []string{
+			`INSERT INTO sales_daily (date, orders, items, customers, gross_sales, discount, shipping, updated_at)
+			SELECT ?, COUNT(*), COALESCE(SUM((SELECT SUM(oi.quantity) FROM order_items oi WHERE oi.order_id = o.id)), 0),
+				COUNT(DISTINCT o.user_id), COALESCE(SUM(o.total_price), 0), COALESCE(SUM(o.discount_amount), 0),
+				COALESCE(SUM(o.shipping_cost), 0), NOW()
+			FROM orders o
+			WHERE ` + paidOrders + ` AND o.created_at >= ? AND o.created_at < ?
+			HAVING COUNT(*) > 0`,
+
+			`INSERT INTO sales_daily_products (date, product_id, category_id, quantity, revenue)
+			SELECT ?, oi.product_id, COALESCE(MAX(p.category_id), 0), SUM(oi.quantity), SUM(oi.price_at_purchase * oi.quantity)
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+			LEFT JOIN products p ON p.id = oi.product_id
+			WHERE ` + paidOrders + ` AND o.created_at >= ? AND o.created_at < ?
+			GROUP BY oi.product_id`,
+
+			`INSERT INTO sales_daily_shipping (date, shipping_method, courier, orders, revenue, shipping)
+			SELECT ?, o.shipping_method, COALESCE(o.courier, ''), COUNT(*),
+				SUM(o.total_price + o.shipping_cost - o.discount_amount), SUM(o.shipping_cost)
+			FROM orders o
+			WHERE ` + paidOrders + ` AND o.created_at >= ? AND o.created_at < ?
+			GROUP BY o.shipping_method, COALESCE(o.courier, '')`,
+
+			`INSERT INTO sales_daily_customers (date, user_id, orders, revenue)
+			SELECT ?, o.user_id, COUNT(*), SUM(o.total_price + o.shipping_cost - o.discount_amount)
+			FROM orders o
+			WHERE ` + paidOrders + ` AND o.created_at >= ? AND o.created_at < ?
+			GROUP BY o.user_id`,
+		}
+		for _, sql := range statements {
+			if err := tx.Exec(sql, date, from, to).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RollupRange recomputes the rollups of every day from from to to, inclusive
+func RollupRange(db *gorm.DB, from, to time.Time) (int, error) {
+	days := 0
+	for day := truncateDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if err := RollupDay(db, day); err != nil {
+			return days, err
+		}
+		days++
+	}
+	return days, nil
+}
+
+// RollupRecent recomputes the last days days, including today. When no rollup
+// exists yet it backfills from the first order instead, up to maxBackfillDays.
+func RollupRecent(db *gorm.DB, days int) (int, error) {
+	today := truncateDay(time.Now())
+	from := today.AddDate(0, 0, -(days - 1))
+
+	var rolled int64
+	if err := db.Model(&models.SalesDaily{}).Count(&rolled).Error; err != nil {
+		return 0, err
+	}
+	if rolled == 0 {
+		var first *time.Time
+		if err := db.Model(&models.Order{}).Select("MIN(created_at)").Scan(&first).Error; err != nil {
+			return 0, err
+		}
+		if first != nil && first.Before(from) {
+			from = truncateDay(*first)
+			if limit := today.AddDate(0, 0, -(maxBackfillDays - 1)); from.Before(limit) {
+				log.Printf("Sales rollup backfills from %s; rebuild older days with POST /api/admin/analytics/rebuild",
+					limit.Format("2006-01-02"))
+				from = limit
+			}
+		}
+	}
+
+	return RollupRange(db, from, today)
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
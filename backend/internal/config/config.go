@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -23,6 +25,15 @@ type Config struct {
 	JWTSecret        string
 	JWTExpireMinutes int
 	RefreshExpireDays int
+	JWTKeys          map[string]string // Verification keys by kid; tokens without a kid use JWTSecret
+	JWTActiveKeyID   string            // Key that signs new tokens; empty signs with JWTSecret
+	JWTIssuer        string
+	JWTAudience      string
+
+	// Login lockout
+	LoginFreeAttempts   int // Failed password or OTP checks before each further one is delayed
+	LoginMaxAttempts    int // Failed checks that lock the account
+	LoginLockoutMinutes int
 
 	// Google OAuth
 	GoogleClientID     string
@@ -46,16 +57,42 @@ type Config struct {
 	StoreWhatsApp            string
 	StoreName                string
 	StoreAddress             string
+	FrontendURL              string // Storefront base URL, used for links in emails
 
 	// Bank
 	BankName    string
 	BankAccount string
 	BankNumber  string
 
+	// Pricing
+	MinMarginPercent float64 // Bulk price updates may not go below this gross margin
+
+	// Payment gateways
+	PaymentProviders  string
+	MidtransServerKey string
+	MidtransBaseURL   string
+	MidtransVABank    string
+	FakePaymentSecret string
+
 	// Upload
 	UploadPath    string
 	WatermarkPath string
 	MaxImageSize  int64
+	ImportPath    string // Uploaded import spreadsheets; must not be publicly served
+
+	// Background jobs
+	JobsEnabled            bool
+	JobIntervalMinutes     int
+	UnpaidOrderExpireHours int
+	PaymentReminderHours   int
+
+	// Low-stock alerts
+	LowStockAlertEmails          string // Comma-separated; empty sends to the admin notification list
+	LowStockAlertIntervalMinutes int
+
+	// Analytics
+	AnalyticsRollupDays            int // Recent days recomputed by every sales rollup run
+	AnalyticsRollupIntervalMinutes int
 }
 
 var AppConfig *Config
@@ -66,6 +103,53 @@ func LoadConfig() error {
 	jwtExpire, _ := strconv.Atoi(getEnv("JWT_EXPIRE_MINUTES", "60"))
 	refreshExpire, _ := strconv.Atoi(getEnv("REFRESH_EXPIRE_DAYS", "30"))
 	maxImageSize, _ := strconv.ParseInt(getEnv("MAX_IMAGE_SIZE", "10485760"), 10, 64)
+	jobInterval, _ := strconv.Atoi(getEnv("JOB_INTERVAL_MINUTES", "5"))
+	unpaidExpire, _ := strconv.Atoi(getEnv("UNPAID_ORDER_EXPIRE_HOURS", "24"))
+	paymentReminder, _ := strconv.Atoi(getEnv("PAYMENT_REMINDER_HOURS", "12"))
+	minMargin, _ := strconv.ParseFloat(getEnv("MIN_MARGIN_PERCENT", "10"), 64)
+	lowStockInterval, _ := strconv.Atoi(getEnv("LOW_STOCK_ALERT_INTERVAL_MINUTES", "60"))
+	rollupDays, _ := strconv.Atoi(getEnv("ANALYTICS_ROLLUP_DAYS", "14"))
+	rollupInterval, _ := strconv.Atoi(getEnv("ANALYTICS_ROLLUP_INTERVAL_MINUTES", "60"))
+	loginFree, _ := strconv.Atoi(getEnv("LOGIN_FREE_ATTEMPTS", "3"))
+	loginMax, _ := strconv.Atoi(getEnv("LOGIN_MAX_ATTEMPTS", "10"))
+	loginLockout, _ := strconv.Atoi(getEnv("LOGIN_LOCKOUT_MINUTES", "15"))
+
+	jwtKeys, err := parseJWTKeys(getEnv("JWT_KEYS", ""))
+	if err != nil {
+		return err
+	}
+	jwtActiveKeyID := getEnv("JWT_ACTIVE_KID", "")
+	if _, ok := jwtKeys[jwtActiveKeyID]; jwtActiveKeyID != "" && !ok {
+		return fmt.Errorf("JWT_ACTIVE_KID %q is not listed in JWT_KEYS", jwtActiveKeyID)
+	}
+
+	if jobInterval <= 0 {
+		jobInterval = 5
+	}
+	if unpaidExpire <= 0 {
+		unpaidExpire = 24
+	}
+	if paymentReminder <= 0 || paymentReminder >= unpaidExpire {
+		paymentReminder = unpaidExpire / 2
+	}
+	if lowStockInterval <= 0 {
+		lowStockInterval = 60
+	}
+	if rollupDays <= 0 {
+		rollupDays = 14
+	}
+	if rollupInterval <= 0 {
+		rollupInterval = 60
+	}
+	if loginFree < 0 {
+		loginFree = 3
+	}
+	if loginMax <= loginFree {
+		loginMax = loginFree + 7
+	}
+	if loginLockout <= 0 {
+		loginLockout = 15
+	}
 
 	AppConfig = &Config{
 		// Server
@@ -83,6 +167,15 @@ func LoadConfig() error {
 		JWTSecret:         getEnv("JWT_SECRET", "your-super-secret-key-change-in-production"),
 		JWTExpireMinutes:  jwtExpire,
 		RefreshExpireDays: refreshExpire,
+		JWTKeys:           jwtKeys,
+		JWTActiveKeyID:    jwtActiveKeyID,
+		JWTIssuer:         getEnv("JWT_ISSUER", "gsm-motor"),
+		JWTAudience:       getEnv("JWT_AUDIENCE", "gsm-motor-api"),
+
+		// Login lockout
+		LoginFreeAttempts:   loginFree,
+		LoginMaxAttempts:    loginMax,
+		LoginLockoutMinutes: loginLockout,
 
 		// Google OAuth
 		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
@@ -106,21 +199,66 @@ func LoadConfig() error {
 		StoreWhatsApp:            getEnv("STORE_WHATSAPP", "6281386363979"),
 		StoreName:                getEnv("STORE_NAME", "GSM Motor"),
 		StoreAddress:             getEnv("STORE_ADDRESS", ""),
+		FrontendURL:              strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:5173"), "/"),
 
 		// Bank
 		BankName:    getEnv("BANK_NAME", ""),
 		BankAccount: getEnv("BANK_ACCOUNT", ""),
 		BankNumber:  getEnv("BANK_NUMBER", ""),
 
+		// Pricing
+		MinMarginPercent: minMargin,
+
+		// Payment gateways
+		PaymentProviders:  getEnv("PAYMENT_PROVIDERS", "manual"),
+		MidtransServerKey: getEnv("MIDTRANS_SERVER_KEY", ""),
+		MidtransBaseURL:   getEnv("MIDTRANS_BASE_URL", "https://api.sandbox.midtrans.com"),
+		MidtransVABank:    getEnv("MIDTRANS_VA_BANK", "bca"),
+		FakePaymentSecret: getEnv("FAKE_PAYMENT_SECRET", ""),
+
 		// Upload
 		UploadPath:    getEnv("UPLOAD_PATH", "./uploads"),
 		WatermarkPath: getEnv("WATERMARK_PATH", "./assets/watermark.png"),
 		MaxImageSize:  maxImageSize,
+		ImportPath:    getEnv("IMPORT_PATH", "./imports"),
+
+		// Background jobs
+		JobsEnabled:            getEnv("JOBS_ENABLED", "true") == "true",
+		JobIntervalMinutes:     jobInterval,
+		UnpaidOrderExpireHours: unpaidExpire,
+		PaymentReminderHours:   paymentReminder,
+
+		// Low-stock alerts
+		LowStockAlertEmails:          getEnv("LOW_STOCK_ALERT_EMAILS", ""),
+		LowStockAlertIntervalMinutes: lowStockInterval,
+
+		// Analytics
+		AnalyticsRollupDays:            rollupDays,
+		AnalyticsRollupIntervalMinutes: rollupInterval,
 	}
 
 	return nil
 }
 
+// parseJWTKeys reads "kid:secret" pairs separated by commas
+func parseJWTKeys(raw string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for i, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kid, secret, ok := strings.Cut(pair, ":")
+		kid, secret = strings.TrimSpace(kid), strings.TrimSpace(secret)
+		if !ok || kid == "" || secret == "" {
+			// The entry itself may hold a secret, so only its position is reported
+			return nil, fmt.Errorf("JWT_KEYS entry %d must be kid:secret", i+1)
+		}
+		keys[kid] = secret
+	}
+	return keys, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"gsm-motor/internal/config"
 
@@ -53,3 +54,40 @@ func Connect() error {
 func AutoMigrate(models ...interface{}) error {
 	return DB.AutoMigrate(models...)
 }
+
+// DropIndexIfExists removes an index that a model no longer declares;
+// AutoMigrate only adds indexes and never drops them
+func DropIndexIfExists(model interface{}, name string) error {
+	if !DB.Migrator().HasIndex(model, name) {
+		return nil
+	}
+	return DB.Migrator().DropIndex(model, name)
+}
+
+// SyncEnumColumn rewrites an enum column whose values differ from the model's
+// type tag; AutoMigrate only compares the type name and never changes them
+func SyncEnumColumn(model interface{}, field string) error {
+	stmt := &gorm.Statement{DB: DB}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	f := stmt.Schema.LookUpField(field)
+	if f == nil {
+		return fmt.Errorf("unknown field %s", field)
+	}
+
+	columns, err := DB.Migrator().ColumnTypes(model)
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		if column.Name() != f.DBName {
+			continue
+		}
+		if current, ok := column.ColumnType(); ok && strings.EqualFold(current, string(f.DataType)) {
+			return nil
+		}
+		return DB.Migrator().AlterColumn(model, field)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+)
+
+// csvFlushEvery is how many rows are buffered before they are sent to the client
+const csvFlushEvery = 500
+
+// CSVWriter streams rows as CSV. A UTF-8 byte order mark is written first so
+// spreadsheet programs read the file as UTF-8.
+type CSVWriter struct {
+	w    *csv.Writer
+	out  io.Writer
+	rows int
+}
+
+// NewCSV starts a CSV file on w
+func NewCSV(w io.Writer) (*CSVWriter, error) {
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	return &CSVWriter{w: csv.NewWriter(w), out: w}, nil
+}
+
+// WriteRow writes one record
+func (cw *CSVWriter) WriteRow(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, v := range cells {
+		c := normalize(v)
+		record[i] = c.text
+		if !c.numeric {
+			record[i] = escapeFormula(c.text)
+		}
+	}
+	if err := cw.w.Write(record); err != nil {
+		return err
+	}
+
+	cw.rows++
+	if cw.rows%csvFlushEvery == 0 {
+		return cw.flush()
+	}
+	return nil
+}
+
+// Close flushes the remaining rows
+func (cw *CSVWriter) Close() error {
+	return cw.flush()
+}
+
+func (cw *CSVWriter) flush() error {
+	cw.w.Flush()
+	if f, ok := cw.out.(http.Flusher); ok {
+		f.Flush()
+	}
+	return cw.w.Error()
+}
+
+// escapeFormula keeps text that starts like a formula from being evaluated when
+// the file is opened in a spreadsheet
+func escapeFormula(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + s
+	}
+	return s
+}
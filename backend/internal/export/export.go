@@ -0,0 +1,111 @@
+// Package export writes tabular data as CSV or XLSX while it is being produced,
+// so large exports never have to be held in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// ParseFormat returns the format for a query value, defaulting to CSV
+func ParseFormat(value string) (Format, bool) {
+	switch value {
+	case "", "csv":
+		return FormatCSV, true
+	case "xlsx":
+		return FormatXLSX, true
+	}
+	return "", false
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Writer writes rows of cells. Cells may be strings, numbers, booleans, times,
+// pointers to those, or nil for an empty cell.
+type Writer interface {
+	WriteRow(cells ...interface{}) error
+	// Close finishes the file; nothing is complete before it is called
+	Close() error
+}
+
+// NewWriter returns a writer for the format that streams to w
+func NewWriter(w io.Writer, format Format, sheetName string) (Writer, error) {
+	if format == FormatXLSX {
+		return NewXLSX(w, sheetName)
+	}
+	return NewCSV(w)
+}
+
+// cell is a value normalized for writing
+type cell struct {
+	text    string
+	numeric bool
+}
+
+// normalize dereferences pointers and formats a value for a cell
+func normalize(v interface{}) cell {
+	switch x := v.(type) {
+	case nil:
+		return cell{}
+	case string:
+		return cell{text: x}
+	case *string:
+		if x == nil {
+			return cell{}
+		}
+		return cell{text: *x}
+	case int:
+		return cell{text: strconv.Itoa(x), numeric: true}
+	case int64:
+		return cell{text: strconv.FormatInt(x, 10), numeric: true}
+	case uint:
+		return cell{text: strconv.FormatUint(uint64(x), 10), numeric: true}
+	case *uint:
+		if x == nil {
+			return cell{}
+		}
+		return cell{text: strconv.FormatUint(uint64(*x), 10), numeric: true}
+	case float64:
+		return cell{text: strconv.FormatFloat(x, 'f', -1, 64), numeric: true}
+	case *float64:
+		if x == nil {
+			return cell{}
+		}
+		return cell{text: strconv.FormatFloat(*x, 'f', -1, 64), numeric: true}
+	case bool:
+		if x {
+			return cell{text: "ya"}
+		}
+		return cell{text: "tidak"}
+	case time.Time:
+		if x.IsZero() {
+			return cell{}
+		}
+		return cell{text: x.Format("2006-01-02 15:04:05")}
+	case *time.Time:
+		if x == nil || x.IsZero() {
+			return cell{}
+		}
+		return cell{text: x.Format("2006-01-02 15:04:05")}
+	case fmt.Stringer:
+		return cell{text: x.String()}
+	default:
+		return cell{text: fmt.Sprint(x)}
+	}
+}
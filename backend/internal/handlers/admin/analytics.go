@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"gsm-motor/internal/analytics"
+	"gsm-motor/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRebuildDays caps how many days one rebuild request recomputes
+const maxRebuildDays = 366
+
+// analyticsRange reads the from/to query (YYYY-MM-DD, inclusive, default last 30 days)
+func analyticsRange(c *gin.Context) (analytics.Range, bool) {
+	from, to, ok := parseReportRange(c)
+	if !ok || !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal tidak valid (YYYY-MM-DD)"})
+		return analytics.Range{}, false
+	}
+	return analytics.Range{From: from, To: to.AddDate(0, 0, -1)}, true
+}
+
+// GetSalesAnalytics returns the revenue and order series per day, week or month
+func GetSalesAnalytics(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+	interval := c.DefaultQuery("interval", "day")
+	if !analytics.ValidInterval(interval) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Interval tidak valid (day, week, month)"})
+		return
+	}
+
+	series, total, err := analytics.Sales(database.DB, r, interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat analitik"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     series,
+		"total":    total,
+		"interval": interval,
+	})
+}
+
+// GetTopProductsAnalytics returns the best-selling products by quantity or revenue
+func GetTopProductsAnalytics(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+	by, limit := topParams(c)
+
+	items, err := analytics.TopProducts(database.DB, r, by, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat analitik"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items, "by": by})
+}
+
+// GetTopCategoriesAnalytics returns the best-selling categories by quantity or revenue
+func GetTopCategoriesAnalytics(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+	by, limit := topParams(c)
+
+	items, err := analytics.TopCategories(database.DB, r, by, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat analitik"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items, "by": by})
+}
+
+// GetShippingAnalytics returns orders per shipping method and courier
+func GetShippingAnalytics(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+
+	rows, err := analytics.ShippingBreakdown(database.DB, r)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat analitik"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// GetCustomerAnalytics returns new, returning and repeat customers and monthly cohorts
+func GetCustomerAnalytics(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+
+	stats, err := analytics.Customers(database.DB, r)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat analitik"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+// RebuildAnalytics recomputes the daily rollups of a date range, e.g. after
+// orders were corrected outside the recent window the background job refreshes
+func RebuildAnalytics(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+	if r.To.Sub(r.From).Hours()/24 >= maxRebuildDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rentang maksimal " + strconv.Itoa(maxRebuildDays) + " hari"})
+		return
+	}
+
+	days, err := analytics.RollupRange(database.DB, r.From, r.To)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui analitik"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Analitik berhasil diperbarui",
+		"days":    days,
+	})
+}
+
+// topParams reads the ranking (quantity or revenue) and the result limit
+func topParams(c *gin.Context) (string, int) {
+	by := c.DefaultQuery("by", "quantity")
+	if by != "revenue" {
+		by = "quantity"
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	return by, limit
+}
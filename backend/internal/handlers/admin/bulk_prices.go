@@ -0,0 +1,482 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BulkPriceScope limits a bulk price update. Empty fields do not filter, so an
+// empty scope covers every product.
+type BulkPriceScope struct {
+	ProductIDs  []uint   `json:"product_ids,omitempty"`
+	CategoryIDs []uint   `json:"category_ids,omitempty"`
+	SubmittedBy *string  `json:"submitted_by,omitempty"` // Case-sensitive subadmin name
+	MinPrice    *float64 `json:"min_price,omitempty"`
+	MaxPrice    *float64 `json:"max_price,omitempty"`
+}
+
+// validate returns a customer-facing message when the scope is invalid
+func (s *BulkPriceScope) validate() string {
+	if (s.MinPrice != nil && *s.MinPrice < 0) || (s.MaxPrice != nil && *s.MaxPrice < 0) {
+		return "Rentang harga tidak boleh negatif"
+	}
+	if s.MinPrice != nil && s.MaxPrice != nil && *s.MinPrice > *s.MaxPrice {
+		return "Harga minimum tidak boleh lebih besar dari harga maksimum"
+	}
+	return ""
+}
+
+// apply filters a products query by the scope. The price range matches the
+// product's base price, which for products with variants is the cheapest variant.
+func (s *BulkPriceScope) apply(query *gorm.DB) *gorm.DB {
+	if len(s.ProductIDs) > 0 {
+		query = query.Where("products.id IN ?", s.ProductIDs)
+	}
+	if len(s.CategoryIDs) > 0 {
+		query = query.Where("products.category_id IN ?", s.CategoryIDs)
+	}
+	if s.SubmittedBy != nil {
+		query = query.Where("products.submitted_by = ?", *s.SubmittedBy)
+	}
+	if s.MinPrice != nil {
+		query = query.Where("products.price >= ?", *s.MinPrice)
+	}
+	if s.MaxPrice != nil {
+		query = query.Where("products.price <= ?", *s.MaxPrice)
+	}
+	return query
+}
+
+// errMarginViolations aborts a bulk price transaction that breaks the minimum margin
+var errMarginViolations = errors.New("bulk price update violates the minimum margin")
+
+// errInvalidPrices aborts a bulk price transaction that would make a price zero or negative
+var errInvalidPrices = errors.New("bulk price update makes prices zero or negative")
+
+// InvalidPrice is a product or variant whose new price would be zero or
+// negative; a bulk update containing one is refused, even with force
+type InvalidPrice struct {
+	ProductID      uint     `json:"product_id"`
+	VariantID      *uint    `json:"variant_id,omitempty"`
+	Name           string   `json:"name"`
+	NewPrice       float64  `json:"new_price"`
+	NewPrice3Items *float64 `json:"new_price_3_items,omitempty"`
+	NewPrice5Items *float64 `json:"new_price_5_items,omitempty"`
+}
+
+// BulkPriceUpdate changes the prices of the products in scope by a percentage,
+// rounding up to the nearest 500 and keeping tier prices below the base price.
+// With dry_run the old and new prices are returned without saving anything.
+// Otherwise all changes are made in one transaction and saved as a batch that
+// can be rolled back. Changes that would put a product or variant below the
+// minimum margin are refused unless force is set, in which case they are
+// applied and returned as warnings. Changes that make a price zero or negative
+// are always refused.
+func BulkPriceUpdate(c *gin.Context) {
+	var req struct {
+		BulkPriceScope
+		Percentage float64 `json:"percentage" binding:"required,min=-100,max=100"`
+		Force      bool    `json:"force"`
+		DryRun     bool    `json:"dry_run"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Persentase tidak valid (-100 sampai 100)"})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if req.DryRun {
+		var products []models.Product
+		if err := req.apply(database.DB.Model(&models.Product{})).Preload("Variants").Order("products.id ASC").Find(&products).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil produk"})
+			return
+		}
+		items, violations, invalid := planPriceChanges(products, req.Percentage)
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":            true,
+			"count":              len(items),
+			"items":              items,
+			"violations":         violations,
+			"invalid":            invalid,
+			"min_margin_percent": config.AppConfig.MinMarginPercent,
+		})
+		return
+	}
+
+	scope, _ := json.Marshal(req.BulkPriceScope)
+	batch := models.PriceBatch{
+		Percentage: req.Percentage,
+		Scope:      string(scope),
+		Forced:     req.Force,
+	}
+	if admin := middleware.GetCurrentUser(c); admin != nil {
+		batch.CreatedBy = &admin.ID
+	}
+
+	var violations []MarginViolation
+	var invalid []InvalidPrice
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var products []models.Product
+		if err := req.apply(tx.Model(&models.Product{})).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Variants", func(db *gorm.DB) *gorm.DB {
+				return db.Clauses(clause.Locking{Strength: "UPDATE"})
+			}).
+			Order("products.id ASC").
+			Find(&products).Error; err != nil {
+			return err
+		}
+
+		var items []models.PriceBatchItem
+		items, violations, invalid = planPriceChanges(products, req.Percentage)
+		if len(invalid) > 0 {
+			return errInvalidPrices
+		}
+		if len(violations) > 0 && !req.Force {
+			return errMarginViolations
+		}
+
+		batch.ItemCount = len(items)
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].BatchID = batch.ID
+		}
+		if len(items) > 0 {
+			if err := tx.CreateInBatches(items, 200).Error; err != nil {
+				return err
+			}
+		}
+
+		return setBatchPrices(tx, &batch, items, false, batch.CreatedBy)
+	})
+	if errors.Is(err, errInvalidPrices) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Perubahan harga membuat harga menjadi nol atau negatif",
+			"invalid": invalid,
+		})
+		return
+	}
+	if errors.Is(err, errMarginViolations) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":              "Perubahan harga membuat margin di bawah batas minimum",
+			"min_margin_percent": config.AppConfig.MinMarginPercent,
+			"violations":         violations,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui harga"})
+		return
+	}
+
+	response := gin.H{
+		"message": "Harga berhasil diperbarui",
+		"count":   batch.ItemCount,
+		"batch":   batch,
+	}
+	if len(violations) > 0 {
+		response["warnings"] = violations
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// planPriceChanges works out the new prices of the products and their variants.
+// Products with variants are priced per variant. Items whose prices would not
+// change are left out, as are items whose new price would be zero or negative;
+// those are returned as invalid.
+func planPriceChanges(products []models.Product, percentage float64) ([]models.PriceBatchItem, []MarginViolation, []InvalidPrice) {
+	items := []models.PriceBatchItem{}
+	var violations []MarginViolation
+	invalid := []InvalidPrice{}
+
+	add := func(item models.PriceBatchItem, cost *float64) {
+		item.NewPrice, item.NewPrice3Items, item.NewPrice5Items = adjustTierPrices(item.OldPrice, item.OldPrice3Items, item.OldPrice5Items, percentage)
+		if models.PricesEqual(item.OldPrice, item.OldPrice3Items, item.OldPrice5Items, item.NewPrice, item.NewPrice3Items, item.NewPrice5Items) {
+			return
+		}
+		if item.NewPrice <= 0 ||
+			(item.NewPrice3Items != nil && *item.NewPrice3Items < 0) ||
+			(item.NewPrice5Items != nil && *item.NewPrice5Items < 0) {
+			invalid = append(invalid, InvalidPrice{
+				ProductID:      item.ProductID,
+				VariantID:      item.VariantID,
+				Name:           item.Name,
+				NewPrice:       item.NewPrice,
+				NewPrice3Items: item.NewPrice3Items,
+				NewPrice5Items: item.NewPrice5Items,
+			})
+			return
+		}
+		items = append(items, item)
+		if v := checkMargin(item.ProductID, item.VariantID, item.Name, cost, item.NewPrice, item.NewPrice3Items, item.NewPrice5Items); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+
+	for _, p := range products {
+		if len(p.Variants) == 0 {
+			add(models.PriceBatchItem{
+				ProductID:      p.ID,
+				Name:           p.Name,
+				OldPrice:       p.Price,
+				OldPrice3Items: p.Price3Items,
+				OldPrice5Items: p.Price5Items,
+			}, p.CostPrice)
+			continue
+		}
+		for _, variant := range p.Variants {
+			cost := variant.CostPrice
+			if cost == nil {
+				cost = p.CostPrice
+			}
+			variantID := variant.ID
+			add(models.PriceBatchItem{
+				ProductID:      p.ID,
+				VariantID:      &variantID,
+				Name:           p.Name + " - " + variant.Name,
+				OldPrice:       variant.Price,
+				OldPrice3Items: variant.Price3Items,
+				OldPrice5Items: variant.Price5Items,
+			}, cost)
+		}
+	}
+	return items, violations, invalid
+}
+
+// setBatchPrices writes the new prices of a batch, or the old ones when rolling
+// back, records them in the price history and re-syncs the base price of
+// products with variants
+func setBatchPrices(tx *gorm.DB, batch *models.PriceBatch, items []models.PriceBatchItem, rollback bool, changedBy *uint) error {
+	now := time.Now()
+	batchID := batch.ID
+	synced := make(map[uint]bool)
+	for _, item := range items {
+		oldPrice, newPrice := item.OldPrice, item.NewPrice
+		entry := models.ProductPriceHistory{
+			ProductID:      item.ProductID,
+			VariantID:      item.VariantID,
+			OldPrice:       &oldPrice,
+			OldPrice3Items: item.OldPrice3Items,
+			OldPrice5Items: item.OldPrice5Items,
+			NewPrice:       item.NewPrice,
+			NewPrice3Items: item.NewPrice3Items,
+			NewPrice5Items: item.NewPrice5Items,
+			Source:         models.PriceSourceBulk,
+			ReferenceID:    &batchID,
+			ChangedBy:      changedBy,
+		}
+		if rollback {
+			note := "Rollback"
+			entry.OldPrice, entry.OldPrice3Items, entry.OldPrice5Items = &newPrice, item.NewPrice3Items, item.NewPrice5Items
+			entry.NewPrice, entry.NewPrice3Items, entry.NewPrice5Items = item.OldPrice, item.OldPrice3Items, item.OldPrice5Items
+			entry.Note = &note
+		}
+		if err := models.RecordPriceChange(tx, &entry); err != nil {
+			return err
+		}
+
+		price, price3, price5 := entry.NewPrice, entry.NewPrice3Items, entry.NewPrice5Items
+		updates := map[string]interface{}{
+			"price":         price,
+			"price_3_items": price3,
+			"price_5_items": price5,
+		}
+
+		if item.VariantID == nil {
+			updates["last_price_update"] = now
+			if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).Updates(updates).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := tx.Model(&models.ProductVariant{}).Where("id = ?", *item.VariantID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if !synced[item.ProductID] {
+			synced[item.ProductID] = true
+			if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).Update("last_price_update", now).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for productID := range synced {
+		if err := models.SyncProductFromVariants(tx, productID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPriceBatches returns bulk price updates, newest first
+func ListPriceBatches(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+	offset := (page - 1) * perPage
+
+	var total int64
+	database.DB.Model(&models.PriceBatch{}).Count(&total)
+
+	var batches []models.PriceBatch
+	database.DB.Order("id DESC").Offset(offset).Limit(perPage).Find(&batches)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": batches,
+		"meta": gin.H{
+			"current_page": page,
+			"per_page":     perPage,
+			"total":        total,
+			"total_pages":  (total + int64(perPage) - 1) / int64(perPage),
+		},
+	})
+}
+
+// GetPriceBatch returns a bulk price update with its old and new prices
+func GetPriceBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID batch tidak valid"})
+		return
+	}
+
+	var batch models.PriceBatch
+	if err := database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&batch, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": batch})
+}
+
+// RollbackPriceBatch restores the prices a bulk update replaced. Products and
+// variants whose prices were changed again after the batch are left alone and
+// listed as skipped, so later edits are not overwritten.
+func RollbackPriceBatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID batch tidak valid"})
+		return
+	}
+
+	admin := middleware.GetCurrentUser(c)
+	var batch models.PriceBatch
+	var restored []models.PriceBatchItem
+	skipped := []models.PriceBatchItem{}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&batch, id).Error; err != nil {
+			return err
+		}
+		if batch.RolledBackAt != nil {
+			return errPriceBatchRolledBack
+		}
+
+		var items []models.PriceBatchItem
+		if err := tx.Where("batch_id = ?", batch.ID).Order("id ASC").Find(&items).Error; err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			current, err := lockCurrentPrices(tx, item)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				skipped = append(skipped, item)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !models.PricesEqual(current.Price, current.Price3Items, current.Price5Items, item.NewPrice, item.NewPrice3Items, item.NewPrice5Items) {
+				skipped = append(skipped, item)
+				continue
+			}
+			restored = append(restored, item)
+		}
+
+		var adminID *uint
+		if admin != nil {
+			adminID = &admin.ID
+		}
+		if err := setBatchPrices(tx, &batch, restored, true, adminID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		batch.RolledBackAt = &now
+		if admin != nil {
+			batch.RolledBackBy = &admin.ID
+		}
+		return tx.Model(&batch).Updates(map[string]interface{}{
+			"rolled_back_at": batch.RolledBackAt,
+			"rolled_back_by": batch.RolledBackBy,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batch tidak ditemukan"})
+		return
+	}
+	if errors.Is(err, errPriceBatchRolledBack) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Batch sudah dibatalkan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membatalkan perubahan harga"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Perubahan harga berhasil dibatalkan",
+		"data":     batch,
+		"restored": len(restored),
+		"skipped":  skipped,
+	})
+}
+
+// errPriceBatchRolledBack is returned when a batch has already been rolled back
+var errPriceBatchRolledBack = errors.New("price batch already rolled back")
+
+// batchItemPrices holds the current prices of a batch item's product or variant
+type batchItemPrices struct {
+	Price       float64
+	Price3Items *float64
+	Price5Items *float64
+}
+
+// lockCurrentPrices locks and reads the current prices of the item's product or variant
+func lockCurrentPrices(tx *gorm.DB, item models.PriceBatchItem) (batchItemPrices, error) {
+	var current batchItemPrices
+	query := tx.Model(&models.Product{}).Where("id = ?", item.ProductID)
+	if item.VariantID != nil {
+		query = tx.Model(&models.ProductVariant{}).Where("id = ? AND product_id = ?", *item.VariantID, item.ProductID)
+	}
+	err := query.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("price, price_3_items, price_5_items").
+		Take(&current).Error
+	return current, err
+}
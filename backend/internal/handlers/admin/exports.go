@@ -0,0 +1,277 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/export"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is how many records are loaded from the database at a time
+const exportBatchSize = 200
+
+// startExport validates the format query, sends the download headers and
+// returns a writer that streams into the response
+func startExport(c *gin.Context, name string) (export.Writer, bool) {
+	format, ok := export.ParseFormat(c.Query("format"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tidak valid (csv, xlsx)"})
+		return nil, false
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(c.Writer, format, name)
+	if err != nil {
+		log.Printf("Export %s failed to start: %v", name, err)
+		return nil, false
+	}
+	return w, true
+}
+
+// finishExport closes the file. The headers are already sent, so an error can
+// only be logged; the client receives a truncated file.
+func finishExport(name string, w export.Writer, err error) {
+	if err != nil {
+		log.Printf("Export %s failed: %v", name, err)
+	}
+	if closeErr := w.Close(); closeErr != nil {
+		log.Printf("Export %s failed to finish: %v", name, closeErr)
+	}
+}
+
+// ExportOrders streams orders with one row per line item, using the order list filters
+func ExportOrders(c *gin.Context) {
+	query, msg := applyOrderFilters(c, database.DB.Model(&models.Order{}))
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	w, ok := startExport(c, "pesanan")
+	if !ok {
+		return
+	}
+
+	err := w.WriteRow("No. Pesanan", "Tanggal", "Status", "Status Pembayaran", "Metode Pembayaran",
+		"Pelanggan", "Email", "No. HP", "Pengiriman", "Kurir", "Layanan", "No. Resi", "Alamat",
+		"Voucher", "Produk", "Varian", "SKU", "Qty", "Harga Satuan", "Subtotal Item",
+		"Subtotal Pesanan", "Diskon", "Ongkir", "Total")
+	if err != nil {
+		finishExport("orders", w, err)
+		return
+	}
+
+	var batch []models.Order
+	result := query.
+		Preload("User").
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, name")
+		}).
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, order := range batch {
+				if err := writeOrderRows(w, order); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	finishExport("orders", w, result.Error)
+}
+
+func writeOrderRows(w export.Writer, order models.Order) error {
+	var name, email string
+	var phone *string
+	if order.User != nil {
+		name, email, phone = order.User.Name, order.User.Email, order.User.Phone
+	}
+
+	head := []interface{}{order.OrderNumber, order.CreatedAt, order.GetStatusLabel(), string(order.PaymentStatus), order.PaymentProvider,
+		name, email, phone, string(order.ShippingMethod), order.Courier, order.CourierService, order.TrackingNumber, order.ShippingAddress,
+		order.VoucherCode}
+	totals := []interface{}{order.TotalPrice, order.DiscountAmount, order.ShippingCost, order.GetGrandTotal()}
+
+	if len(order.Items) == 0 {
+		row := append(append(head, nil, nil, nil, nil, nil, nil), totals...)
+		return w.WriteRow(row...)
+	}
+
+	for _, item := range order.Items {
+		productName := ""
+		if item.Product != nil {
+			productName = item.Product.Name
+		}
+		row := append([]interface{}{}, head...)
+		row = append(row, productName, item.VariantName, item.SKU, item.Quantity, item.PriceAtPurchase, item.GetSubtotal())
+		row = append(row, totals...)
+		if err := w.WriteRow(row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportProducts streams products with stock and prices, one row per variant for
+// products sold in variants, using the admin product list filters
+func ExportProducts(c *gin.Context) {
+	query, _ := applyProductFilters(c, database.DB.Model(&models.Product{}))
+
+	w, ok := startExport(c, "produk")
+	if !ok {
+		return
+	}
+
+	err := w.WriteRow("ID", "Nama", "Slug", "Kategori", "Supplier", "SKU", "Varian",
+		"Harga", "Harga 3+", "Harga 5+", "Harga Modal", "Stok", "Batas Restock", "Berat (g)", "Dibuat")
+	if err != nil {
+		finishExport("products", w, err)
+		return
+	}
+
+	// Pages keep the list order; FindInBatches would re-sort by id
+	for offset := 0; ; offset += exportBatchSize {
+		var batch []models.Product
+		if err = query.Session(&gorm.Session{}).
+			Preload("Category").
+			Preload("Supplier").
+			Preload("Variants", func(db *gorm.DB) *gorm.DB {
+				return db.Order("id ASC")
+			}).
+			Offset(offset).
+			Limit(exportBatchSize).
+			Find(&batch).Error; err != nil || len(batch) == 0 {
+			break
+		}
+		for _, p := range batch {
+			if err = writeProductRows(w, p); err != nil {
+				break
+			}
+		}
+		if err != nil || len(batch) < exportBatchSize {
+			break
+		}
+	}
+	finishExport("products", w, err)
+}
+
+func writeProductRows(w export.Writer, p models.Product) error {
+	category, supplier := "", ""
+	if p.Category != nil {
+		category = p.Category.Name
+	}
+	if p.Supplier != nil {
+		supplier = p.Supplier.Name
+	}
+
+	if len(p.Variants) == 0 {
+		return w.WriteRow(p.ID, p.Name, p.Slug, category, supplier, nil, nil,
+			p.Price, p.Price3Items, p.Price5Items, p.CostPrice, p.Stock, p.ReorderPoint, p.Weight, p.CreatedAt)
+	}
+	for _, v := range p.Variants {
+		cost := v.CostPrice
+		if cost == nil {
+			cost = p.CostPrice
+		}
+		if err := w.WriteRow(p.ID, p.Name, p.Slug, category, supplier, v.SKU, v.Name,
+			v.Price, v.Price3Items, v.Price5Items, cost, v.Stock, p.ReorderPoint, v.Weight, p.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// customerExportRow is a customer with their paid order totals
+type customerExportRow struct {
+	ID              uint
+	Name            string
+	Email           string
+	Phone           *string
+	Province        *string
+	City            *string
+	AddressDetail   *string
+	EmailVerifiedAt *time.Time
+	CreatedAt       time.Time
+	Orders          int
+	TotalSpent      float64
+	LastOrderAt     *time.Time
+}
+
+// ExportCustomers streams customers with their paid order count and spend.
+// search matches name, email or phone; from/to filter on the registration date.
+func ExportCustomers(c *gin.Context) {
+	query := database.DB.Model(&models.User{}).
+		Select(`users.id, users.name, users.email, users.phone, users.province, users.city, users.address_detail,
+			users.email_verified_at, users.created_at,
+			COUNT(o.id) AS orders, COALESCE(SUM(o.total_price + o.shipping_cost - o.discount_amount), 0) AS total_spent,
+			MAX(o.created_at) AS last_order_at`).
+		Joins("LEFT JOIN orders o ON o.user_id = users.id AND o.deleted_at IS NULL AND o.payment_status = ? AND o.status <> ?",
+			models.PaymentVerified, models.OrderCancelled).
+		Where("users.role = ?", models.RoleCustomer).
+		Group("users.id").
+		Order("users.id ASC")
+
+	if search := c.Query("search"); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("users.name LIKE ? OR users.email LIKE ? OR users.phone LIKE ?", like, like, like)
+	}
+	from, to, ok := parseDateFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal tidak valid (YYYY-MM-DD)"})
+		return
+	}
+	if !from.IsZero() {
+		query = query.Where("users.created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("users.created_at < ?", to)
+	}
+
+	w, ok := startExport(c, "pelanggan")
+	if !ok {
+		return
+	}
+
+	err := w.WriteRow("ID", "Nama", "Email", "No. HP", "Provinsi", "Kota", "Alamat",
+		"Email Terverifikasi", "Terdaftar", "Jumlah Pesanan", "Total Belanja", "Pesanan Terakhir")
+	if err != nil {
+		finishExport("customers", w, err)
+		return
+	}
+
+	// Rows are read one at a time straight from the result set
+	rows, err := query.Rows()
+	if err != nil {
+		finishExport("customers", w, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row customerExportRow
+		if err = database.DB.ScanRows(rows, &row); err != nil {
+			break
+		}
+		if err = w.WriteRow(row.ID, row.Name, row.Email, row.Phone, row.Province, row.City, row.AddressDetail,
+			row.EmailVerifiedAt != nil, row.CreatedAt, row.Orders, row.TotalSpent, row.LastOrderAt); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	finishExport("customers", w, err)
+}
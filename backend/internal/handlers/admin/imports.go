@@ -0,0 +1,245 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/importer"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxImportFileSize   = 20 << 20  // Spreadsheet
+	maxImportImagesSize = 200 << 20 // Images zip
+)
+
+// StartProductImport uploads a product spreadsheet (CSV or XLSX) and queues it.
+// With dry_run=true rows are only validated; the report shows what would change.
+// An optional images zip supplies files referenced by name in the images column.
+func StartProductImport(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File import wajib diunggah"})
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".csv" && ext != ".xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File harus berformat CSV atau XLSX"})
+		return
+	}
+	if file.Size > maxImportFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ukuran file import maksimal 20MB"})
+		return
+	}
+
+	images, _ := c.FormFile("images")
+	if images != nil {
+		if strings.ToLower(filepath.Ext(images.Filename)) != ".zip" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File gambar harus berformat ZIP"})
+			return
+		}
+		if images.Size > maxImportImagesSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ukuran file ZIP gambar maksimal 200MB"})
+			return
+		}
+	}
+
+	// Imports are kept outside the public uploads directory
+	dir := filepath.Join(config.AppConfig.ImportPath, uuid.New().String())
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		log.Printf("Failed to create import directory: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan file import"})
+		return
+	}
+
+	job := models.ImportJob{
+		Status:   models.ImportQueued,
+		DryRun:   c.PostForm("dry_run") == "true",
+		Filename: filepath.Base(file.Filename),
+		FilePath: filepath.Join(dir, "products"+ext),
+	}
+	if err := c.SaveUploadedFile(file, job.FilePath); err != nil {
+		log.Printf("Failed to save import file: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan file import"})
+		return
+	}
+	if images != nil {
+		imagesPath := filepath.Join(dir, "images.zip")
+		if err := c.SaveUploadedFile(images, imagesPath); err != nil {
+			log.Printf("Failed to save import images: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan file gambar"})
+			return
+		}
+		job.ImagesPath = &imagesPath
+	}
+	if admin := middleware.GetCurrentUser(c); admin != nil {
+		job.CreatedBy = &admin.ID
+	}
+
+	if err := database.DB.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat import"})
+		return
+	}
+	startImport(job.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Import sedang diproses", "data": job})
+}
+
+// startImport runs the import in the background. Jobs that never start here
+// are picked up by the product_imports scheduler job.
+func startImport(id uint) {
+	go func() {
+		if err := importer.Run(context.Background(), id); err != nil {
+			log.Printf("Import %d failed: %v", id, err)
+		}
+	}()
+}
+
+// ListProductImports lists imports, newest first
+func ListProductImports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+	offset := (page - 1) * perPage
+
+	query := database.DB.Model(&models.ImportJob{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var jobs []models.ImportJob
+	query.Omit("report").Order("id DESC").Offset(offset).Limit(perPage).Find(&jobs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": jobs,
+		"meta": gin.H{
+			"current_page": page,
+			"per_page":     perPage,
+			"total":        total,
+			"total_pages":  (total + int64(perPage) - 1) / int64(perPage),
+		},
+	})
+}
+
+// GetProductImport returns an import's progress and, once finished, its row
+// report. errors_only=true leaves out the rows that went through.
+func GetProductImport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID import tidak valid"})
+		return
+	}
+
+	var job models.ImportJob
+	if err := database.DB.First(&job, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import tidak ditemukan"})
+		return
+	}
+
+	rows := []importer.RowResult{}
+	if job.Report != nil {
+		if err := json.Unmarshal([]byte(*job.Report), &rows); err != nil {
+			log.Printf("Import %d has an unreadable report: %v", job.ID, err)
+		}
+	}
+	if c.Query("errors_only") == "true" {
+		failed := rows[:0]
+		for _, row := range rows {
+			if !row.OK() {
+				failed = append(failed, row)
+			}
+		}
+		rows = failed
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job, "rows": rows})
+}
+
+var (
+	errImportNotFound       = errors.New("import not found")
+	errImportNotApplicable  = errors.New("import is not a completed dry run")
+	errImportAlreadyApplied = errors.New("dry run was already applied")
+)
+
+// ApplyProductImport runs a finished dry run for real, reusing its uploaded files.
+// A dry run can be applied only once.
+func ApplyProductImport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID import tidak valid"})
+		return
+	}
+
+	job := models.ImportJob{Status: models.ImportQueued}
+	if admin := middleware.GetCurrentUser(c); admin != nil {
+		job.CreatedBy = &admin.ID
+	}
+
+	var dryRun models.ImportJob
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so two clicks cannot both start an import
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&dryRun, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errImportNotFound
+			}
+			return err
+		}
+		if !dryRun.DryRun || dryRun.Status != models.ImportCompleted {
+			return errImportNotApplicable
+		}
+		if dryRun.AppliedJobID != nil {
+			return errImportAlreadyApplied
+		}
+
+		job.Filename = dryRun.Filename
+		job.FilePath = dryRun.FilePath
+		job.ImagesPath = dryRun.ImagesPath
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		return tx.Model(&dryRun).UpdateColumn("applied_job_id", job.ID).Error
+	})
+	switch {
+	case errors.Is(err, errImportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import tidak ditemukan"})
+		return
+	case errors.Is(err, errImportNotApplicable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Hanya simulasi import yang sudah selesai yang dapat dijalankan"})
+		return
+	case errors.Is(err, errImportAlreadyApplied):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          "Simulasi import ini sudah dijalankan",
+			"applied_job_id": dryRun.AppliedJobID,
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat import"})
+		return
+	}
+	startImport(job.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Import sedang diproses", "data": job})
+}
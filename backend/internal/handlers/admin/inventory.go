@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/inventory"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListInventoryMovements returns the stock ledger of a product, newest first
+func ListInventoryMovements(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.Unscoped().Preload("Variants").First(&product, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Produk tidak ditemukan"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 200 {
+		perPage = 50
+	}
+	offset := (page - 1) * perPage
+
+	query := database.DB.Model(&models.InventoryMovement{}).Where("product_id = ?", product.ID)
+	if variantID, _ := strconv.ParseUint(c.Query("variant_id"), 10, 32); variantID > 0 {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	if movementType := c.Query("type"); movementType != "" {
+		query = query.Where("type = ?", movementType)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var movements []models.InventoryMovement
+	query.
+		Preload("Variant", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, sku, name")
+		}).
+		Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(perPage).
+		Find(&movements)
+
+	c.JSON(http.StatusOK, gin.H{
+		"product": gin.H{
+			"id":       product.ID,
+			"name":     product.Name,
+			"stock":    product.Stock,
+			"variants": product.Variants,
+		},
+		"data": movements,
+		"meta": gin.H{
+			"current_page": page,
+			"per_page":     perPage,
+			"total":        total,
+			"total_pages":  (total + int64(perPage) - 1) / int64(perPage),
+		},
+	})
+}
+
+// AdjustInventoryRequest represents a manual stock movement; exactly one of
+// Delta (relative) or Stock (absolute count) must be given
+type AdjustInventoryRequest struct {
+	VariantID *uint  `json:"variant_id"`
+	Type      string `json:"type" binding:"required,oneof=adjustment return"`
+	Delta     *int   `json:"delta"`
+	Stock     *int   `json:"stock"`
+	Note      string `json:"note" binding:"required,min=3"`
+}
+
+// AdjustInventory records a manual adjustment or a customer return
+func AdjustInventory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var req AdjustInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Jenis dan catatan penyesuaian wajib diisi"})
+		return
+	}
+	if (req.Delta == nil) == (req.Stock == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Isi salah satu: selisih atau jumlah stok"})
+		return
+	}
+	if req.Type == string(models.MovementReturn) && (req.Delta == nil || *req.Delta <= 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retur harus menambah stok"})
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Produk tidak ditemukan"})
+		return
+	}
+
+	var variantCount int64
+	database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+	if variantCount > 0 {
+		if req.VariantID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Pilih varian yang disesuaikan"})
+			return
+		}
+		var variant models.ProductVariant
+		if err := database.DB.Where("id = ? AND product_id = ?", *req.VariantID, product.ID).First(&variant).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Varian tidak ditemukan"})
+			return
+		}
+	} else {
+		req.VariantID = nil
+	}
+
+	actor := middleware.GetCurrentUser(c)
+	var movement *models.InventoryMovement
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if req.Stock != nil {
+			movement, err = inventory.Set(tx, product.ID, req.VariantID, *req.Stock, models.MovementType(req.Type), actor, req.Note)
+		} else {
+			movement, err = inventory.Apply(tx, inventory.Change{
+				ProductID: product.ID,
+				VariantID: req.VariantID,
+				Delta:     *req.Delta,
+				Type:      models.MovementType(req.Type),
+				Actor:     actor,
+				Note:      req.Note,
+			})
+		}
+		return err
+	})
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stok tidak boleh negatif"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyesuaikan stok"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Stok berhasil disesuaikan",
+		"movement": movement,
+	})
+}
+
+// ReconcileInventory lists products and variants whose stock differs from their ledger
+func ReconcileInventory(c *gin.Context) {
+	productID, _ := strconv.ParseUint(c.Query("product_id"), 10, 32)
+
+	discrepancies, err := inventory.Reconcile(database.DB, uint(productID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal merekonsiliasi stok"})
+		return
+	}
+	if discrepancies == nil {
+		discrepancies = []inventory.Discrepancy{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  discrepancies,
+		"count": len(discrepancies),
+	})
+}
+
+// ResolveInventory records adjustments so the ledger matches the current stock
+func ResolveInventory(c *gin.Context) {
+	var req struct {
+		ProductID uint   `json:"product_id"` // 0 = all products
+		Note      string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid"})
+		return
+	}
+	if req.Note == "" {
+		req.Note = "Rekonsiliasi stok"
+	}
+
+	actor := middleware.GetCurrentUser(c)
+	var resolved []inventory.Discrepancy
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		discrepancies, err := inventory.Reconcile(tx, req.ProductID)
+		if err != nil {
+			return err
+		}
+		for _, d := range discrepancies {
+			if _, err := inventory.Resolve(tx, d, actor, req.Note); err != nil {
+				return err
+			}
+		}
+		resolved = discrepancies
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal merekonsiliasi stok"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Rekonsiliasi stok selesai",
+		"resolved": len(resolved),
+	})
+}
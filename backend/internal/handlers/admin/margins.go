@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"math"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/models"
+)
+
+// adminVariant exposes the variant cost price, which is hidden from customers
+type adminVariant struct {
+	models.ProductVariant
+	CostPrice     *float64 `json:"cost_price"`
+	MarginPercent *float64 `json:"margin_percent"`
+}
+
+// adminProduct exposes the cost price and margin of a product to admins
+type adminProduct struct {
+	models.Product
+	CostPrice     *float64       `json:"cost_price"`
+	MarginPercent *float64       `json:"margin_percent"`
+	Variants      []adminVariant `json:"variants,omitempty"`
+}
+
+// toAdminProduct wraps a product with its cost and margin
+func toAdminProduct(p models.Product) adminProduct {
+	ap := adminProduct{
+		Product:       p,
+		CostPrice:     p.CostPrice,
+		MarginPercent: marginOf(p.Price, p.CostPrice),
+	}
+	for _, v := range p.Variants {
+		cost := v.CostPrice
+		if cost == nil {
+			cost = p.CostPrice
+		}
+		ap.Variants = append(ap.Variants, adminVariant{
+			ProductVariant: v,
+			CostPrice:      v.CostPrice,
+			MarginPercent:  marginOf(v.Price, cost),
+		})
+	}
+	return ap
+}
+
+func toAdminProducts(products []models.Product) []adminProduct {
+	result := make([]adminProduct, len(products))
+	for i, p := range products {
+		result[i] = toAdminProduct(p)
+	}
+	return result
+}
+
+func marginOf(price float64, cost *float64) *float64 {
+	if cost == nil {
+		return nil
+	}
+	margin := math.Round(models.MarginPercent(price, *cost)*100) / 100
+	return &margin
+}
+
+// MarginViolation is a product or variant whose new price would fall below the minimum margin
+type MarginViolation struct {
+	ProductID     uint    `json:"product_id"`
+	VariantID     *uint   `json:"variant_id,omitempty"`
+	Name          string  `json:"name"`
+	CostPrice     float64 `json:"cost_price"`
+	NewPrice      float64 `json:"new_price"`
+	MarginPercent float64 `json:"margin_percent"`
+}
+
+// lowestPrice returns the cheapest price a customer can pay, taking the quantity tiers into account
+func lowestPrice(price float64, price3, price5 *float64) float64 {
+	lowest := price
+	for _, tier := range []*float64{price3, price5} {
+		if tier != nil && *tier > 0 && *tier < lowest {
+			lowest = *tier
+		}
+	}
+	return lowest
+}
+
+// checkMargin reports a violation when the lowest of the new prices is below the
+// configured minimum margin. Items without a cost price are not checked.
+func checkMargin(productID uint, variantID *uint, name string, cost *float64, price float64, price3, price5 *float64) *MarginViolation {
+	if cost == nil {
+		return nil
+	}
+	lowest := lowestPrice(price, price3, price5)
+	margin := models.MarginPercent(lowest, *cost)
+	if margin >= config.AppConfig.MinMarginPercent {
+		return nil
+	}
+	return &MarginViolation{
+		ProductID:     productID,
+		VariantID:     variantID,
+		Name:          name,
+		CostPrice:     *cost,
+		NewPrice:      lowest,
+		MarginPercent: math.Round(margin*100) / 100,
+	}
+}
@@ -1,23 +1,27 @@
 package admin
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"gsm-motor/internal/config"
 	"gsm-motor/internal/database"
+	"gsm-motor/internal/middleware"
 	"gsm-motor/internal/models"
+	"gsm-motor/internal/orders"
+	"gsm-motor/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // AdminListOrders returns all orders with filters
 func AdminListOrders(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
-	status := c.Query("status")
-	paymentStatus := c.Query("payment_status")
-	search := c.Query("search")
 
 	if page < 1 {
 		page = 1
@@ -29,14 +33,10 @@ func AdminListOrders(c *gin.Context) {
 		Preload("Items").
 		Preload("Items.Product")
 
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
-	if paymentStatus != "" {
-		query = query.Where("payment_status = ?", paymentStatus)
-	}
-	if search != "" {
-		query = query.Where("order_number LIKE ? OR shipping_address LIKE ?", "%"+search+"%", "%"+search+"%")
+	query, msg := applyOrderFilters(c, query)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
 	}
 
 	var total int64
@@ -60,6 +60,34 @@ func AdminListOrders(c *gin.Context) {
 	})
 }
 
+// applyOrderFilters applies the status, payment_status, search and from/to
+// (YYYY-MM-DD, inclusive) filters shared by the order list and export.
+// The returned message is customer-facing and empty when the filters are valid.
+func applyOrderFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, string) {
+	if status := c.Query("status"); status != "" {
+		query = query.Where("orders.status = ?", status)
+	}
+	if paymentStatus := c.Query("payment_status"); paymentStatus != "" {
+		query = query.Where("orders.payment_status = ?", paymentStatus)
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("orders.order_number LIKE ? OR orders.shipping_address LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+	if c.Query("from") != "" || c.Query("to") != "" {
+		from, to, ok := parseDateFilter(c)
+		if !ok {
+			return query, "Format tanggal tidak valid (YYYY-MM-DD)"
+		}
+		if !from.IsZero() {
+			query = query.Where("orders.created_at >= ?", from)
+		}
+		if !to.IsZero() {
+			query = query.Where("orders.created_at < ?", to)
+		}
+	}
+	return query, ""
+}
+
 // AdminGetOrder returns a single order with all details
 func AdminGetOrder(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -74,6 +102,8 @@ func AdminGetOrder(c *gin.Context) {
 		Preload("Items").
 		Preload("Items.Product").
 		Preload("PaymentProofs").
+		Preload("Charges").
+		Scopes(models.PreloadStatusHistory).
 		First(&order, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pesanan tidak ditemukan"})
 		return
@@ -87,40 +117,74 @@ type UpdateOrderStatusRequest struct {
 	Status         string `json:"status"`
 	PaymentStatus  string `json:"payment_status"`
 	TrackingNumber string `json:"tracking_number"`
+	Note           string `json:"note"`
 }
 
-// AdminUpdateOrderStatus updates order status
+// AdminUpdateOrderStatus updates order status following the order state machine
 func AdminUpdateOrderStatus(c *gin.Context) {
+	admin := middleware.GetCurrentUser(c)
+
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
 		return
 	}
 
-	var order models.Order
-	if err := database.DB.First(&order, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Pesanan tidak ditemukan"})
-		return
-	}
-
 	var req UpdateOrderStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid"})
 		return
 	}
 
-	// Update status
-	if req.Status != "" {
-		order.Status = models.OrderStatus(req.Status)
+	if req.Status != "" && !models.OrderStatus(req.Status).IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status pesanan tidak valid"})
+		return
 	}
-	if req.PaymentStatus != "" {
-		order.PaymentStatus = models.PaymentStatus(req.PaymentStatus)
+	if req.PaymentStatus != "" && !models.PaymentStatus(req.PaymentStatus).IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status pembayaran tidak valid"})
+		return
 	}
-	if req.TrackingNumber != "" {
-		order.TrackingNumber = &req.TrackingNumber
+
+	var order models.Order
+	cancelled := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+			return err
+		}
+
+		// Payment first, so "verify and process" can be sent in one request
+		if req.PaymentStatus != "" && models.PaymentStatus(req.PaymentStatus) != order.PaymentStatus {
+			if err := order.SetPaymentStatus(tx, models.PaymentStatus(req.PaymentStatus), admin, req.Note); err != nil {
+				return err
+			}
+		}
+		if req.Status != "" && models.OrderStatus(req.Status) != order.Status {
+			// Cancelling must also put the items back into stock
+			if models.OrderStatus(req.Status) == models.OrderCancelled {
+				if err := orders.Cancel(tx, &order, admin, req.Note); err != nil {
+					return err
+				}
+				cancelled = true
+			} else if err := order.TransitionTo(tx, models.OrderStatus(req.Status), admin, req.Note); err != nil {
+				return err
+			}
+		}
+		if req.TrackingNumber != "" {
+			order.TrackingNumber = &req.TrackingNumber
+			if err := tx.Model(&order).Update("tracking_number", req.TrackingNumber).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondOrderUpdateError(c, err)
+		return
 	}
 
-	database.DB.Save(&order)
+	if cancelled {
+		notifyCustomerOfCancellation(order.ID, req.Note)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Status pesanan berhasil diperbarui",
@@ -128,6 +192,80 @@ func AdminUpdateOrderStatus(c *gin.Context) {
 	})
 }
 
+// respondOrderUpdateError maps order update errors to HTTP responses
+// errPaymentOnCancelled is returned when a payment is verified for a cancelled
+// order, whose stock and voucher were already released
+var errPaymentOnCancelled = errors.New("cannot verify payment of a cancelled order")
+
+func respondOrderUpdateError(c *gin.Context, err error) {
+	var transitionErr *models.TransitionError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pesanan tidak ditemukan"})
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("Perubahan status dari %s ke %s tidak diizinkan", transitionErr.From, transitionErr.To),
+		})
+	case errors.Is(err, orders.ErrNotCancellable):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Pesanan tidak dapat dibatalkan pada status ini"})
+	case errors.Is(err, errPaymentOnCancelled):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Pesanan sudah dibatalkan; pembayaran tidak dapat diverifikasi dan perlu direfund"})
+	case errors.Is(err, models.ErrStaleOrder):
+		c.JSON(http.StatusConflict, gin.H{"error": "Pesanan baru saja diubah, silakan muat ulang"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui pesanan"})
+	}
+}
+
+// AdminCancelOrder cancels an order with a reason and restores its stock
+func AdminCancelOrder(c *gin.Context) {
+	admin := middleware.GetCurrentUser(c)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" binding:"required,min=3"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alasan pembatalan wajib diisi"})
+		return
+	}
+
+	var order *models.Order
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = orders.LockOrder(tx, uint(id))
+		if err != nil {
+			return err
+		}
+		return orders.Cancel(tx, order, admin, req.Reason)
+	})
+	if err != nil {
+		respondOrderUpdateError(c, err)
+		return
+	}
+
+	notifyCustomerOfCancellation(order.ID, req.Reason)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pesanan berhasil dibatalkan",
+		"order":   order,
+	})
+}
+
+// notifyCustomerOfCancellation emails the order owner about a cancellation (async)
+func notifyCustomerOfCancellation(orderID uint, reason string) {
+	var order models.Order
+	if err := database.DB.Preload("User").First(&order, orderID).Error; err != nil || order.User == nil {
+		return
+	}
+	go utils.SendOrderCancelledEmail(order.User.Email, order.OrderNumber, order.User.Name, reason)
+}
+
 // AdminDeleteOrder deletes an order and its related data
 func AdminDeleteOrder(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -164,6 +302,13 @@ func AdminDeleteOrder(c *gin.Context) {
 		return
 	}
 
+	// Delete status history
+	if err := tx.Where("order_id = ?", id).Delete(&models.OrderStatusHistory{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus riwayat status"})
+		return
+	}
+
 	// Delete the order itself
 	if err := tx.Delete(&order).Error; err != nil {
 		tx.Rollback()
@@ -184,6 +329,8 @@ func AdminDeleteOrder(c *gin.Context) {
 
 // AdminVerifyPayment verifies or rejects payment proof
 func AdminVerifyPayment(c *gin.Context) {
+	admin := middleware.GetCurrentUser(c)
+
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
@@ -212,22 +359,38 @@ func AdminVerifyPayment(c *gin.Context) {
 		return
 	}
 
-	proof.Status = models.PaymentProofStatus(req.Status)
-	if req.AdminNotes != "" {
-		proof.AdminNotes = &req.AdminNotes
-	}
-	database.DB.Save(&proof)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+			return err
+		}
+		if req.Status == "verified" && order.Status == models.OrderCancelled {
+			return errPaymentOnCancelled
+		}
 
-	// Update order payment status
-	var order models.Order
-	if err := database.DB.First(&order, id).Error; err == nil {
+		proof.Status = models.PaymentProofStatus(req.Status)
+		if req.AdminNotes != "" {
+			proof.AdminNotes = &req.AdminNotes
+		}
+		if err := tx.Save(&proof).Error; err != nil {
+			return err
+		}
+
+		// Update order payment status
 		if req.Status == "verified" {
-			order.PaymentStatus = models.PaymentVerified
-			order.Status = models.OrderProcessing
-		} else {
-			order.PaymentStatus = models.PaymentFailed
+			if err := order.SetPaymentStatus(tx, models.PaymentVerified, admin, req.AdminNotes); err != nil {
+				return err
+			}
+			if order.Status == models.OrderPending {
+				return order.TransitionTo(tx, models.OrderProcessing, admin, "Pembayaran terverifikasi")
+			}
+			return nil
 		}
-		database.DB.Save(&order)
+		return order.SetPaymentStatus(tx, models.PaymentFailed, admin, req.AdminNotes)
+	})
+	if err != nil {
+		respondOrderUpdateError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -264,13 +427,13 @@ func AdminDashboard(c *gin.Context) {
 	// Processing orders
 	database.DB.Model(&models.Order{}).Where("status = ?", "processing").Count(&stats.ProcessingOrders)
 
-	// Low stock products (< 10)
-	database.DB.Model(&models.Product{}).Where("stock < 10").Count(&stats.LowStockProducts)
+	// Low stock products
+	database.DB.Model(&models.Product{}).Scopes(models.LowStock).Count(&stats.LowStockProducts)
 
 	// Total revenue from completed orders
 	database.DB.Model(&models.Order{}).
 		Where("status = ? AND payment_status = ?", "completed", "verified").
-		Select("COALESCE(SUM(total_price + shipping_cost), 0)").
+		Select("COALESCE(SUM(total_price + shipping_cost - discount_amount), 0)").
 		Scan(&stats.TotalRevenue)
 
 	// Today's orders
@@ -295,7 +458,8 @@ func AdminDashboard(c *gin.Context) {
 	var lowStockProducts []models.Product
 	database.DB.
 		Preload("Category").
-		Where("stock < 10").
+		Preload("Supplier").
+		Scopes(models.LowStock).
 		Order("stock ASC").
 		Limit(10).
 		Find(&lowStockProducts)
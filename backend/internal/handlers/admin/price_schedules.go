@@ -0,0 +1,327 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/pricing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PriceScheduleRequest represents the create price schedule request. A change
+// needs a future start time; a sale starts now when starts_at is empty and
+// always needs an end time.
+type PriceScheduleRequest struct {
+	Kind        string     `json:"kind" binding:"required,oneof=change sale"`
+	VariantID   *uint      `json:"variant_id"`
+	Price       float64    `json:"price" binding:"required,gt=0"`
+	Price3Items *float64   `json:"price_3_items"`
+	Price5Items *float64   `json:"price_5_items"`
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	Note        string     `json:"note" binding:"max=255"`
+	Force       bool       `json:"force"` // Allow a price below the minimum margin
+}
+
+// ListPriceHistory returns the price changes of a product and its variants, newest first
+func ListPriceHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+	offset := (page - 1) * perPage
+
+	query := database.DB.Model(&models.ProductPriceHistory{}).Where("product_id = ?", id)
+	if variantID, _ := strconv.ParseUint(c.Query("variant_id"), 10, 32); variantID > 0 {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var history []models.ProductPriceHistory
+	query.
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name, email, role")
+		}).
+		Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(perPage).
+		Find(&history)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": history,
+		"meta": gin.H{
+			"current_page": page,
+			"per_page":     perPage,
+			"total":        total,
+			"total_pages":  (total + int64(perPage) - 1) / int64(perPage),
+		},
+	})
+}
+
+// ListPriceSchedules returns the scheduled price changes and sales of a product
+func ListPriceSchedules(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	query := database.DB.Where("product_id = ?", id)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var schedules []models.PriceSchedule
+	query.Order("starts_at DESC, id DESC").Find(&schedules)
+
+	c.JSON(http.StatusOK, gin.H{"data": schedules})
+}
+
+// CreatePriceSchedule schedules a price change or a sale for a product. Products
+// sold in variants are scheduled per variant. Sales of the same product or
+// variant may not overlap.
+func CreatePriceSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var req PriceScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.Preload("Variants").First(&product, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Produk tidak ditemukan"})
+		return
+	}
+
+	// Resolve the product or variant whose price is scheduled
+	name, currentPrice, cost := product.Name, product.Price, product.CostPrice
+	if len(product.Variants) > 0 {
+		if req.VariantID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Harga produk bervarian dijadwalkan per varian"})
+			return
+		}
+		var variant *models.ProductVariant
+		for i := range product.Variants {
+			if product.Variants[i].ID == *req.VariantID {
+				variant = &product.Variants[i]
+			}
+		}
+		if variant == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Varian tidak ditemukan"})
+			return
+		}
+		name, currentPrice = product.Name+" - "+variant.Name, variant.Price
+		if variant.CostPrice != nil {
+			cost = variant.CostPrice
+		}
+	} else if req.VariantID != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Varian tidak ditemukan"})
+		return
+	}
+
+	if msg := validatePriceSchedule(&req, currentPrice); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if v := checkMargin(product.ID, req.VariantID, name, cost, req.Price, req.Price3Items, req.Price5Items); v != nil && !req.Force {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":              "Harga membuat margin di bawah batas minimum",
+			"min_margin_percent": config.AppConfig.MinMarginPercent,
+			"violations":         []MarginViolation{*v},
+		})
+		return
+	}
+
+	schedule := models.PriceSchedule{
+		ProductID:   product.ID,
+		VariantID:   req.VariantID,
+		Kind:        models.PriceScheduleKind(req.Kind),
+		Status:      models.ScheduleScheduled,
+		Price:       req.Price,
+		Price3Items: req.Price3Items,
+		Price5Items: req.Price5Items,
+		StartsAt:    *req.StartsAt,
+		EndsAt:      req.EndsAt,
+	}
+	if req.Note != "" {
+		schedule.Note = &req.Note
+	}
+	if admin := middleware.GetCurrentUser(c); admin != nil {
+		schedule.CreatedBy = &admin.ID
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if schedule.Kind == models.PriceSale {
+			// Lock the product so two overlapping sales cannot be created at once
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Product{}, product.ID).Error; err != nil {
+				return err
+			}
+			overlap := tx.Model(&models.PriceSchedule{}).
+				Where("product_id = ? AND kind = ? AND status IN ?", product.ID, models.PriceSale,
+					[]models.PriceScheduleStatus{models.ScheduleScheduled, models.ScheduleActive}).
+				Where("starts_at < ? AND ends_at > ?", schedule.EndsAt, schedule.StartsAt)
+			if schedule.VariantID != nil {
+				overlap = overlap.Where("variant_id = ?", *schedule.VariantID)
+			} else {
+				overlap = overlap.Where("variant_id IS NULL")
+			}
+			var count int64
+			if err := overlap.Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errSaleOverlap
+			}
+		}
+		return tx.Create(&schedule).Error
+	})
+	if errors.Is(err, errSaleOverlap) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sudah ada promo lain pada periode tersebut"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat jadwal harga"})
+		return
+	}
+
+	// A sale starting now takes effect right away instead of on the next job run
+	if !schedule.StartsAt.After(time.Now()) {
+		if _, err := pricing.ApplyDue(database.DB, time.Now()); err != nil {
+			log.Printf("Failed to apply price schedule %d: %v", schedule.ID, err)
+		}
+		database.DB.First(&schedule, schedule.ID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Jadwal harga berhasil dibuat",
+		"schedule": schedule,
+	})
+}
+
+// errSaleOverlap is returned when a sale overlaps another sale of the same product or variant
+var errSaleOverlap = errors.New("sale overlaps another sale")
+
+// validatePriceSchedule checks a schedule request against the current price and
+// fills in the default start time. It returns a customer-facing message.
+func validatePriceSchedule(req *PriceScheduleRequest, currentPrice float64) string {
+	now := time.Now()
+	for _, tier := range []**float64{&req.Price3Items, &req.Price5Items} {
+		if *tier != nil && **tier <= 0 {
+			*tier = nil
+		}
+	}
+
+	switch models.PriceScheduleKind(req.Kind) {
+	case models.PriceChange:
+		if req.StartsAt == nil || !req.StartsAt.After(now) {
+			return "Waktu mulai perubahan harga harus di masa depan"
+		}
+		if req.EndsAt != nil {
+			return "Waktu selesai hanya untuk promo"
+		}
+		if req.Price3Items != nil && *req.Price3Items >= req.Price {
+			return "Harga 3 item harus lebih murah dari harga satuan"
+		}
+		if req.Price5Items != nil && *req.Price5Items >= req.Price {
+			return "Harga 5 item harus lebih murah dari harga satuan"
+		}
+
+	case models.PriceSale:
+		if req.Price3Items != nil || req.Price5Items != nil {
+			return "Promo hanya memiliki satu harga"
+		}
+		if req.Price >= currentPrice {
+			return "Harga promo harus lebih murah dari harga saat ini"
+		}
+		if req.StartsAt == nil {
+			req.StartsAt = &now
+		}
+		if req.EndsAt == nil || !req.EndsAt.After(*req.StartsAt) || !req.EndsAt.After(now) {
+			return "Waktu selesai promo harus setelah waktu mulai"
+		}
+	}
+	return ""
+}
+
+// CancelPriceSchedule cancels a price schedule that has not finished. An active
+// sale ends immediately.
+func CancelPriceSchedule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var schedule models.PriceSchedule
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, id).Error; err != nil {
+			return err
+		}
+		if schedule.Status != models.ScheduleScheduled && schedule.Status != models.ScheduleActive {
+			return errPriceScheduleFinished
+		}
+		if err := pricing.EndSale(tx, &schedule); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		schedule.Status = models.ScheduleCancelled
+		schedule.EndedAt = &now
+		return tx.Model(&schedule).Updates(map[string]interface{}{
+			"status":   schedule.Status,
+			"ended_at": schedule.EndedAt,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Jadwal harga tidak ditemukan"})
+		return
+	}
+	if errors.Is(err, errPriceScheduleFinished) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Jadwal harga sudah selesai atau dibatalkan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membatalkan jadwal harga"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Jadwal harga berhasil dibatalkan",
+		"schedule": schedule,
+	})
+}
+
+// errPriceScheduleFinished is returned when a schedule can no longer be cancelled
+var errPriceScheduleFinished = errors.New("price schedule already finished")
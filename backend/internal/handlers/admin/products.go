@@ -1,27 +1,30 @@
 package admin
 
 import (
-	"log"
+	"errors"
 	"math"
 	"net/http"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"gsm-motor/internal/database"
+	"gsm-motor/internal/inventory"
 	"gsm-motor/internal/middleware"
 	"gsm-motor/internal/models"
+	"gsm-motor/internal/search"
 	"gsm-motor/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // AdminListProducts returns all products for admin
 func AdminListProducts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
-	search := c.Query("search")
 
 	if page < 1 {
 		page = 1
@@ -30,37 +33,61 @@ func AdminListProducts(c *gin.Context) {
 
 	query := database.DB.Model(&models.Product{}).
 		Preload("Category").
-		Preload("Images")
+		Preload("Images").
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		})
 
-	if search != "" {
-		query = query.Scopes(models.ProductSearch(search))
-	}
-
-	var total int64
-	query.Count(&total)
+	query, result := applyProductFilters(c, query)
+	total := result.Total
 
 	var products []models.Product
 	query.
-		Order("CASE WHEN stock < 10 THEN 0 ELSE 1 END, stock ASC, created_at DESC").
 		Offset(offset).
 		Limit(perPage).
 		Find(&products)
 
+	models.AttachVariantSummaries(database.DB, products)
+
 	c.JSON(http.StatusOK, gin.H{
-		"data": products,
+		"data": toAdminProducts(products),
 		"meta": gin.H{
-			"current_page": page,
-			"per_page":     perPage,
-			"total":        total,
-			"total_pages":  (total + int64(perPage) - 1) / int64(perPage),
+			"current_page":     page,
+			"per_page":         perPage,
+			"total":            total,
+			"total_pages":      (total + int64(perPage) - 1) / int64(perPage),
+			"search_corrected": result.Corrected,
 		},
 	})
 }
 
+// applyProductFilters applies the low_stock and search filters shared by the
+// admin product list and export, and orders the products: best matches first
+// when searching, otherwise low stock first
+func applyProductFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, search.Result) {
+	// Only products below their own reorder point
+	if c.Query("low_stock") == "true" {
+		query = query.Scopes(models.LowStock)
+	}
+
+	searchText := c.Query("search")
+	query, result := search.Match(query, searchText)
+	if searchText != "" {
+		query = search.OrderByRelevance(query, result.Query)
+	} else {
+		query = query.Order("CASE WHEN stock < reorder_point THEN 0 ELSE 1 END, stock ASC, created_at DESC")
+	}
+	return query, result
+}
+
 // AdminCreateProduct creates a new product
 func AdminCreateProduct(c *gin.Context) {
 	name := c.PostForm("name")
 	categoryID, _ := strconv.ParseUint(c.PostForm("category_id"), 10, 32)
+	supplierID, _ := strconv.ParseUint(c.PostForm("supplier_id"), 10, 32)
+	reorderPoint, reorderErr := strconv.Atoi(c.PostForm("reorder_point"))
+	reorderQuantity, _ := strconv.Atoi(c.PostForm("reorder_quantity"))
+	costPrice, _ := strconv.ParseFloat(c.PostForm("cost_price"), 64)
 	price, _ := strconv.ParseFloat(c.PostForm("price"), 64)
 	price3, _ := strconv.ParseFloat(c.PostForm("price_3_items"), 64)
 	price5, _ := strconv.ParseFloat(c.PostForm("price_5_items"), 64)
@@ -68,8 +95,22 @@ func AdminCreateProduct(c *gin.Context) {
 	weight, _ := strconv.Atoi(c.PostForm("weight"))
 	description := c.PostForm("description")
 	submittedBy := c.PostForm("submitted_by") // New field for subadmin tracking
+	sku := strings.TrimSpace(c.PostForm("sku"))
 
-	if name == "" || categoryID == 0 || price <= 0 {
+	variants, hasVariants, msg := parseVariantsField(c.PostForm("variants"))
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if sku != "" {
+		if inUse, _ := models.SKUInUse(database.DB, sku, 0, 0); inUse {
+			c.JSON(http.StatusConflict, gin.H{"error": "SKU " + sku + " sudah digunakan"})
+			return
+		}
+	}
+
+	// The price of a product with variants comes from its cheapest variant
+	if name == "" || categoryID == 0 || (price <= 0 && len(variants) == 0) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Nama, kategori, dan harga wajib diisi"})
 		return
 	}
@@ -77,18 +118,25 @@ func AdminCreateProduct(c *gin.Context) {
 	if weight <= 0 {
 		weight = 500 // Default weight in grams
 	}
+	if reorderErr != nil || reorderPoint <= 0 {
+		reorderPoint = models.DefaultReorderPoint
+	}
+	if reorderQuantity < 0 {
+		reorderQuantity = 0
+	}
 
 	// Generate slug
 	productSlug := slug.Make(name) + "-" + strconv.FormatInt(time.Now().Unix(), 36)
 
 	product := models.Product{
-		Name:        name,
-		Slug:        productSlug,
-		CategoryID:  uint(categoryID),
-		Price:       price,
-		Stock:       stock,
-		Weight:      weight,
-		Description: &description,
+		Name:            name,
+		Slug:            productSlug,
+		CategoryID:      uint(categoryID),
+		Price:           price,
+		Weight:          weight,
+		ReorderPoint:    reorderPoint,
+		ReorderQuantity: reorderQuantity,
+		Description:     &description,
 	}
 
 	// Set submitted_by if provided (case-sensitive)
@@ -96,6 +144,17 @@ func AdminCreateProduct(c *gin.Context) {
 		product.SubmittedBy = &submittedBy
 	}
 
+	if supplierID > 0 {
+		supplier := uint(supplierID)
+		product.SupplierID = &supplier
+	}
+	if sku != "" {
+		product.SKU = &sku
+	}
+	if costPrice > 0 {
+		product.CostPrice = &costPrice
+	}
+
 	if price3 > 0 {
 		product.Price3Items = &price3
 	}
@@ -103,8 +162,27 @@ func AdminCreateProduct(c *gin.Context) {
 		product.Price5Items = &price5
 	}
 
-	if err := database.DB.Create(&product).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat produk"})
+	// Opening stock is recorded in the inventory ledger
+	actor := middleware.GetCurrentUser(c)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		if hasVariants {
+			return replaceProductVariants(tx, &product, variants, actor)
+		}
+		entry := manualPriceChange(actor, product.ID, nil)
+		entry.NewPrice, entry.NewPrice3Items, entry.NewPrice5Items = product.Price, product.Price3Items, product.Price5Items
+		if err := models.RecordPriceChange(tx, entry); err != nil {
+			return err
+		}
+		if _, err := inventory.Set(tx, product.ID, nil, stock, models.MovementAdjustment, actor, "Stok awal"); err != nil {
+			return err
+		}
+		return tx.First(&product, product.ID).Error
+	})
+	if err != nil {
+		respondVariantError(c, err, "Gagal membuat produk")
 		return
 	}
 
@@ -132,7 +210,7 @@ func AdminCreateProduct(c *gin.Context) {
 			// Set first image as primary
 			if i == 0 {
 				product.ImagePath = &imagePath
-				database.DB.Save(&product)
+				database.DB.Model(&product).Update("image_path", imagePath)
 			}
 		}
 	}
@@ -152,7 +230,7 @@ func AdminCreateProduct(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Produk berhasil dibuat",
-		"product": product,
+		"product": toAdminProduct(product),
 	})
 }
 
@@ -170,37 +248,141 @@ func AdminUpdateProduct(c *gin.Context) {
 		return
 	}
 
+	// Only the fields the form edits are written, so a sale started by a price
+	// schedule or a low-stock alert stamp set meanwhile is not reverted
+	var edited []string
+
 	// Update fields
 	if name := c.PostForm("name"); name != "" {
 		product.Name = name
+		edited = append(edited, "Name")
 	}
 	if categoryID, _ := strconv.ParseUint(c.PostForm("category_id"), 10, 32); categoryID > 0 {
 		product.CategoryID = uint(categoryID)
+		edited = append(edited, "CategoryID")
+	}
+	// An empty or zero supplier_id clears the default supplier
+	if raw, ok := c.GetPostForm("supplier_id"); ok {
+		product.SupplierID = nil
+		if supplierID, _ := strconv.ParseUint(raw, 10, 32); supplierID > 0 {
+			supplier := uint(supplierID)
+			product.SupplierID = &supplier
+		}
+		edited = append(edited, "SupplierID")
 	}
 	if price, _ := strconv.ParseFloat(c.PostForm("price"), 64); price > 0 {
 		product.Price = price
+		edited = append(edited, "Price")
 	}
 	if price3, _ := strconv.ParseFloat(c.PostForm("price_3_items"), 64); price3 >= 0 {
 		product.Price3Items = &price3
+		edited = append(edited, "Price3Items")
 	}
 	if price5, _ := strconv.ParseFloat(c.PostForm("price_5_items"), 64); price5 >= 0 {
 		product.Price5Items = &price5
+		edited = append(edited, "Price5Items")
 	}
-	if stock, err := strconv.Atoi(c.PostForm("stock")); err == nil {
-		product.Stock = stock
-	}
+	stock, stockErr := strconv.Atoi(c.PostForm("stock"))
 	if weight, _ := strconv.Atoi(c.PostForm("weight")); weight > 0 {
 		product.Weight = weight
+		edited = append(edited, "Weight")
+	}
+	// An empty cost_price clears the cost
+	if raw, ok := c.GetPostForm("cost_price"); ok {
+		product.CostPrice = nil
+		if costPrice, err := strconv.ParseFloat(raw, 64); err == nil && costPrice > 0 {
+			product.CostPrice = &costPrice
+		}
+		edited = append(edited, "CostPrice")
+	}
+	if reorderPoint, err := strconv.Atoi(c.PostForm("reorder_point")); err == nil && reorderPoint >= 0 {
+		product.ReorderPoint = reorderPoint
+		edited = append(edited, "ReorderPoint")
+	}
+	if reorderQuantity, err := strconv.Atoi(c.PostForm("reorder_quantity")); err == nil && reorderQuantity >= 0 {
+		product.ReorderQuantity = reorderQuantity
+		edited = append(edited, "ReorderQuantity")
 	}
 	if desc := c.PostForm("description"); desc != "" {
 		product.Description = &desc
+		edited = append(edited, "Description")
 	}
 	// Update submitted_by if provided (case-sensitive)
 	if submittedBy := c.PostForm("submitted_by"); submittedBy != "" {
 		product.SubmittedBy = &submittedBy
+		edited = append(edited, "SubmittedBy")
+	}
+	// An empty sku clears it
+	if raw, ok := c.GetPostForm("sku"); ok {
+		product.SKU = nil
+		if sku := strings.TrimSpace(raw); sku != "" {
+			if inUse, _ := models.SKUInUse(database.DB, sku, product.ID, 0); inUse {
+				c.JSON(http.StatusConflict, gin.H{"error": "SKU " + sku + " sudah digunakan"})
+				return
+			}
+			product.SKU = &sku
+		}
+		edited = append(edited, "SKU")
+	}
+
+	variants, hasVariants, msg := parseVariantsField(c.PostForm("variants"))
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
 	}
 
-	database.DB.Save(&product)
+	// Stock changes go through the inventory ledger; stock and price of a
+	// product with variants are derived from its variants
+	actor := middleware.GetCurrentUser(c)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// The old prices for the history are read under the lock as well
+		var current models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, price, price_3_items, price_5_items").
+			First(&current, product.ID).Error; err != nil {
+			return err
+		}
+		oldPrice, oldPrice3, oldPrice5 := current.Price, current.Price3Items, current.Price5Items
+
+		if len(edited) > 0 {
+			if err := tx.Model(&product).Select(append(edited, "UpdatedAt")).Updates(&product).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.First(&product, product.ID).Error; err != nil {
+			return err
+		}
+		if hasVariants {
+			return replaceProductVariants(tx, &product, variants, actor)
+		}
+
+		var variantCount int64
+		if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount).Error; err != nil {
+			return err
+		}
+		if variantCount > 0 {
+			if err := models.SyncProductFromVariants(tx, product.ID); err != nil {
+				return err
+			}
+		} else {
+			entry := manualPriceChange(actor, product.ID, nil)
+			entry.OldPrice, entry.OldPrice3Items, entry.OldPrice5Items = &oldPrice, oldPrice3, oldPrice5
+			entry.NewPrice, entry.NewPrice3Items, entry.NewPrice5Items = product.Price, product.Price3Items, product.Price5Items
+			if err := models.RecordPriceChange(tx, entry); err != nil {
+				return err
+			}
+			if stockErr == nil {
+				if _, err := inventory.Set(tx, product.ID, nil, stock, models.MovementAdjustment, actor, "Penyesuaian stok"); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Preload("Variants").First(&product, product.ID).Error
+	})
+	if err != nil {
+		respondVariantError(c, err, "Gagal memperbarui produk")
+		return
+	}
 
 	// Handle new image uploads
 	form, _ := c.MultipartForm()
@@ -225,17 +407,41 @@ func AdminUpdateProduct(c *gin.Context) {
 			// Update primary image if not set
 			if product.ImagePath == nil {
 				product.ImagePath = &imagePath
-				database.DB.Save(&product)
+				database.DB.Model(&product).Update("image_path", imagePath)
 			}
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Produk berhasil diperbarui",
-		"product": product,
+		"product": toAdminProduct(product),
 	})
 }
 
+// manualPriceChange starts a price history entry for a price set in the admin product form
+func manualPriceChange(actor *models.User, productID uint, variantID *uint) *models.ProductPriceHistory {
+	entry := &models.ProductPriceHistory{ProductID: productID, VariantID: variantID, Source: models.PriceSourceManual}
+	if actor != nil {
+		actorID := actor.ID
+		entry.ChangedBy = &actorID
+	}
+	return entry
+}
+
+// respondVariantError reports a failed product save, surfacing SKU conflicts
+func respondVariantError(c *gin.Context, err error, fallback string) {
+	var conflict *SKUConflictError
+	if errors.As(err, &conflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU " + conflict.SKU + " sudah digunakan"})
+		return
+	}
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stok tidak boleh negatif"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+}
+
 // AdminDeleteProduct deletes a product (admin only)
 func AdminDeleteProduct(c *gin.Context) {
 	user := middleware.GetCurrentUser(c)
@@ -265,102 +471,49 @@ func AdminDeleteProduct(c *gin.Context) {
 		processor.DeleteImage(*product.ImagePath)
 	}
 
-	// Delete product (cascade deletes images)
+	// Delete product (cascade deletes images), its variants and fitments
+	database.DB.Where("product_id = ?", product.ID).Delete(&models.ProductVariant{})
+	database.DB.Model(&product).Association("Vehicles").Clear()
 	database.DB.Delete(&product)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Produk berhasil dihapus"})
 }
 
-// BulkPriceUpdate updates all product prices by percentage
-func BulkPriceUpdate(c *gin.Context) {
-	var req struct {
-		Percentage float64 `json:"percentage" binding:"required,min=-100,max=100"`
+// adjustTierPrices applies a percentage change to a base price and its tiers,
+// rounding up to the nearest 500 and keeping each tier cheaper than the one above.
+// A nil tier stays nil.
+func adjustTierPrices(price float64, price3, price5 *float64, percentage float64) (float64, *float64, *float64) {
+	// Helper function to beautify price
+	beautifyPrice := func(price float64) float64 {
+		// Round to nearest 500
+		return math.Ceil(price/500) * 500
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Persentase tidak valid (-100 sampai 100)"})
-		return
-	}
-
-	// Get all products
-	var products []models.Product
-	database.DB.Find(&products)
-
-	// Use goroutines for parallel processing
-	var wg sync.WaitGroup
-	batchSize := 100
-	now := time.Now()
-
-	// Log start
-	log.Printf("Starting bulk update for %d products with percentage: %f", len(products), req.Percentage)
+	// Calculate new base price
+	newPrice := beautifyPrice(price * (1 + percentage/100))
 
-	for i := 0; i < len(products); i += batchSize {
-		end := i + batchSize
-		if end > len(products) {
-			end = len(products)
+	var newPrice3, newPrice5 *float64
+	if price3 != nil && *price3 > 0 {
+		beauty3 := beautifyPrice(*price3 * (1 + percentage/100))
+		if beauty3 >= newPrice {
+			beauty3 = newPrice - 500
 		}
+		newPrice3 = &beauty3
+	}
 
-		wg.Add(1)
-		go func(batch []models.Product) {
-			defer wg.Done()
-			for _, p := range batch {
-				// Helper function to beautify price
-				beautifyPrice := func(price float64) float64 {
-					// Round to nearest 500
-					return math.Ceil(price/500) * 500
-				}
-
-				oldPrice := p.Price
-				// Calculate new base price
-				newPrice := p.Price * (1 + req.Percentage/100)
-				newPrice = beautifyPrice(newPrice)
-
-				updates := map[string]interface{}{
-					"price":             newPrice,
-					"last_price_update": now,
-				}
-
-				// Update tiered prices if they exist
-				if p.Price3Items != nil && *p.Price3Items > 0 {
-					newPrice3 := *p.Price3Items * (1 + req.Percentage/100)
-					beauty3 := beautifyPrice(newPrice3)
-					if beauty3 >= newPrice {
-						beauty3 = newPrice - 500
-					}
-					updates["price_3_items"] = beauty3
-				}
-
-				if p.Price5Items != nil && *p.Price5Items > 0 {
-					newPrice5 := *p.Price5Items * (1 + req.Percentage/100)
-					beauty5 := beautifyPrice(newPrice5)
-
-					price3Val, exists := updates["price_3_items"]
-					if exists {
-						if beauty5 >= price3Val.(float64) {
-							beauty5 = price3Val.(float64) - 500
-						}
-					} else if beauty5 >= newPrice {
-						beauty5 = newPrice - 1000
-					}
-					updates["price_5_items"] = beauty5
-				}
-
-				// Explicitly use ID and check error
-				if err := database.DB.Model(&models.Product{ID: p.ID}).Updates(updates).Error; err != nil {
-					log.Printf("Error updating product %d: %v", p.ID, err)
-				} else {
-					log.Printf("Updated product %d: %f -> %f", p.ID, oldPrice, newPrice)
-				}
+	if price5 != nil && *price5 > 0 {
+		beauty5 := beautifyPrice(*price5 * (1 + percentage/100))
+		if newPrice3 != nil {
+			if beauty5 >= *newPrice3 {
+				beauty5 = *newPrice3 - 500
 			}
-		}(products[i:end])
+		} else if beauty5 >= newPrice {
+			beauty5 = newPrice - 1000
+		}
+		newPrice5 = &beauty5
 	}
 
-	wg.Wait()
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Harga berhasil diperbarui",
-		"count":   len(products),
-	})
+	return newPrice, newPrice3, newPrice5
 }
 
 // SubadminStats represents statistics for a subadmin
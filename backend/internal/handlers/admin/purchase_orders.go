@@ -0,0 +1,610 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/inventory"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PurchaseOrderItemInput is one line of a purchase order request
+type PurchaseOrderItemInput struct {
+	ProductID uint    `json:"product_id" binding:"required"`
+	VariantID *uint   `json:"variant_id"`
+	Quantity  int     `json:"quantity" binding:"required,min=1"`
+	UnitCost  float64 `json:"unit_cost" binding:"min=0"`
+}
+
+// PurchaseOrderRequest represents the create/update purchase order request
+type PurchaseOrderRequest struct {
+	SupplierID uint                     `json:"supplier_id" binding:"required"`
+	Notes      string                   `json:"notes"`
+	Items      []PurchaseOrderItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+// ReceiveItemInput is the quantity received for one purchase order line
+type ReceiveItemInput struct {
+	ItemID   uint     `json:"item_id" binding:"required"`
+	Quantity int      `json:"quantity" binding:"required,min=1"`
+	UnitCost *float64 `json:"unit_cost"` // Defaults to the cost on the purchase order
+}
+
+// openPurchaseStatuses are the states in which a purchase order still expects goods
+var openPurchaseStatuses = []models.PurchaseOrderStatus{
+	models.PurchaseDraft,
+	models.PurchaseOrdered,
+	models.PurchasePartiallyReceived,
+}
+
+// errPurchaseOrderState is returned when a purchase order is not in a state that allows the action
+var errPurchaseOrderState = errors.New("purchase order state does not allow this action")
+
+// purchaseLineError is a customer-facing validation error for a purchase order line
+type purchaseLineError struct {
+	Message string
+}
+
+func (e *purchaseLineError) Error() string {
+	return e.Message
+}
+
+// ListPurchaseOrders returns purchase orders, optionally filtered by status or supplier
+func ListPurchaseOrders(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+	offset := (page - 1) * perPage
+
+	query := database.DB.Model(&models.PurchaseOrder{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if supplierID, _ := strconv.ParseUint(c.Query("supplier_id"), 10, 32); supplierID > 0 {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var purchaseOrders []models.PurchaseOrder
+	query.
+		Preload("Supplier").
+		Preload("Items").
+		Order("created_at DESC").
+		Offset(offset).
+		Limit(perPage).
+		Find(&purchaseOrders)
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": purchaseOrders,
+		"meta": gin.H{
+			"current_page": page,
+			"per_page":     perPage,
+			"total":        total,
+			"total_pages":  (total + int64(perPage) - 1) / int64(perPage),
+		},
+	})
+}
+
+// GetPurchaseOrder returns a purchase order with its lines
+func GetPurchaseOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var po models.PurchaseOrder
+	if err := preloadPurchaseOrder(database.DB).First(&po, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "PO tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purchase_order": po})
+}
+
+// CreatePurchaseOrder creates a draft purchase order
+func CreatePurchaseOrder(c *gin.Context) {
+	var req PurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, req.SupplierID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier tidak ditemukan"})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	po := models.PurchaseOrder{
+		Number:     models.GeneratePurchaseOrderNumber(),
+		SupplierID: supplier.ID,
+		Status:     models.PurchaseDraft,
+	}
+	if user != nil {
+		po.CreatedBy = &user.ID
+	}
+	if req.Notes != "" {
+		po.Notes = &req.Notes
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&po).Error; err != nil {
+			return err
+		}
+		return createPurchaseOrderItems(tx, po.ID, req.Items)
+	})
+	if err != nil {
+		respondPurchaseOrderError(c, err, "Gagal membuat PO")
+		return
+	}
+
+	preloadPurchaseOrder(database.DB).First(&po, po.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "PO berhasil dibuat",
+		"purchase_order": po,
+	})
+}
+
+// UpdatePurchaseOrder replaces the supplier, notes and lines of a draft purchase order
+func UpdatePurchaseOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var req PurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, req.SupplierID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier tidak ditemukan"})
+		return
+	}
+
+	var po models.PurchaseOrder
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPurchaseOrder(tx, uint(id), &po); err != nil {
+			return err
+		}
+		if !po.IsEditable() {
+			return errPurchaseOrderState
+		}
+
+		po.SupplierID = supplier.ID
+		po.Notes = nil
+		if req.Notes != "" {
+			po.Notes = &req.Notes
+		}
+		if err := tx.Save(&po).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("purchase_order_id = ?", po.ID).Delete(&models.PurchaseOrderItem{}).Error; err != nil {
+			return err
+		}
+		return createPurchaseOrderItems(tx, po.ID, req.Items)
+	})
+	if err != nil {
+		respondPurchaseOrderError(c, err, "Gagal memperbarui PO")
+		return
+	}
+
+	preloadPurchaseOrder(database.DB).First(&po, po.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "PO berhasil diperbarui",
+		"purchase_order": po,
+	})
+}
+
+// SubmitPurchaseOrder marks a draft purchase order as ordered from the supplier
+func SubmitPurchaseOrder(c *gin.Context) {
+	changePurchaseOrderStatus(c, func(po *models.PurchaseOrder) (map[string]interface{}, bool) {
+		if po.Status != models.PurchaseDraft {
+			return nil, false
+		}
+		return map[string]interface{}{"status": models.PurchaseOrdered, "ordered_at": time.Now()}, true
+	}, "PO berhasil dipesan")
+}
+
+// CancelPurchaseOrder cancels a purchase order that has not received any goods
+func CancelPurchaseOrder(c *gin.Context) {
+	changePurchaseOrderStatus(c, func(po *models.PurchaseOrder) (map[string]interface{}, bool) {
+		if po.Status != models.PurchaseDraft && po.Status != models.PurchaseOrdered {
+			return nil, false
+		}
+		return map[string]interface{}{"status": models.PurchaseCancelled}, true
+	}, "PO berhasil dibatalkan")
+}
+
+// ClosePurchaseOrder ends a partially received purchase order when the supplier
+// will not deliver the rest, so its lines no longer count as outstanding
+func ClosePurchaseOrder(c *gin.Context) {
+	changePurchaseOrderStatus(c, func(po *models.PurchaseOrder) (map[string]interface{}, bool) {
+		if po.Status != models.PurchasePartiallyReceived {
+			return nil, false
+		}
+		return map[string]interface{}{"status": models.PurchaseClosed, "received_at": time.Now()}, true
+	}, "PO berhasil ditutup")
+}
+
+// ReceivePurchaseOrder books received goods into stock at their unit cost.
+// Partial deliveries are allowed; receiving more than ordered is not.
+func ReceivePurchaseOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var req struct {
+		Items []ReceiveItemInput `json:"items" binding:"required,min=1,dive"`
+		Note  string             `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	actor := middleware.GetCurrentUser(c)
+	var po models.PurchaseOrder
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPurchaseOrder(tx, uint(id), &po); err != nil {
+			return err
+		}
+		if !po.CanReceive() {
+			return errPurchaseOrderState
+		}
+
+		var items []models.PurchaseOrderItem
+		if err := tx.Where("purchase_order_id = ?", po.ID).Find(&items).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*models.PurchaseOrderItem, len(items))
+		for i := range items {
+			byID[items[i].ID] = &items[i]
+		}
+
+		note := req.Note
+		if note == "" {
+			note = "Penerimaan " + po.Number
+		}
+
+		for _, in := range req.Items {
+			item, ok := byID[in.ItemID]
+			if !ok {
+				return &purchaseLineError{Message: "Item PO tidak ditemukan"}
+			}
+			if in.Quantity > item.Outstanding() {
+				return &purchaseLineError{Message: "Jumlah diterima melebihi sisa pesanan"}
+			}
+
+			unitCost := item.UnitCost
+			if in.UnitCost != nil {
+				if *in.UnitCost < 0 {
+					return &purchaseLineError{Message: "Harga beli tidak valid"}
+				}
+				unitCost = *in.UnitCost
+			}
+
+			item.ReceivedQuantity += in.Quantity
+			item.UnitCost = unitCost
+			if err := tx.Model(item).Updates(map[string]interface{}{
+				"received_quantity": item.ReceivedQuantity,
+				"unit_cost":         unitCost,
+			}).Error; err != nil {
+				return err
+			}
+
+			if _, err := inventory.Apply(tx, inventory.Change{
+				ProductID:     item.ProductID,
+				VariantID:     item.VariantID,
+				Delta:         in.Quantity,
+				Type:          models.MovementReceiving,
+				Actor:         actor,
+				ReferenceType: models.ReferencePurchaseOrder,
+				ReferenceID:   po.ID,
+				Note:          note,
+				UnitCost:      &unitCost,
+			}); err != nil {
+				return err
+			}
+		}
+
+		status := models.PurchaseReceived
+		for i := range items {
+			if items[i].Outstanding() > 0 {
+				status = models.PurchasePartiallyReceived
+				break
+			}
+		}
+		updates := map[string]interface{}{"status": status}
+		if status == models.PurchaseReceived {
+			updates["received_at"] = time.Now()
+		}
+		return tx.Model(&po).Updates(updates).Error
+	})
+	if err != nil {
+		respondPurchaseOrderError(c, err, "Gagal menerima barang")
+		return
+	}
+
+	preloadPurchaseOrder(database.DB).First(&po, po.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Barang berhasil diterima",
+		"purchase_order": po,
+	})
+}
+
+// GenerateLowStockPurchaseOrders creates one draft purchase order per supplier
+// for the low-stock products and variants that are not already on an open PO.
+// Products without a default supplier are reported back instead.
+func GenerateLowStockPurchaseOrders(c *gin.Context) {
+	var req struct {
+		SupplierID uint `json:"supplier_id"` // Optional: only this supplier
+	}
+	c.ShouldBindJSON(&req)
+
+	candidates, err := lowStockRestockLines(req.SupplierID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat stok menipis"})
+		return
+	}
+
+	bySupplier := make(map[uint][]PurchaseOrderItemInput)
+	var supplierOrder []uint
+	var withoutSupplier []gin.H
+	for _, line := range candidates {
+		if line.SupplierID == nil {
+			withoutSupplier = append(withoutSupplier, gin.H{"product_id": line.ProductID, "variant_id": line.VariantID, "name": line.Name})
+			continue
+		}
+		if _, ok := bySupplier[*line.SupplierID]; !ok {
+			supplierOrder = append(supplierOrder, *line.SupplierID)
+		}
+		bySupplier[*line.SupplierID] = append(bySupplier[*line.SupplierID], PurchaseOrderItemInput{
+			ProductID: line.ProductID,
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+			UnitCost:  line.UnitCost,
+		})
+	}
+
+	user := middleware.GetCurrentUser(c)
+	var created []models.PurchaseOrder
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, supplierID := range supplierOrder {
+			po := models.PurchaseOrder{
+				Number:     models.GeneratePurchaseOrderNumber(),
+				SupplierID: supplierID,
+				Status:     models.PurchaseDraft,
+			}
+			note := "Dibuat otomatis dari daftar stok menipis"
+			po.Notes = &note
+			if user != nil {
+				po.CreatedBy = &user.ID
+			}
+			if err := tx.Create(&po).Error; err != nil {
+				return err
+			}
+			if err := createPurchaseOrderItems(tx, po.ID, bySupplier[supplierID]); err != nil {
+				return err
+			}
+			created = append(created, po)
+		}
+		return nil
+	})
+	if err != nil {
+		respondPurchaseOrderError(c, err, "Gagal membuat PO")
+		return
+	}
+
+	for i := range created {
+		preloadPurchaseOrder(database.DB).First(&created[i], created[i].ID)
+	}
+	if created == nil {
+		created = []models.PurchaseOrder{}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":          "PO draft berhasil dibuat",
+		"purchase_orders":  created,
+		"without_supplier": withoutSupplier,
+	})
+}
+
+// restockLine is a low-stock product or variant with a suggested order quantity
+type restockLine struct {
+	ProductID       uint
+	VariantID       *uint
+	SupplierID      *uint
+	Name            string
+	Stock           int
+	ReorderPoint    int
+	ReorderQuantity int
+	Quantity        int
+	UnitCost        float64
+}
+
+// lowStockRestockLines lists what needs restocking. Products with variants are
+// restocked per variant. The quantity is the product's reorder quantity, and the
+// unit cost is the last received cost.
+func lowStockRestockLines(supplierID uint) ([]restockLine, error) {
+	var lines []restockLine
+
+	productQuery := database.DB.Model(&models.Product{}).
+		Select("products.id AS product_id, products.supplier_id, products.name, products.stock, products.reorder_point, products.reorder_quantity").
+		Scopes(models.LowStock).
+		Where("NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id AND v.deleted_at IS NULL)").
+		Where("NOT "+onOpenPurchaseOrder("NULL"), openPurchaseStatuses)
+	if supplierID != 0 {
+		productQuery = productQuery.Where("products.supplier_id = ?", supplierID)
+	}
+	if err := productQuery.Scan(&lines).Error; err != nil {
+		return nil, err
+	}
+
+	var variantLines []restockLine
+	variantQuery := database.DB.Model(&models.ProductVariant{}).
+		Select("products.id AS product_id, product_variants.id AS variant_id, products.supplier_id, CONCAT(products.name, ' - ', product_variants.name) AS name, product_variants.stock, products.reorder_point, products.reorder_quantity").
+		Joins("JOIN products ON products.id = product_variants.product_id AND products.deleted_at IS NULL").
+		Scopes(models.LowStockVariants).
+		Where("NOT "+onOpenPurchaseOrder("product_variants.id"), openPurchaseStatuses)
+	if supplierID != 0 {
+		variantQuery = variantQuery.Where("products.supplier_id = ?", supplierID)
+	}
+	if err := variantQuery.Scan(&variantLines).Error; err != nil {
+		return nil, err
+	}
+	lines = append(lines, variantLines...)
+
+	for i := range lines {
+		product := models.Product{ReorderPoint: lines[i].ReorderPoint, ReorderQuantity: lines[i].ReorderQuantity}
+		lines[i].Quantity = product.SuggestedReorderQuantity(lines[i].Stock)
+		lines[i].UnitCost = lastReceivedCost(lines[i].ProductID, lines[i].VariantID)
+	}
+	return lines, nil
+}
+
+// onOpenPurchaseOrder matches products (or the variant in variantColumn) that
+// still have goods outstanding on an open purchase order
+func onOpenPurchaseOrder(variantColumn string) string {
+	return "EXISTS (SELECT 1 FROM purchase_order_items poi JOIN purchase_orders po ON po.id = poi.purchase_order_id " +
+		"WHERE po.deleted_at IS NULL AND po.status IN ? AND poi.product_id = products.id " +
+		"AND poi.variant_id <=> " + variantColumn + " AND poi.received_quantity < poi.quantity)"
+}
+
+// lastReceivedCost returns the unit cost of the latest receiving, or 0 if never received
+func lastReceivedCost(productID uint, variantID *uint) float64 {
+	query := database.DB.Model(&models.InventoryMovement{}).
+		Where("product_id = ? AND type = ? AND unit_cost IS NOT NULL", productID, models.MovementReceiving)
+	if variantID != nil {
+		query = query.Where("variant_id = ?", *variantID)
+	} else {
+		query = query.Where("variant_id IS NULL")
+	}
+
+	var costs []float64
+	query.Order("created_at DESC, id DESC").Limit(1).Pluck("unit_cost", &costs)
+	if len(costs) == 0 {
+		return 0
+	}
+	return costs[0]
+}
+
+// createPurchaseOrderItems validates and inserts purchase order lines
+func createPurchaseOrderItems(tx *gorm.DB, purchaseOrderID uint, inputs []PurchaseOrderItemInput) error {
+	for _, in := range inputs {
+		var product models.Product
+		if err := tx.First(&product, in.ProductID).Error; err != nil {
+			return &purchaseLineError{Message: "Produk tidak ditemukan"}
+		}
+
+		var variantCount int64
+		if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount).Error; err != nil {
+			return err
+		}
+		if variantCount > 0 {
+			if in.VariantID == nil {
+				return &purchaseLineError{Message: "Pilih varian untuk " + product.Name}
+			}
+			var variant models.ProductVariant
+			if err := tx.Where("id = ? AND product_id = ?", *in.VariantID, product.ID).First(&variant).Error; err != nil {
+				return &purchaseLineError{Message: "Varian tidak ditemukan"}
+			}
+		} else {
+			in.VariantID = nil
+		}
+
+		item := models.PurchaseOrderItem{
+			PurchaseOrderID: purchaseOrderID,
+			ProductID:       product.ID,
+			VariantID:       in.VariantID,
+			Quantity:        in.Quantity,
+			UnitCost:        in.UnitCost,
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func changePurchaseOrderStatus(c *gin.Context, next func(po *models.PurchaseOrder) (map[string]interface{}, bool), message string) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var po models.PurchaseOrder
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockPurchaseOrder(tx, uint(id), &po); err != nil {
+			return err
+		}
+		updates, ok := next(&po)
+		if !ok {
+			return errPurchaseOrderState
+		}
+		return tx.Model(&po).Updates(updates).Error
+	})
+	if err != nil {
+		respondPurchaseOrderError(c, err, "Gagal memperbarui PO")
+		return
+	}
+
+	preloadPurchaseOrder(database.DB).First(&po, po.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":        message,
+		"purchase_order": po,
+	})
+}
+
+func lockPurchaseOrder(tx *gorm.DB, id uint, po *models.PurchaseOrder) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(po, id).Error
+}
+
+func preloadPurchaseOrder(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Supplier").
+		Preload("Items").
+		Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id, name, slug, stock")
+		}).
+		Preload("Items.Variant", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		})
+}
+
+func respondPurchaseOrderError(c *gin.Context, err error, fallback string) {
+	var lineErr *purchaseLineError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "PO tidak ditemukan"})
+	case errors.Is(err, errPurchaseOrderState):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Status PO tidak memungkinkan aksi ini"})
+	case errors.As(err, &lineErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": lineErr.Message})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
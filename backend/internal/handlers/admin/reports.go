@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ProfitRow is one line of the gross profit report. Revenue is what customers paid
+// for the items; Cost uses the cost snapshot taken at checkout. UncostedRevenue is
+// the part of Revenue from items sold without a known cost, whose profit is overstated.
+type ProfitRow struct {
+	Key             string  `json:"key"`
+	Label           string  `json:"label"`
+	Orders          int64   `json:"orders"`
+	Quantity        int64   `json:"quantity"`
+	Revenue         float64 `json:"revenue"`
+	Discount        float64 `json:"discount"`
+	Cost            float64 `json:"cost"`
+	UncostedRevenue float64 `json:"uncosted_revenue"`
+	GrossProfit     float64 `json:"gross_profit"`
+	MarginPercent   float64 `json:"margin_percent"`
+}
+
+// profitPeriodFormats maps the period grouping to a MySQL DATE_FORMAT pattern
+var profitPeriodFormats = map[string]string{
+	"day":   "%Y-%m-%d",
+	"week":  "%x-W%v",
+	"month": "%Y-%m",
+}
+
+// itemRevenueSQL and the cost columns aggregate order items
+const (
+	itemRevenueSQL  = "SUM(oi.price_at_purchase * oi.quantity)"
+	itemCostSQL     = "SUM(COALESCE(oi.cost_at_purchase, 0) * oi.quantity)"
+	itemUncostedSQL = "SUM(CASE WHEN oi.cost_at_purchase IS NULL THEN oi.price_at_purchase * oi.quantity ELSE 0 END)"
+)
+
+// GetProfitReport returns gross profit of completed, paid orders grouped by order,
+// product, category or period (day, week or month), between from and to (YYYY-MM-DD).
+// Voucher discounts are order-level, so they are only deducted in the order and
+// period groupings.
+func GetProfitReport(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", "period")
+	period := c.DefaultQuery("period", "day")
+
+	from, to, ok := parseReportRange(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format tanggal tidak valid (YYYY-MM-DD)"})
+		return
+	}
+
+	// Completed orders with a verified payment, as on the dashboard revenue
+	items := database.DB.Table("order_items oi").
+		Joins("JOIN orders o ON o.id = oi.order_id AND o.deleted_at IS NULL").
+		Where("o.status = ? AND o.payment_status = ?", models.OrderCompleted, models.PaymentVerified).
+		Where("o.created_at >= ? AND o.created_at < ?", from, to).
+		Session(&gorm.Session{})
+
+	var rows []ProfitRow
+	var err error
+	switch groupBy {
+	case "product":
+		err = items.
+			Select("CAST(oi.product_id AS CHAR) AS `key`, COALESCE(p.name, '-') AS label, COUNT(DISTINCT o.id) AS orders, SUM(oi.quantity) AS quantity, " +
+				itemRevenueSQL + " AS revenue, " + itemCostSQL + " AS cost, " + itemUncostedSQL + " AS uncosted_revenue").
+			Joins("LEFT JOIN products p ON p.id = oi.product_id").
+			Group("oi.product_id, p.name").
+			Order("revenue DESC").
+			Scan(&rows).Error
+	case "category":
+		err = items.
+			Select("CAST(cat.id AS CHAR) AS `key`, COALESCE(cat.name, '-') AS label, COUNT(DISTINCT o.id) AS orders, SUM(oi.quantity) AS quantity, " +
+				itemRevenueSQL + " AS revenue, " + itemCostSQL + " AS cost, " + itemUncostedSQL + " AS uncosted_revenue").
+			Joins("LEFT JOIN products p ON p.id = oi.product_id").
+			Joins("LEFT JOIN categories cat ON cat.id = p.category_id").
+			Group("cat.id, cat.name").
+			Order("revenue DESC").
+			Scan(&rows).Error
+	case "order":
+		err = ordersProfit(items).
+			Select("CAST(t.id AS CHAR) AS `key`, t.order_number AS label, 1 AS orders, t.quantity, t.revenue, t.discount, t.cost, t.uncosted_revenue").
+			Order("t.created_at DESC").
+			Scan(&rows).Error
+	case "period":
+		format, ok := profitPeriodFormats[period]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Periode tidak valid (day, week, month)"})
+			return
+		}
+		err = ordersProfit(items).
+			Select("DATE_FORMAT(t.created_at, ?) AS `key`, COUNT(*) AS orders, SUM(t.quantity) AS quantity, "+
+				"SUM(t.revenue) AS revenue, SUM(t.discount) AS discount, SUM(t.cost) AS cost, SUM(t.uncosted_revenue) AS uncosted_revenue", format).
+			Group("`key`").
+			Order("`key` ASC").
+			Scan(&rows).Error
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pengelompokan tidak valid (order, product, category, period)"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat laporan"})
+		return
+	}
+	if rows == nil {
+		rows = []ProfitRow{}
+	}
+
+	var total ProfitRow
+	total.Key, total.Label = "total", "Total"
+	for i := range rows {
+		rows[i].finish()
+		total.Orders += rows[i].Orders
+		total.Quantity += rows[i].Quantity
+		total.Revenue += rows[i].Revenue
+		total.Discount += rows[i].Discount
+		total.Cost += rows[i].Cost
+		total.UncostedRevenue += rows[i].UncostedRevenue
+	}
+	if groupBy == "product" || groupBy == "category" {
+		// An order with several products is counted once in the total
+		items.Distinct("o.id").Count(&total.Orders)
+	}
+	total.finish()
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     rows,
+		"total":    total,
+		"group_by": groupBy,
+		"from":     from.Format("2006-01-02"),
+		"to":       to.AddDate(0, 0, -1).Format("2006-01-02"),
+	})
+}
+
+// ordersProfit aggregates the items per order, so discounts are counted once per order
+func ordersProfit(items *gorm.DB) *gorm.DB {
+	perOrder := items.
+		Select("o.id, o.order_number, o.created_at, o.discount_amount AS discount, SUM(oi.quantity) AS quantity, " +
+			itemRevenueSQL + " AS revenue, " + itemCostSQL + " AS cost, " + itemUncostedSQL + " AS uncosted_revenue").
+		Group("o.id, o.order_number, o.created_at, o.discount_amount")
+	return database.DB.Table("(?) AS t", perOrder)
+}
+
+// finish computes gross profit and margin after the money columns are filled in
+func (r *ProfitRow) finish() {
+	if r.Label == "" {
+		r.Label = r.Key
+	}
+	net := r.Revenue - r.Discount
+	r.GrossProfit = net - r.Cost
+	r.MarginPercent = 0
+	if net > 0 {
+		r.MarginPercent = math.Round(r.GrossProfit/net*10000) / 100
+	}
+}
+
+// parseReportRange reads the from/to dates (inclusive), defaulting to the last 30 days.
+// The returned to is exclusive.
+func parseReportRange(c *gin.Context) (from, to time.Time, ok bool) {
+	from, to, ok = parseDateFilter(c)
+	if !ok {
+		return from, to, false
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if to.IsZero() {
+		to = today.AddDate(0, 0, 1)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+	}
+	return from, to, true
+}
+
+// parseDateFilter reads the optional from/to dates (YYYY-MM-DD, inclusive).
+// A missing date is returned as the zero time; the returned to is exclusive.
+func parseDateFilter(c *gin.Context) (from, to time.Time, ok bool) {
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			return from, to, false
+		}
+		from = parsed
+	}
+	if raw := c.Query("to"); raw != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			return from, to, false
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	return from, to, true
+}
@@ -0,0 +1,130 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SupplierRequest represents the create/update supplier request
+type SupplierRequest struct {
+	Name        string `json:"name" binding:"required,min=2,max=255"`
+	ContactName string `json:"contact_name"`
+	Phone       string `json:"phone"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	Address     string `json:"address"`
+	Notes       string `json:"notes"`
+}
+
+// ListSuppliers returns all suppliers
+func ListSuppliers(c *gin.Context) {
+	var suppliers []models.Supplier
+	database.DB.Order("name ASC").Find(&suppliers)
+
+	c.JSON(http.StatusOK, gin.H{"data": suppliers})
+}
+
+// CreateSupplier creates a supplier
+func CreateSupplier(c *gin.Context) {
+	var req SupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	supplier := models.Supplier{}
+	applySupplierRequest(&supplier, &req)
+
+	if err := database.DB.Create(&supplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat supplier"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":  "Supplier berhasil dibuat",
+		"supplier": supplier,
+	})
+}
+
+// UpdateSupplier updates a supplier
+func UpdateSupplier(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier tidak ditemukan"})
+		return
+	}
+
+	var req SupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	applySupplierRequest(&supplier, &req)
+	if err := database.DB.Save(&supplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui supplier"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Supplier berhasil diperbarui",
+		"supplier": supplier,
+	})
+}
+
+// DeleteSupplier deletes a supplier; products keep no default supplier
+func DeleteSupplier(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := database.DB.First(&supplier, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier tidak ditemukan"})
+		return
+	}
+
+	var openOrders int64
+	database.DB.Model(&models.PurchaseOrder{}).
+		Where("supplier_id = ? AND status IN ?", supplier.ID, []models.PurchaseOrderStatus{models.PurchaseOrdered, models.PurchasePartiallyReceived}).
+		Count(&openOrders)
+	if openOrders > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Supplier masih memiliki PO yang belum selesai"})
+		return
+	}
+
+	database.DB.Model(&models.Product{}).Where("supplier_id = ?", supplier.ID).Update("supplier_id", nil)
+	database.DB.Delete(&supplier)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier berhasil dihapus"})
+}
+
+func applySupplierRequest(supplier *models.Supplier, req *SupplierRequest) {
+	optional := func(value string) *string {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil
+		}
+		return &value
+	}
+
+	supplier.Name = strings.TrimSpace(req.Name)
+	supplier.ContactName = optional(req.ContactName)
+	supplier.Phone = optional(req.Phone)
+	supplier.Email = optional(req.Email)
+	supplier.Address = optional(req.Address)
+	supplier.Notes = optional(req.Notes)
+}
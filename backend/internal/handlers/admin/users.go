@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListLockedUsers returns the accounts that are locked after failed logins
+func ListLockedUsers(c *gin.Context) {
+	var users []models.User
+	database.DB.
+		Select("id, name, email, role, locked_until, last_failed_login_at").
+		Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&users)
+
+	data := make([]gin.H, 0, len(users))
+	for _, u := range users {
+		data = append(data, gin.H{
+			"id":                   u.ID,
+			"name":                 u.Name,
+			"email":                u.Email,
+			"role":                 u.Role,
+			"locked_until":         u.LockedUntil,
+			"last_failed_login_at": u.LastFailedLoginAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// UnlockUser ends the lockout of an account and clears its failed login counter
+func UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User tidak ditemukan"})
+		return
+	}
+
+	if err := models.ResetLoginFailures(database.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka kunci akun"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kunci akun berhasil dibuka",
+		"user": gin.H{
+			"id":    user.ID,
+			"name":  user.Name,
+			"email": user.Email,
+		},
+	})
+}
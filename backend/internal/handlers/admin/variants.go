@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gsm-motor/internal/inventory"
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// VariantInput is one entry of the "variants" JSON form field on product create/update.
+// Entries with an ID update that variant; entries without one create a new variant.
+type VariantInput struct {
+	ID          uint     `json:"id"`
+	SKU         string   `json:"sku"`
+	Name        string   `json:"name"`
+	Size        *string  `json:"size"`
+	Color       *string  `json:"color"`
+	Fitment     *string  `json:"fitment"`
+	Price       float64  `json:"price"`
+	Price3Items *float64 `json:"price_3_items"`
+	Price5Items *float64 `json:"price_5_items"`
+	CostPrice   *float64 `json:"cost_price"`
+	Stock       int      `json:"stock"`
+	Weight      int      `json:"weight"`
+}
+
+// parseVariantsField decodes the "variants" form field. present is false when the
+// field was not sent, so updates that do not touch variants leave them alone.
+// The returned message is customer-facing and empty when the input is valid.
+func parseVariantsField(raw string) (inputs []VariantInput, present bool, msg string) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, false, ""
+	}
+	if err := json.Unmarshal([]byte(raw), &inputs); err != nil {
+		return nil, true, "Format varian tidak valid"
+	}
+
+	seen := make(map[string]bool, len(inputs))
+	for i := range inputs {
+		v := &inputs[i]
+		v.SKU = strings.TrimSpace(v.SKU)
+		v.Name = strings.TrimSpace(v.Name)
+		if v.SKU == "" || v.Name == "" {
+			return nil, true, "SKU dan nama varian wajib diisi"
+		}
+		if v.Price <= 0 {
+			return nil, true, "Harga varian " + v.SKU + " wajib diisi"
+		}
+		if v.CostPrice != nil && *v.CostPrice < 0 {
+			return nil, true, "Harga modal varian " + v.SKU + " tidak boleh negatif"
+		}
+		if v.Stock < 0 {
+			return nil, true, "Stok varian " + v.SKU + " tidak boleh negatif"
+		}
+		key := strings.ToUpper(v.SKU)
+		if seen[key] {
+			return nil, true, "SKU " + v.SKU + " duplikat"
+		}
+		seen[key] = true
+	}
+	return inputs, true, ""
+}
+
+// SKUConflictError is returned when a SKU is already used by another variant
+type SKUConflictError struct {
+	SKU string
+}
+
+func (e *SKUConflictError) Error() string {
+	return fmt.Sprintf("sku %q is already in use", e.SKU)
+}
+
+// replaceProductVariants makes the product's variants match inputs: listed variants
+// are updated or created and the rest are deleted. Stock changes are recorded in
+// the inventory ledger, price changes in the price history, and the product's stock
+// and price are synced from the variants.
+func replaceProductVariants(tx *gorm.DB, product *models.Product, inputs []VariantInput, actor *models.User) error {
+	var existing []models.ProductVariant
+	if err := tx.Where("product_id = ?", product.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+
+	// When a product is first split into variants its own stock moves to them
+	if len(existing) == 0 && len(inputs) > 0 {
+		if _, err := inventory.Set(tx, product.ID, nil, 0, models.MovementAdjustment, actor, "Stok dipindahkan ke varian"); err != nil {
+			return err
+		}
+	}
+	byID := make(map[uint]*models.ProductVariant, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+	}
+
+	keep := make(map[uint]bool, len(inputs))
+	for _, in := range inputs {
+		// SKUs are unique across all products and variants, including deleted ones
+		inUse, err := models.SKUInUse(tx, in.SKU, 0, in.ID)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return &SKUConflictError{SKU: in.SKU}
+		}
+
+		variant := &models.ProductVariant{ProductID: product.ID}
+		entry := manualPriceChange(actor, product.ID, nil)
+		if in.ID != 0 {
+			found, ok := byID[in.ID]
+			if !ok {
+				return fmt.Errorf("variant %d does not belong to product %d", in.ID, product.ID)
+			}
+			variant = found
+			oldPrice := found.Price
+			entry.OldPrice, entry.OldPrice3Items, entry.OldPrice5Items = &oldPrice, found.Price3Items, found.Price5Items
+		}
+
+		variant.SKU = in.SKU
+		variant.Name = in.Name
+		variant.Size = in.Size
+		variant.Color = in.Color
+		variant.Fitment = in.Fitment
+		variant.Price = in.Price
+		variant.Price3Items = in.Price3Items
+		variant.Price5Items = in.Price5Items
+		variant.CostPrice = in.CostPrice
+		variant.Weight = in.Weight
+		if variant.Weight <= 0 {
+			variant.Weight = product.Weight
+		}
+
+		if err := tx.Omit("stock").Save(variant).Error; err != nil {
+			return err
+		}
+		variantID := variant.ID
+		entry.VariantID = &variantID
+		entry.NewPrice, entry.NewPrice3Items, entry.NewPrice5Items = variant.Price, variant.Price3Items, variant.Price5Items
+		if err := models.RecordPriceChange(tx, entry); err != nil {
+			return err
+		}
+		if _, err := inventory.Set(tx, product.ID, &variant.ID, in.Stock, models.MovementAdjustment, actor, "Penyesuaian stok varian"); err != nil {
+			return err
+		}
+		keep[variant.ID] = true
+	}
+
+	// Removed variants leave the ledger at zero before they are deleted
+	for _, v := range existing {
+		if keep[v.ID] {
+			continue
+		}
+		variantID := v.ID
+		if _, err := inventory.Set(tx, product.ID, &variantID, 0, models.MovementAdjustment, actor, "Varian dihapus"); err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.ProductVariant{}, v.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := models.SyncProductFromVariants(tx, product.ID); err != nil {
+		return err
+	}
+	return tx.Preload("Variants").First(product, product.ID).Error
+}
@@ -0,0 +1,219 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// VehicleRequest represents the create/update vehicle request
+type VehicleRequest struct {
+	Brand     string `json:"brand" binding:"required,max=100"`
+	Model     string `json:"model" binding:"required,max=150"`
+	YearStart int    `json:"year_start" binding:"required,min=1950,max=2100"`
+	YearEnd   *int   `json:"year_end"`
+	EngineCC  *int   `json:"engine_cc"`
+}
+
+// VehicleWithCount is a catalog entry with the number of parts that fit it
+type VehicleWithCount struct {
+	models.Vehicle
+	ProductCount int64 `json:"product_count"`
+}
+
+// AdminListVehicles returns the fitment catalog with product counts
+func AdminListVehicles(c *gin.Context) {
+	query := database.DB.Model(&models.Vehicle{}).
+		Select("vehicles.*, (SELECT COUNT(*) FROM product_fitments pf WHERE pf.vehicle_id = vehicles.id) AS product_count")
+
+	for _, term := range strings.Fields(c.Query("search")) {
+		query = query.Where("CONCAT(brand, ' ', model) LIKE ?", "%"+term+"%")
+	}
+
+	var vehicles []VehicleWithCount
+	query.Order("brand ASC, model ASC, year_start ASC").Find(&vehicles)
+
+	c.JSON(http.StatusOK, gin.H{"data": vehicles})
+}
+
+// CreateVehicle adds a vehicle to the fitment catalog
+func CreateVehicle(c *gin.Context) {
+	var req VehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	if req.YearEnd != nil && *req.YearEnd < req.YearStart {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun akhir harus setelah tahun awal"})
+		return
+	}
+
+	vehicle := models.Vehicle{}
+	applyVehicleRequest(&vehicle, &req)
+
+	var existing models.Vehicle
+	if err := database.DB.Unscoped().
+		Where("brand = ? AND model = ? AND year_start = ?", vehicle.Brand, vehicle.Model, vehicle.YearStart).
+		First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kendaraan sudah ada"})
+		return
+	}
+
+	if err := database.DB.Create(&vehicle).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat kendaraan"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Kendaraan berhasil dibuat",
+		"vehicle": vehicle,
+	})
+}
+
+// UpdateVehicle updates a catalog vehicle
+func UpdateVehicle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := database.DB.First(&vehicle, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kendaraan tidak ditemukan"})
+		return
+	}
+
+	var req VehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	if req.YearEnd != nil && *req.YearEnd < req.YearStart {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun akhir harus setelah tahun awal"})
+		return
+	}
+
+	applyVehicleRequest(&vehicle, &req)
+
+	var existing models.Vehicle
+	if err := database.DB.Unscoped().
+		Where("brand = ? AND model = ? AND year_start = ? AND id != ?", vehicle.Brand, vehicle.Model, vehicle.YearStart, vehicle.ID).
+		First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kendaraan sudah ada"})
+		return
+	}
+
+	if err := database.DB.Save(&vehicle).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui kendaraan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Kendaraan berhasil diperbarui",
+		"vehicle": vehicle,
+	})
+}
+
+// DeleteVehicle removes a vehicle with its fitments and saved customer bikes
+func DeleteVehicle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := database.DB.First(&vehicle, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kendaraan tidak ditemukan"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&vehicle).Association("Products").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Where("vehicle_id = ?", vehicle.ID).Delete(&models.UserVehicle{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&vehicle).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus kendaraan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Kendaraan berhasil dihapus"})
+}
+
+// UpdateProductFitments replaces the list of vehicles a product fits
+func UpdateProductFitments(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var product models.Product
+	if err := database.DB.First(&product, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Produk tidak ditemukan"})
+		return
+	}
+
+	var req struct {
+		VehicleIDs []uint `json:"vehicle_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid"})
+		return
+	}
+
+	var vehicles []models.Vehicle
+	if len(req.VehicleIDs) > 0 {
+		database.DB.Where("id IN ?", req.VehicleIDs).Find(&vehicles)
+		if len(vehicles) != len(uniqueIDs(req.VehicleIDs)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Beberapa kendaraan tidak ditemukan"})
+			return
+		}
+	}
+
+	association := database.DB.Model(&product).Association("Vehicles")
+	if len(vehicles) == 0 {
+		err = association.Clear()
+	} else {
+		err = association.Replace(vehicles)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan kecocokan kendaraan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Kecocokan kendaraan berhasil diperbarui",
+		"vehicles": vehicles,
+	})
+}
+
+func applyVehicleRequest(vehicle *models.Vehicle, req *VehicleRequest) {
+	vehicle.Brand = strings.TrimSpace(req.Brand)
+	vehicle.Model = strings.TrimSpace(req.Model)
+	vehicle.YearStart = req.YearStart
+	vehicle.YearEnd = req.YearEnd
+	vehicle.EngineCC = req.EngineCC
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
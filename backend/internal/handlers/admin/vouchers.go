@@ -0,0 +1,221 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VoucherRequest represents the create/update voucher request
+type VoucherRequest struct {
+	Code         string     `json:"code" binding:"required,min=3,max=50"`
+	Description  string     `json:"description"`
+	Type         string     `json:"type" binding:"required,oneof=percentage fixed"`
+	Value        float64    `json:"value" binding:"required,gt=0"`
+	MaxDiscount  *float64   `json:"max_discount"`
+	MinSpend     float64    `json:"min_spend" binding:"min=0"`
+	UsageLimit   *int       `json:"usage_limit"`
+	PerUserLimit *int       `json:"per_user_limit"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	IsActive     *bool      `json:"is_active"`
+	CategoryIDs  []uint     `json:"category_ids"`
+	ProductIDs   []uint     `json:"product_ids"`
+}
+
+// ListVouchers returns all vouchers
+func ListVouchers(c *gin.Context) {
+	var vouchers []models.Voucher
+	database.DB.
+		Preload("Categories").
+		Preload("Products", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name, slug")
+		}).
+		Order("created_at DESC").
+		Find(&vouchers)
+
+	c.JSON(http.StatusOK, gin.H{"data": vouchers})
+}
+
+// CreateVoucher creates a new voucher
+func CreateVoucher(c *gin.Context) {
+	var req VoucherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	if msg := validateVoucherRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var existing models.Voucher
+	if err := database.DB.Unscoped().Where("code = ?", models.NormalizeVoucherCode(req.Code)).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kode voucher sudah digunakan"})
+		return
+	}
+
+	voucher := models.Voucher{IsActive: true}
+	applyVoucherRequest(&voucher, &req)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&voucher).Error; err != nil {
+			return err
+		}
+		return replaceVoucherRestrictions(tx, &voucher, &req)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat voucher"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Voucher berhasil dibuat",
+		"voucher": voucher,
+	})
+}
+
+// UpdateVoucher updates a voucher
+func UpdateVoucher(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var voucher models.Voucher
+	if err := database.DB.First(&voucher, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Voucher tidak ditemukan"})
+		return
+	}
+
+	var req VoucherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+
+	if msg := validateVoucherRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var existing models.Voucher
+	if err := database.DB.Unscoped().
+		Where("code = ? AND id != ?", models.NormalizeVoucherCode(req.Code), voucher.ID).
+		First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kode voucher sudah digunakan"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Re-read under lock: Save writes every column, and a redemption committed
+		// since the read above must not lose its used_count increment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&voucher, voucher.ID).Error; err != nil {
+			return err
+		}
+		applyVoucherRequest(&voucher, &req)
+		if err := tx.Save(&voucher).Error; err != nil {
+			return err
+		}
+		return replaceVoucherRestrictions(tx, &voucher, &req)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui voucher"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Voucher berhasil diperbarui",
+		"voucher": voucher,
+	})
+}
+
+// DeleteVoucher deletes a voucher; orders that used it keep their discount
+func DeleteVoucher(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var voucher models.Voucher
+	if err := database.DB.First(&voucher, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Voucher tidak ditemukan"})
+		return
+	}
+
+	database.DB.Delete(&voucher)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Voucher berhasil dihapus"})
+}
+
+// validateVoucherRequest returns a customer-facing message for invalid combinations
+func validateVoucherRequest(req *VoucherRequest) string {
+	if req.Type == string(models.VoucherPercentage) && req.Value > 100 {
+		return "Persentase diskon maksimal 100"
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return "Tanggal berakhir harus setelah tanggal mulai"
+	}
+	if req.UsageLimit != nil && *req.UsageLimit < 1 {
+		return "Batas penggunaan minimal 1"
+	}
+	if req.PerUserLimit != nil && *req.PerUserLimit < 1 {
+		return "Batas penggunaan per pelanggan minimal 1"
+	}
+	return ""
+}
+
+func applyVoucherRequest(voucher *models.Voucher, req *VoucherRequest) {
+	voucher.Code = models.NormalizeVoucherCode(req.Code)
+	voucher.Type = models.VoucherType(req.Type)
+	voucher.Value = req.Value
+	voucher.MaxDiscount = req.MaxDiscount
+	voucher.MinSpend = req.MinSpend
+	voucher.UsageLimit = req.UsageLimit
+	voucher.PerUserLimit = req.PerUserLimit
+	voucher.StartsAt = req.StartsAt
+	voucher.EndsAt = req.EndsAt
+	voucher.Description = nil
+	if req.Description != "" {
+		voucher.Description = &req.Description
+	}
+	if req.IsActive != nil {
+		voucher.IsActive = *req.IsActive
+	}
+}
+
+// replaceVoucherRestrictions sets the voucher's category and product restrictions
+func replaceVoucherRestrictions(tx *gorm.DB, voucher *models.Voucher, req *VoucherRequest) error {
+	if len(req.CategoryIDs) == 0 {
+		if err := tx.Model(voucher).Association("Categories").Clear(); err != nil {
+			return err
+		}
+	} else {
+		var categories []models.Category
+		if err := tx.Where("id IN ?", req.CategoryIDs).Find(&categories).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(voucher).Association("Categories").Replace(categories); err != nil {
+			return err
+		}
+	}
+
+	if len(req.ProductIDs) == 0 {
+		return tx.Model(voucher).Association("Products").Clear()
+	}
+	var products []models.Product
+	if err := tx.Where("id IN ?", req.ProductIDs).Find(&products).Error; err != nil {
+		return err
+	}
+	return tx.Model(voucher).Association("Products").Replace(products)
+}
@@ -0,0 +1,413 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	emailChangeTTL         = 10 * time.Minute
+	emailChangeInterval    = time.Minute // Minimum time between codes sent for an email change
+	maxEmailChangeAttempts = 5
+)
+
+var (
+	// errEmailChangeInvalid is returned when there is no usable pending email change
+	errEmailChangeInvalid = errors.New("no pending email change")
+	// errEmailTaken is returned when the new email belongs to another account
+	errEmailTaken = errors.New("email already registered")
+)
+
+// SendSecurityCode emails a code to the current user's address. Accounts
+// created with Google have no password, so they confirm setting one or
+// changing their email with this code instead.
+func SendSecurityCode(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Rate limit: check if last OTP was sent less than 1 minute ago
+	if user.OTPExpiresAt != nil {
+		lastSent := user.OTPExpiresAt.Add(-10 * time.Minute) // OTP expires in 10 min, so last sent = expiry - 10 min
+		if time.Since(lastSent) < time.Minute {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Mohon tunggu 1 menit sebelum meminta kode baru"})
+			return
+		}
+	}
+
+	otpCode, err := utils.GenerateOTP()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat kode OTP"})
+		return
+	}
+	otpExpiry := time.Now().Add(10 * time.Minute)
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"otp_code":       otpCode,
+		"otp_expires_at": otpExpiry,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan kode OTP"})
+		return
+	}
+
+	go utils.SendOTPEmail(user.Email, otpCode, user.Name)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Kode verifikasi telah dikirim ke email Anda"})
+}
+
+// verifySecurityCode checks and spends a code from SendSecurityCode. Wrong
+// codes count against the account like failed logins. It writes the error
+// response and returns false when the code is not accepted.
+func verifySecurityCode(c *gin.Context, user *models.User, code string) bool {
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode verifikasi dari email wajib diisi", "requires_otp": true})
+		return false
+	}
+	if wait := loginRetryAfter(user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return false
+	}
+	if user.OTPCode == nil || subtle.ConstantTimeCompare([]byte(*user.OTPCode), []byte(code)) != 1 {
+		if wait, locked := recordLoginFailure(user); locked {
+			respondLoginLocked(c, wait, true)
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode OTP salah"})
+		return false
+	}
+	if user.OTPExpiresAt == nil || time.Now().After(*user.OTPExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode OTP sudah kadaluarsa. Silakan minta kode baru."})
+		return false
+	}
+	// A code confirms one change only
+	if err := database.DB.Model(user).Updates(map[string]interface{}{"otp_code": nil, "otp_expires_at": nil}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memverifikasi kode"})
+		return false
+	}
+	if wait := resetLoginFailures(user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return false
+	}
+	return true
+}
+
+// verifyCurrentPassword re-checks the password of a logged in user before a
+// sensitive change. Wrong passwords count against the account like failed
+// logins. It writes the error response and returns false when the password is
+// not accepted.
+func verifyCurrentPassword(c *gin.Context, user *models.User, password, wrongMsg string) bool {
+	if wait := loginRetryAfter(user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return false
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(password)); err != nil {
+		if wait, locked := recordLoginFailure(user); locked {
+			respondLoginLocked(c, wait, true)
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": wrongMsg})
+		return false
+	}
+	if wait := resetLoginFailures(user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return false
+	}
+	return true
+}
+
+// ChangePasswordRequest represents the change password request. Accounts
+// created with Google have no password yet; they set one with a code from
+// SendSecurityCode instead of current_password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	OTP             string `json:"otp"`
+	Password        string `json:"password" binding:"required,min=6"`
+	ConfirmPassword string `json:"confirm_password" binding:"required"`
+}
+
+// ChangePassword changes the current user's password, or sets a first local
+// password for accounts created with Google. Either way every other device is
+// logged out.
+func ChangePassword(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+	if req.Password != req.ConfirmPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password tidak cocok"})
+		return
+	}
+
+	hadPassword := user.Password != nil
+	if hadPassword {
+		if req.CurrentPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password saat ini wajib diisi"})
+			return
+		}
+		if !verifyCurrentPassword(c, user, req.CurrentPassword, "Password saat ini salah") {
+			return
+		}
+		if req.Password == req.CurrentPassword {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password baru harus berbeda dari password saat ini"})
+			return
+		}
+	} else if !verifySecurityCode(c, user, req.OTP) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses password"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password":       string(hashedPassword),
+			"otp_code":       nil,
+			"otp_expires_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		// A reset link requested before the change must not undo it
+		if err := tx.Where("email = ?", user.Email).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		if _, err := models.RevokeUserSessions(tx, user.ID, models.SessionPasswordChange, c.GetString("session_id")); err != nil {
+			return err
+		}
+		return models.RevokeLegacyTokens(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan password"})
+		return
+	}
+
+	go utils.SendPasswordChangedEmail(user.Email, user.Name)
+
+	if !hadPassword {
+		c.JSON(http.StatusOK, gin.H{"message": "Password berhasil dibuat. Anda sekarang juga dapat login dengan email dan password. Perangkat lain telah dikeluarkan."})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password berhasil diubah. Perangkat lain telah dikeluarkan."})
+}
+
+// ChangeEmailRequest represents the change email request. The current password
+// is required for accounts that have one, otherwise a code from SendSecurityCode.
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password"`
+	OTP      string `json:"otp"`
+}
+
+// RequestEmailChange sends a code to the new email address. The account keeps
+// its current email until the code is verified.
+func RequestEmailChange(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email tidak valid"})
+		return
+	}
+
+	if user.Password != nil {
+		if req.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Password wajib diisi"})
+			return
+		}
+		if !verifyCurrentPassword(c, user, req.Password, "Password salah") {
+			return
+		}
+	} else if !verifySecurityCode(c, user, req.OTP) {
+		return
+	}
+
+	newEmail := strings.ToLower(req.NewEmail)
+	if newEmail == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email baru sama dengan email saat ini"})
+		return
+	}
+	if taken, err := emailTaken(database.DB, newEmail); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa email"})
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email sudah terdaftar"})
+		return
+	}
+
+	var previous models.EmailChangeRequest
+	if err := database.DB.Where("user_id = ?", user.ID).Take(&previous).Error; err == nil {
+		if time.Since(previous.CreatedAt) < emailChangeInterval {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Mohon tunggu 1 menit sebelum meminta kode baru"})
+			return
+		}
+	}
+
+	otpCode, err := utils.GenerateOTP()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat kode OTP"})
+		return
+	}
+
+	request := models.EmailChangeRequest{
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		CodeHash:  models.HashSecret(otpCode),
+		ExpiresAt: time.Now().Add(emailChangeTTL),
+		CreatedAt: time.Now(),
+	}
+	if err := database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan kode OTP"})
+		return
+	}
+
+	go func() {
+		if err := utils.SendEmailChangeOTP(newEmail, otpCode, user.Name); err != nil {
+			log.Printf("Failed to send email change code to %s: %v", newEmail, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Kode verifikasi telah dikirim ke email baru Anda",
+		"new_email":  request.NewEmail,
+		"expires_at": request.ExpiresAt,
+	})
+}
+
+// VerifyEmailChangeRequest represents the verify email change request
+type VerifyEmailChangeRequest struct {
+	OTP string `json:"otp" binding:"required,len=6"`
+}
+
+// VerifyEmailChange moves the account to the new email once the code sent to it
+// is entered. The previous address is told about the change.
+func VerifyEmailChange(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req VerifyEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid"})
+		return
+	}
+
+	// Wrong codes count against the account like failed logins
+	if wait := loginRetryAfter(user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return
+	}
+
+	oldEmail := user.Email
+	var newEmail string
+	matched := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var request models.EmailChangeRequest
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", user.ID).Take(&request).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errEmailChangeInvalid
+			}
+			return err
+		}
+		if !request.ExpiresAt.After(time.Now()) || request.Attempts >= maxEmailChangeAttempts {
+			return errEmailChangeInvalid
+		}
+
+		matched = subtle.ConstantTimeCompare([]byte(models.HashSecret(req.OTP)), []byte(request.CodeHash)) == 1
+		if !matched {
+			// Committed, so guessing the code runs out of attempts
+			return tx.Model(&request).UpdateColumn("attempts", gorm.Expr("attempts + 1")).Error
+		}
+
+		// The address may have been registered since the code was sent
+		taken, err := emailTaken(tx, request.NewEmail)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return errEmailTaken
+		}
+
+		newEmail = request.NewEmail
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"email":             newEmail,
+			"email_verified_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("email = ?", oldEmail).Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&request).Error
+	})
+	if errors.Is(err, errEmailChangeInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode OTP sudah kadaluarsa. Silakan minta kode baru."})
+		return
+	}
+	if errors.Is(err, errEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email sudah terdaftar"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengubah email"})
+		return
+	}
+	if !matched {
+		if wait, locked := recordLoginFailure(user); locked {
+			respondLoginLocked(c, wait, true)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode OTP salah"})
+		return
+	}
+	// The change is already saved; a wait set meanwhile just stays in place
+	resetLoginFailures(user)
+
+	go utils.SendEmailChangedEmail(oldEmail, newEmail, user.Name)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email berhasil diubah",
+		"user": gin.H{
+			"id":    user.ID,
+			"name":  user.Name,
+			"email": newEmail,
+			"role":  user.Role,
+		},
+	})
+}
+
+// emailTaken reports whether an account, including a deleted one, uses the email
+func emailTaken(db *gorm.DB, email string) (bool, error) {
+	var count int64
+	err := db.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error
+	return count > 0, err
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -119,10 +120,24 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Slow down guessing against this account, whichever IPs it comes from
+	if wait := loginRetryAfter(&user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return
+	}
+
 	if err := bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.Password)); err != nil {
+		if wait, locked := recordLoginFailure(&user); locked {
+			respondLoginLocked(c, wait, true)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email atau password salah"})
 		return
 	}
+	if wait := resetLoginFailures(&user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return
+	}
 
 	// Check if email is verified
 	if user.EmailVerifiedAt == nil {
@@ -142,19 +157,13 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Generate tokens
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, string(user.Role))
+	// Generate tokens for a new session
+	accessToken, refreshToken, err := startSession(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat refresh token"})
-		return
-	}
-
 	// Set cookies
 	setAuthCookies(c, accessToken, refreshToken)
 
@@ -171,16 +180,55 @@ func Login(c *gin.Context) {
 	})
 }
 
-// Logout handles user logout
+// Logout revokes the session of the presented tokens and clears the cookies
 func Logout(c *gin.Context) {
+	if claims := presentedClaims(c); claims != nil && claims.SessionID != "" {
+		if _, err := models.RevokeSessionFamily(database.DB, claims.UserID, claims.SessionID, models.SessionLogout); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal logout"})
+			return
+		}
+	}
+
 	// Clear cookies
-	c.SetCookie("access_token", "", -1, "/", "", false, true)
-	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+	clearAuthCookies(c)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Logout berhasil"})
 }
 
-// RefreshToken handles token refresh
+// presentedClaims returns the claims of the refresh token, from the cookie or
+// body, or else of the access token, from the cookie or Authorization header
+func presentedClaims(c *gin.Context) *utils.Claims {
+	type candidate struct {
+		token string
+		typ   utils.TokenType
+	}
+	var candidates []candidate
+	if cookie, err := c.Cookie("refresh_token"); err == nil && cookie != "" {
+		candidates = append(candidates, candidate{cookie, utils.TokenRefresh})
+	}
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength != 0 && c.ShouldBindJSON(&req) == nil && req.RefreshToken != "" {
+		candidates = append(candidates, candidate{req.RefreshToken, utils.TokenRefresh})
+	}
+	if cookie, err := c.Cookie("access_token"); err == nil && cookie != "" {
+		candidates = append(candidates, candidate{cookie, utils.TokenAccess})
+	}
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		candidates = append(candidates, candidate{strings.TrimPrefix(header, "Bearer "), utils.TokenAccess})
+	}
+
+	for _, candidate := range candidates {
+		if claims, err := utils.ParseToken(candidate.token, candidate.typ); err == nil {
+			return claims
+		}
+	}
+	return nil
+}
+
+// RefreshToken rotates a refresh token: the presented token is spent and a new
+// access and refresh token of the same session are returned
 func RefreshToken(c *gin.Context) {
 	// Try to get refresh token from cookie
 	refreshToken, err := c.Cookie("refresh_token")
@@ -197,32 +245,23 @@ func RefreshToken(c *gin.Context) {
 	}
 
 	// Parse refresh token
-	claims, err := utils.ParseToken(refreshToken)
+	claims, err := utils.ParseToken(refreshToken, utils.TokenRefresh)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token tidak valid"})
 		return
 	}
 
-	// Get user
-	var user models.User
-	if err := database.DB.First(&user, claims.UserID).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User tidak ditemukan"})
+	newAccessToken, newRefreshToken, err := rotateSession(c, claims)
+	if errors.Is(err, errSessionInvalid) || errors.Is(err, errSessionReused) {
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesi telah berakhir. Silakan login kembali."})
 		return
 	}
-
-	// Generate new tokens
-	newAccessToken, err := utils.GenerateAccessToken(user.ID, user.Email, string(user.Role))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
 		return
 	}
 
-	newRefreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat refresh token"})
-		return
-	}
-
 	// Set cookies
 	setAuthCookies(c, newAccessToken, newRefreshToken)
 
@@ -255,6 +294,7 @@ func GetMe(c *gin.Context) {
 			"postal_code":    u.PostalCode,
 			"address_detail": u.AddressDetail,
 			"has_address":    u.HasCompleteAddress(),
+			"has_password":   u.Password != nil,
 		},
 	})
 }
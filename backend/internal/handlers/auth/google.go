@@ -12,7 +12,6 @@ import (
 	"gsm-motor/internal/config"
 	"gsm-motor/internal/database"
 	"gsm-motor/internal/models"
-	"gsm-motor/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/oauth2"
@@ -126,19 +125,13 @@ func GoogleCallback(c *gin.Context) {
 		}
 	}
 
-	// Generate tokens
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, string(user.Role))
+	// Generate tokens for a new session
+	accessToken, refreshToken, err := startSession(c, &user)
 	if err != nil {
 		c.Redirect(http.StatusTemporaryRedirect, "/login?error=token_gen")
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.Redirect(http.StatusTemporaryRedirect, "/login?error=refresh_token")
-		return
-	}
-
 	// Set cookies
 	setAuthCookies(c, accessToken, refreshToken)
 
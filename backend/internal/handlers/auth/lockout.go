@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginRetryAfter returns how long the user has to wait before their password
+// or OTP is checked again, or zero when it can be checked now
+func loginRetryAfter(user *models.User) time.Duration {
+	if user.LockedUntil == nil {
+		return 0
+	}
+	return max(time.Until(*user.LockedUntil), 0)
+}
+
+// recordLoginFailure counts a wrong password or OTP for the account. After the
+// free attempts each further failure makes the next check wait twice as long,
+// and reaching the maximum locks the account and emails the user. It returns
+// the wait and whether the account is now locked.
+//
+// The check that failed may have started before another request set a wait;
+// such failures are not counted again, so a burst of parallel guesses cannot
+// start the count over or lift the wait.
+func recordLoginFailure(user *models.User) (time.Duration, bool) {
+	cfg := config.AppConfig
+	lockout := time.Duration(cfg.LoginLockoutMinutes) * time.Minute
+
+	var wait time.Duration
+	locked := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, failed_login_attempts, last_failed_login_at, locked_until").
+			First(&current, user.ID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if wait = loginRetryAfter(&current); wait > 0 {
+			return nil
+		}
+
+		attempts := current.FailedLoginAttempts
+		// Failures spread out over time are typos, not guessing. The time is
+		// counted from the end of the last wait, so the count survives a lockout
+		// and the next failure right after it locks the account again.
+		since := current.LastFailedLoginAt
+		if current.LockedUntil != nil && (since == nil || current.LockedUntil.After(*since)) {
+			since = current.LockedUntil
+		}
+		if since != nil && now.Sub(*since) > lockout {
+			attempts = 0
+		}
+		attempts++
+
+		updates := map[string]interface{}{
+			"failed_login_attempts": attempts,
+			"last_failed_login_at":  now,
+		}
+		switch {
+		case attempts >= cfg.LoginMaxAttempts:
+			wait, locked = lockout, true
+			// A pending OTP is void
+			updates["otp_code"] = nil
+			updates["otp_expires_at"] = nil
+		case attempts > cfg.LoginFreeAttempts:
+			shift := attempts - cfg.LoginFreeAttempts - 1
+			wait = min(time.Second<<min(shift, 30), lockout)
+		}
+		if wait > 0 {
+			updates["locked_until"] = now.Add(wait)
+		}
+		return tx.Model(&current).UpdateColumns(updates).Error
+	})
+	if err != nil {
+		log.Printf("Failed to record login failure for user %d: %v", user.ID, err)
+		return 0, false
+	}
+
+	if locked {
+		log.Printf("Account of user %d locked after %d failed attempts", user.ID, cfg.LoginMaxAttempts)
+		until := time.Now().Add(wait)
+		go func() {
+			if err := utils.SendAccountLockedEmail(user.Email, user.Name, until); err != nil {
+				log.Printf("Failed to send account locked email to %s: %v", user.Email, err)
+			}
+		}()
+	}
+	return wait, locked
+}
+
+// resetLoginFailures clears the failure counter after a correct password or OTP.
+// The user may have been read before a parallel failure set a wait; then
+// nothing is cleared and the wait is returned, and the check must be refused.
+func resetLoginFailures(user *models.User) time.Duration {
+	var wait time.Duration
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, failed_login_attempts, last_failed_login_at, locked_until").
+			First(&current, user.ID).Error; err != nil {
+			return err
+		}
+		if wait = loginRetryAfter(&current); wait > 0 {
+			return nil
+		}
+		if current.FailedLoginAttempts == 0 && current.LastFailedLoginAt == nil && current.LockedUntil == nil {
+			return nil
+		}
+		return models.ResetLoginFailures(tx, user.ID)
+	})
+	if err != nil {
+		log.Printf("Failed to reset login failures for user %d: %v", user.ID, err)
+		return 0
+	}
+	if wait > 0 {
+		return wait
+	}
+	// Keep a later Save of the user from writing the old counter back
+	user.FailedLoginAttempts = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	return 0
+}
+
+// respondLoginLocked rejects a password or OTP check while the account has to wait
+func respondLoginLocked(c *gin.Context, wait time.Duration, locked bool) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+
+	msg := fmt.Sprintf("Terlalu banyak percobaan gagal. Coba lagi dalam %d detik.", seconds)
+	if locked || wait > time.Minute {
+		msg = fmt.Sprintf("Akun dikunci sementara karena terlalu banyak percobaan gagal. Coba lagi dalam %d menit.",
+			int(math.Ceil(wait.Minutes())))
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       msg,
+		"retry_after": seconds,
+	})
+}
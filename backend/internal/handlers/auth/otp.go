@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strings"
 	"time"
@@ -39,8 +40,18 @@ func VerifyOTP(c *gin.Context) {
 		return
 	}
 
+	// Failed OTP checks count against the account like failed logins
+	if wait := loginRetryAfter(&user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return
+	}
+
 	// Check OTP
-	if user.OTPCode == nil || *user.OTPCode != req.OTP {
+	if user.OTPCode == nil || subtle.ConstantTimeCompare([]byte(*user.OTPCode), []byte(req.OTP)) != 1 {
+		if wait, locked := recordLoginFailure(&user); locked {
+			respondLoginLocked(c, wait, true)
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode OTP salah"})
 		return
 	}
@@ -51,6 +62,11 @@ func VerifyOTP(c *gin.Context) {
 		return
 	}
 
+	if wait := resetLoginFailures(&user); wait > 0 {
+		respondLoginLocked(c, wait, false)
+		return
+	}
+
 	// Verify email
 	now := time.Now()
 	user.EmailVerifiedAt = &now
@@ -62,19 +78,13 @@ func VerifyOTP(c *gin.Context) {
 		return
 	}
 
-	// Generate tokens and log user in
-	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, string(user.Role))
+	// Generate tokens and log user in with a new session
+	accessToken, refreshToken, err := startSession(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat token"})
 		return
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat refresh token"})
-		return
-	}
-
 	// Set cookies
 	setAuthCookies(c, accessToken, refreshToken)
 
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	resetTokenTTL        = 30 * time.Minute
+	resetRequestInterval = time.Minute    // Minimum time between reset emails to one address
+	resetWindow          = 24 * time.Hour // Period the request and attempt limits apply to
+	maxResetRequests     = 5              // Reset emails to one address per window
+	maxResetAttempts     = 10             // Wrong codes or tokens for one address per window
+)
+
+var (
+	// errResetInvalid is returned for unknown, expired, used up or wrong reset tokens
+	errResetInvalid = errors.New("invalid password reset token")
+	// errResetThrottled is returned when an email asks for resets too often
+	errResetThrottled = errors.New("password reset requested too often")
+	// errLoginWaiting is returned while the account has to wait after failed checks
+	errLoginWaiting = errors.New("account is waiting after failed attempts")
+)
+
+// ForgotPasswordRequest represents the forgot password request
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword emails a reset code and link. The response is the same whether
+// or not the email is registered.
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email tidak valid"})
+		return
+	}
+
+	if err := requestPasswordReset(strings.ToLower(req.Email)); err != nil {
+		log.Printf("Failed to create password reset for %s: %v", req.Email, err)
+	}
+
+	// Don't reveal if email exists
+	c.JSON(http.StatusOK, gin.H{"message": "Jika email terdaftar, kode dan tautan reset password telah dikirim."})
+}
+
+// requestPasswordReset replaces the pending reset of an email and sends it.
+// Unknown emails, accounts without a password and requests over the limits
+// are skipped without an error.
+func requestPasswordReset(email string) error {
+	var user models.User
+	if err := database.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.Password == nil {
+		// Google-only account; it signs in with Google
+		return nil
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	code, err := utils.GenerateOTP()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reset := models.PasswordResetToken{
+		Email:           email,
+		Token:           models.HashSecret(token),
+		CodeHash:        models.HashSecret(code),
+		ExpiresAt:       now.Add(resetTokenTTL),
+		CreatedAt:       now,
+		Requests:        1,
+		WindowStartedAt: now,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var previous models.PasswordResetToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", email).Take(&previous).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			if now.Sub(previous.CreatedAt) < resetRequestInterval {
+				return errResetThrottled
+			}
+			// Within the window the counts carry over to the new code
+			if now.Sub(previous.WindowStartedAt) < resetWindow {
+				if previous.Requests >= maxResetRequests {
+					return errResetThrottled
+				}
+				reset.Requests = previous.Requests + 1
+				reset.Attempts = previous.Attempts
+				reset.WindowStartedAt = previous.WindowStartedAt
+			}
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&reset).Error
+	})
+	if errors.Is(err, errResetThrottled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?email=%s&token=%s",
+		config.AppConfig.FrontendURL, url.QueryEscape(email), url.QueryEscape(token))
+	go func() {
+		if err := utils.SendPasswordResetEmail(user.Email, user.Name, code, link, resetTokenTTL); err != nil {
+			log.Printf("Failed to send password reset email to %s: %v", user.Email, err)
+		}
+	}()
+	return nil
+}
+
+// ResetPasswordRequest represents the reset password request. Either the token
+// from the emailed link or the emailed code is required.
+type ResetPasswordRequest struct {
+	Email           string `json:"email" binding:"required,email"`
+	Token           string `json:"token"`
+	Code            string `json:"code"`
+	Password        string `json:"password" binding:"required,min=6"`
+	ConfirmPassword string `json:"confirm_password" binding:"required"`
+}
+
+// ResetPassword sets a new password with a reset token or code. The reset can
+// only be used once, and every session of the account is revoked.
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid: " + err.Error()})
+		return
+	}
+	if req.Token == "" && req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode atau tautan reset wajib diisi"})
+		return
+	}
+	if req.Password != req.ConfirmPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password tidak cocok"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses password"})
+		return
+	}
+
+	email := strings.ToLower(req.Email)
+	var user models.User
+	var wait time.Duration
+	matched := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", email).Take(&reset).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errResetInvalid
+			}
+			return err
+		}
+		if !reset.ExpiresAt.After(time.Now()) || reset.Attempts >= maxResetAttempts {
+			return errResetInvalid
+		}
+
+		if err := tx.Where("email = ?", email).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errResetInvalid
+			}
+			return err
+		}
+		// Wrong codes count against the account like failed logins
+		if wait = loginRetryAfter(&user); wait > 0 {
+			return errLoginWaiting
+		}
+
+		if req.Token != "" {
+			matched = subtle.ConstantTimeCompare([]byte(models.HashSecret(req.Token)), []byte(reset.Token)) == 1
+		} else {
+			matched = subtle.ConstantTimeCompare([]byte(models.HashSecret(req.Code)), []byte(reset.CodeHash)) == 1
+		}
+		if !matched {
+			// Committed, so guessing the code runs out of attempts
+			return tx.Model(&reset).UpdateColumn("attempts", gorm.Expr("attempts + 1")).Error
+		}
+
+		updates := map[string]interface{}{
+			"password":       string(hashedPassword),
+			"otp_code":       nil,
+			"otp_expires_at": nil,
+			// A reset proves the owner, so a lockout from guessing ends
+			"failed_login_attempts": 0,
+			"last_failed_login_at":  nil,
+			"locked_until":          nil,
+		}
+		// Receiving the reset email proves the address
+		if user.EmailVerifiedAt == nil {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&reset).Error; err != nil {
+			return err
+		}
+		if _, err := models.RevokeUserSessions(tx, user.ID, models.SessionPasswordReset, ""); err != nil {
+			return err
+		}
+		return models.RevokeLegacyTokens(tx, user.ID)
+	})
+	if errors.Is(err, errLoginWaiting) {
+		respondLoginLocked(c, wait, false)
+		return
+	}
+	if err == nil && !matched {
+		if wait, locked := recordLoginFailure(&user); locked {
+			respondLoginLocked(c, wait, true)
+			return
+		}
+	}
+	if errors.Is(err, errResetInvalid) || (err == nil && !matched) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kode atau tautan reset tidak valid atau sudah kadaluarsa"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mereset password"})
+		return
+	}
+
+	clearAuthCookies(c)
+	go utils.SendPasswordChangedEmail(user.Email, user.Name)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password berhasil direset. Silakan login dengan password baru."})
+}
+
+// randomToken returns a random URL-safe token for reset links
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,360 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refreshReuseGrace is how long a rotated refresh token may still be presented
+// without being treated as stolen
+const refreshReuseGrace = 30 * time.Second
+
+var (
+	// errSessionInvalid is returned for refresh tokens without a live session
+	errSessionInvalid = errors.New("refresh token has no live session")
+	// errSessionReused is returned when a rotated refresh token is presented again
+	errSessionReused = errors.New("rotated refresh token reused")
+)
+
+// startSession opens a new session family for a login and signs its first tokens
+func startSession(c *gin.Context, user *models.User) (accessToken, refreshToken string, err error) {
+	_, accessToken, refreshToken, err = issueSessionTokens(database.DB, c, user, uuid.New().String(), time.Now())
+	return accessToken, refreshToken, err
+}
+
+// issueSessionTokens stores a new refresh token in the session family and signs
+// it together with an access token for the same session
+func issueSessionTokens(tx *gorm.DB, c *gin.Context, user *models.User, familyID string, loginAt time.Time) (*models.AuthSession, string, string, error) {
+	userAgent := c.Request.UserAgent()
+	session := &models.AuthSession{
+		JTI:       uuid.New().String(),
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		Device:    deviceName(userAgent),
+		IPAddress: c.ClientIP(),
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(time.Duration(config.AppConfig.RefreshExpireDays) * 24 * time.Hour),
+		LoginAt:   loginAt,
+	}
+	if err := tx.Create(session).Error; err != nil {
+		return nil, "", "", err
+	}
+
+	accessToken, refreshToken, err := signSessionTokens(user, session)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return session, accessToken, refreshToken, nil
+}
+
+// signSessionTokens signs an access token and the refresh token of a stored session
+func signSessionTokens(user *models.User, session *models.AuthSession) (string, string, error) {
+	accessToken, err := utils.GenerateAccessToken(user.ID, user.Email, string(user.Role), session.FamilyID)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := utils.GenerateRefreshToken(user.ID, user.Email, string(user.Role), session.FamilyID, session.JTI, session.ExpiresAt)
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// rotateSession exchanges a refresh token for a new pair. The presented token is
+// revoked; presenting it again afterwards revokes the whole family, since only
+// a copy held by someone else would still be in use.
+func rotateSession(c *gin.Context, claims *utils.Claims) (accessToken, refreshToken string, err error) {
+	if claims.ID == "" || claims.SessionID == "" {
+		return upgradeLegacySession(c, claims)
+	}
+
+	reused := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.AuthSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("jti = ?", claims.ID).
+			First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errSessionInvalid
+			}
+			return err
+		}
+		if session.UserID != claims.UserID || session.FamilyID != claims.SessionID {
+			return errSessionInvalid
+		}
+
+		if session.RevokedAt != nil {
+			rotated := session.RevokedReason != nil && *session.RevokedReason == models.SessionRotated
+			// Parallel requests may refresh with the same token; a repeat right after
+			// the rotation gets the token that replaced it again instead of ending
+			// the family. No new token is stored, so a replayed copy cannot open a
+			// second branch of the session.
+			if rotated && session.ReplacedBy != nil && time.Since(*session.RevokedAt) < refreshReuseGrace {
+				var next models.AuthSession
+				if err := tx.Where("jti = ?", *session.ReplacedBy).First(&next).Error; err != nil {
+					return errSessionInvalid
+				}
+				// ...unless the replacement was rotated or ended in the meantime
+				if next.RevokedAt != nil || !next.ExpiresAt.After(time.Now()) {
+					return errSessionInvalid
+				}
+				var user models.User
+				if err := tx.First(&user, session.UserID).Error; err != nil {
+					return errSessionInvalid
+				}
+				accessToken, refreshToken, err = signSessionTokens(&user, &next)
+				return err
+			}
+			if rotated {
+				if _, err := models.RevokeSessionFamily(tx, session.UserID, session.FamilyID, models.SessionReused); err != nil {
+					return err
+				}
+				reused = true
+				return nil
+			}
+			return errSessionInvalid
+		}
+		if !session.ExpiresAt.After(time.Now()) {
+			return errSessionInvalid
+		}
+
+		var user models.User
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			return errSessionInvalid
+		}
+
+		next, access, refresh, err := issueSessionTokens(tx, c, &user, session.FamilyID, session.LoginAt)
+		if err != nil {
+			return err
+		}
+		accessToken, refreshToken = access, refresh
+
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": models.SessionRotated,
+			"replaced_by":    next.JTI,
+		}).Error
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if reused {
+		log.Printf("Refresh token reuse detected for user %d, session %s revoked", claims.UserID, claims.SessionID)
+		return "", "", errSessionReused
+	}
+	return accessToken, refreshToken, nil
+}
+
+// upgradeLegacySession moves a refresh token signed before the session store
+// into a new session family, so users logged in before it keep their login.
+// The legacy token is recorded as the family's first, already rotated token:
+// presenting it again is handled like any other rotated token, so it can be
+// upgraded only once.
+func upgradeLegacySession(c *gin.Context, claims *utils.Claims) (string, string, error) {
+	if claims.Type != "" || claims.IssuedAt == nil || claims.ExpiresAt == nil {
+		// Typed tokens are always issued with a session
+		return "", "", errSessionInvalid
+	}
+	legacyID := fmt.Sprintf("legacy-%d-%d", claims.UserID, claims.IssuedAt.Unix())
+
+	var legacy models.AuthSession
+	err := database.DB.Where("jti = ?", legacyID).First(&legacy).Error
+	if err == nil {
+		upgraded := *claims
+		upgraded.ID, upgraded.SessionID = legacy.JTI, legacy.FamilyID
+		return rotateSession(c, &upgraded)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", err
+	}
+
+	var accessToken, refreshToken string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, claims.UserID).Error; err != nil {
+			return errSessionInvalid
+		}
+		if user.Email != claims.Email {
+			// The account moved to another email since the token was issued
+			return errSessionInvalid
+		}
+		if user.SessionsRevokedAt != nil && !claims.IssuedAt.After(*user.SessionsRevokedAt) {
+			// Signed before the user logged out everywhere
+			return errSessionInvalid
+		}
+
+		loginAt := claims.IssuedAt.Time
+		next, access, refresh, err := issueSessionTokens(tx, c, &user, uuid.New().String(), loginAt)
+		if err != nil {
+			return err
+		}
+		accessToken, refreshToken = access, refresh
+
+		now := time.Now()
+		reason := models.SessionRotated
+		return tx.Create(&models.AuthSession{
+			JTI:           legacyID,
+			FamilyID:      next.FamilyID,
+			UserID:        user.ID,
+			Device:        next.Device,
+			IPAddress:     next.IPAddress,
+			UserAgent:     next.UserAgent,
+			ExpiresAt:     claims.ExpiresAt.Time,
+			RevokedAt:     &now,
+			RevokedReason: &reason,
+			ReplacedBy:    &next.JTI,
+			LoginAt:       loginAt,
+		}).Error
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// LogoutAll revokes every session of the current user
+func LogoutAll(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var count int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if count, err = models.RevokeUserSessions(tx, user.ID, models.SessionLogoutAll, ""); err != nil {
+			return err
+		}
+		return models.RevokeLegacyTokens(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal keluar dari semua perangkat"})
+		return
+	}
+	clearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Berhasil keluar dari semua perangkat",
+		"revoked": count,
+	})
+}
+
+// ListSessions returns the devices the current user is logged in on
+func ListSessions(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessions, err := models.ActiveSessions(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil sesi"})
+		return
+	}
+
+	current := c.GetString("session_id")
+	data := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		data = append(data, gin.H{
+			"id":             s.FamilyID,
+			"device":         s.Device,
+			"ip_address":     s.IPAddress,
+			"user_agent":     s.UserAgent,
+			"login_at":       s.LoginAt,
+			"last_active_at": s.CreatedAt,
+			"expires_at":     s.ExpiresAt,
+			"current":        s.FamilyID == current,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// RevokeSession logs the current user out of one device
+func RevokeSession(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	count, err := models.RevokeSessionFamily(database.DB, user.ID, c.Param("id"), models.SessionLogout)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengakhiri sesi"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sesi tidak ditemukan"})
+		return
+	}
+	if c.Param("id") == c.GetString("session_id") {
+		clearAuthCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sesi berhasil diakhiri"})
+}
+
+// clearAuthCookies removes the token cookies
+func clearAuthCookies(c *gin.Context) {
+	c.SetCookie("access_token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
+}
+
+// deviceName gives a short description of the browser and OS in a user agent,
+// e.g. "Chrome di Android"
+func deviceName(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := "Browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "samsungbrowser"):
+		browser = "Samsung Internet"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "firefox/") || strings.Contains(ua, "fxios/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case ua == "":
+		return "Perangkat tidak dikenal"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		os = "iOS"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	}
+	if os == "" {
+		return browser
+	}
+	return browser + " di " + os
+}
@@ -1,6 +1,7 @@
 package cart
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -9,12 +10,15 @@ import (
 	"gsm-motor/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // AddToCartRequest represents the add to cart request
 type AddToCartRequest struct {
-	ProductID uint `json:"product_id" binding:"required"`
-	Quantity  int  `json:"quantity" binding:"required,min=1"`
+	ProductID uint  `json:"product_id" binding:"required"`
+	VariantID *uint `json:"variant_id"` // Required when the product has variants
+	Quantity  int   `json:"quantity" binding:"required,min=1"`
 }
 
 // AddToCart adds a product to the user's cart
@@ -38,34 +42,69 @@ func AddToCart(c *gin.Context) {
 		return
 	}
 
-	if product.Stock < req.Quantity {
+	// Products with variants are sold per variant
+	var variantCount int64
+	database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+
+	stock := product.Stock
+	if variantCount > 0 {
+		if req.VariantID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Silakan pilih varian produk"})
+			return
+		}
+		var variant models.ProductVariant
+		if err := database.DB.Where("id = ? AND product_id = ?", *req.VariantID, product.ID).First(&variant).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Varian tidak ditemukan"})
+			return
+		}
+		stock = variant.Stock
+	} else {
+		req.VariantID = nil
+	}
+
+	if stock < req.Quantity {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Stok tidak mencukupi"})
 		return
 	}
 
-	// Check if item already in cart
-	var existingItem models.CartItem
-	result := database.DB.Where("user_id = ? AND product_id = ?", user.ID, req.ProductID).First(&existingItem)
+	// The unique index does not cover lines without a variant, since MySQL allows
+	// repeated NULLs. Adds of one user run one at a time behind a lock on their
+	// row, so two requests cannot both miss the line and insert it twice.
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&models.User{}, user.ID).Error; err != nil {
+			return err
+		}
 
-	if result.Error == nil {
-		// Update quantity
-		newQty := existingItem.Quantity + req.Quantity
-		if newQty > product.Stock {
-			newQty = product.Stock
+		var existingItem models.CartItem
+		query := tx.Where("user_id = ? AND product_id = ?", user.ID, req.ProductID)
+		if req.VariantID != nil {
+			query = query.Where("variant_id = ?", *req.VariantID)
+		} else {
+			query = query.Where("variant_id IS NULL")
 		}
-		existingItem.Quantity = newQty
-		database.DB.Save(&existingItem)
-	} else {
+		err := query.First(&existingItem).Error
+		if err == nil {
+			// Update quantity
+			existingItem.Quantity = min(existingItem.Quantity+req.Quantity, stock)
+			return tx.Save(&existingItem).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
 		// Create new cart item
-		cartItem := models.CartItem{
+		return tx.Create(&models.CartItem{
 			UserID:    user.ID,
 			ProductID: req.ProductID,
+			VariantID: req.VariantID,
 			Quantity:  req.Quantity,
-		}
-		if err := database.DB.Create(&cartItem).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menambahkan ke keranjang"})
-			return
-		}
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menambahkan ke keranjang"})
+		return
 	}
 
 	// Get updated cart count
@@ -90,6 +129,7 @@ func GetCart(c *gin.Context) {
 	database.DB.
 		Preload("Product").
 		Preload("Product.Images").
+		Preload("Variant").
 		Where("user_id = ?", user.ID).
 		Find(&cartItems)
 
@@ -100,18 +140,38 @@ func GetCart(c *gin.Context) {
 
 	for _, item := range cartItems {
 		if item.Product != nil {
-			subtotal += item.Product.GetEffectivePrice(item.Quantity) * float64(item.Quantity)
-			totalWeight += item.Product.Weight * item.Quantity
+			subtotal += item.GetSubtotal()
+			totalWeight += item.GetTotalWeight()
 			totalItems += item.Quantity
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"items":        cartItems,
 		"subtotal":     subtotal,
+		"discount":     0,
+		"total":        subtotal,
 		"total_weight": totalWeight,
 		"total_items":  totalItems,
-	})
+	}
+
+	// Preview a voucher code if the customer entered one
+	if code := c.Query("voucher"); code != "" {
+		voucher, err := models.ApplyVoucher(database.DB, code, user.ID, cartItems, false)
+		var voucherErr *models.VoucherError
+		switch {
+		case err == nil:
+			response["voucher"] = voucher
+			response["discount"] = voucher.Discount
+			response["total"] = subtotal - voucher.Discount
+		case errors.As(err, &voucherErr):
+			response["voucher_error"] = voucherErr.Message
+		default:
+			response["voucher_error"] = "Gagal memeriksa voucher"
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // UpdateCartItem updates the quantity of a cart item
@@ -138,13 +198,13 @@ func UpdateCartItem(c *gin.Context) {
 
 	// Find cart item
 	var cartItem models.CartItem
-	if err := database.DB.Preload("Product").Where("id = ? AND user_id = ?", id, user.ID).First(&cartItem).Error; err != nil {
+	if err := database.DB.Preload("Product").Preload("Variant").Where("id = ? AND user_id = ?", id, user.ID).First(&cartItem).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Item tidak ditemukan"})
 		return
 	}
 
 	// Check stock
-	if cartItem.Product != nil && req.Quantity > cartItem.Product.Stock {
+	if cartItem.Product != nil && req.Quantity > cartItem.GetAvailableStock() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Stok tidak mencukupi"})
 		return
 	}
@@ -1,17 +1,22 @@
 package checkout
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"gsm-motor/internal/config"
 	"gsm-motor/internal/database"
+	"gsm-motor/internal/inventory"
 	"gsm-motor/internal/middleware"
 	"gsm-motor/internal/models"
+	"gsm-motor/internal/orders"
 	"gsm-motor/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // PrepareCheckout validates cart and returns checkout preview
@@ -26,6 +31,7 @@ func PrepareCheckout(c *gin.Context) {
 	var cartItems []models.CartItem
 	database.DB.
 		Preload("Product").
+		Preload("Variant").
 		Where("user_id = ?", user.ID).
 		Find(&cartItems)
 
@@ -39,19 +45,28 @@ func PrepareCheckout(c *gin.Context) {
 	var totalWeight int
 	for _, item := range cartItems {
 		if item.Product != nil {
-			subtotal += item.Product.GetEffectivePrice(item.Quantity) * float64(item.Quantity)
-			totalWeight += item.Product.Weight * item.Quantity
+			subtotal += item.GetSubtotal()
+			totalWeight += item.GetTotalWeight()
 		}
 	}
 
 	// Check address completeness
 	hasAddress := user.HasCompleteAddress()
 
+	voucher, voucherError := applyVoucherPreview(c.Query("voucher"), user.ID, cartItems)
+	discount := float64(0)
+	if voucher != nil {
+		discount = voucher.Discount
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"items":        cartItems,
-		"subtotal":     subtotal,
-		"total_weight": totalWeight,
-		"has_address":  hasAddress,
+		"items":         cartItems,
+		"subtotal":      subtotal,
+		"discount":      discount,
+		"voucher":       voucher,
+		"voucher_error": voucherError,
+		"total_weight":  totalWeight,
+		"has_address":   hasAddress,
 		"user": gin.H{
 			"name":           user.Name,
 			"email":          user.Email,
@@ -72,12 +87,30 @@ func PrepareCheckout(c *gin.Context) {
 	})
 }
 
+// applyVoucherPreview applies an optional voucher code for display purposes.
+// It returns the customer-facing reason when the voucher cannot be used.
+func applyVoucherPreview(code string, userID uint, cartItems []models.CartItem) (*models.VoucherResult, string) {
+	if code == "" {
+		return nil, ""
+	}
+	voucher, err := models.ApplyVoucher(database.DB, code, userID, cartItems, false)
+	if err != nil {
+		var voucherErr *models.VoucherError
+		if errors.As(err, &voucherErr) {
+			return nil, voucherErr.Message
+		}
+		return nil, "Gagal memeriksa voucher"
+	}
+	return voucher, ""
+}
+
 // CheckoutRequest represents the checkout request
 type CheckoutRequest struct {
 	ShippingMethod string  `json:"shipping_method" binding:"required,oneof=pickup ojol courier"`
 	Courier        string  `json:"courier"`         // Required if shipping_method is courier
 	CourierService string  `json:"courier_service"` // e.g., REG, OKE
-	ShippingCost   float64 `json:"shipping_cost"`
+	ShippingCost   float64 `json:"shipping_cost"`   // Must match the server-side RajaOngkir quote
+	VoucherCode    string  `json:"voucher_code"`
 	Notes          string  `json:"notes"`
 }
 
@@ -111,6 +144,7 @@ func ProcessCheckout(c *gin.Context) {
 	var cartItems []models.CartItem
 	database.DB.
 		Preload("Product").
+		Preload("Variant").
 		Where("user_id = ?", user.ID).
 		Find(&cartItems)
 
@@ -119,23 +153,28 @@ func ProcessCheckout(c *gin.Context) {
 		return
 	}
 
-	// Calculate totals
-	var subtotal float64
-	for _, item := range cartItems {
-		if item.Product != nil {
-			subtotal += item.Product.GetEffectivePrice(item.Quantity) * float64(item.Quantity)
-		}
-	}
-
-	// Determine shipping cost
+	// Recompute the courier price server-side; the client value is only used as a cross-check
 	shippingCost := float64(0)
-	switch req.ShippingMethod {
-	case "pickup":
-		shippingCost = 0
-	case "ojol":
-		shippingCost = 0 // Will be arranged via WhatsApp
-	case "courier":
-		shippingCost = req.ShippingCost
+	if req.ShippingMethod == "courier" {
+		quoted, err := courierShippingCost(user, cartItems, &req)
+		switch {
+		case errors.Is(err, errShippingCostChanged):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":         "Ongkos kirim tidak sesuai, silakan pilih ulang kurir",
+				"shipping_cost": quoted,
+			})
+			return
+		case errors.Is(err, errNoDestination):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Silakan pilih kecamatan tujuan pada alamat Anda"})
+			return
+		case errors.Is(err, errServiceNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Layanan kurir tidak tersedia untuk tujuan ini"})
+			return
+		case err != nil:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Gagal menghitung ongkir, silakan coba lagi"})
+			return
+		}
+		shippingCost = quoted
 	}
 
 	// Build shipping address
@@ -146,6 +185,64 @@ func ProcessCheckout(c *gin.Context) {
 
 	// Start transaction
 	tx := database.DB.Begin()
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulai transaksi"})
+		return
+	}
+
+	// Lock the product rows so concurrent checkouts cannot oversell
+	lockedProducts, err := lockCartProducts(tx, cartItems)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa stok"})
+		return
+	}
+
+	lockedVariants, err := lockCartVariants(tx, cartItems)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa stok"})
+		return
+	}
+
+	if insufficient := findInsufficientStock(cartItems, lockedProducts, lockedVariants); len(insufficient) > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Stok tidak mencukupi",
+			"items": insufficient,
+		})
+		return
+	}
+
+	// Use the locked rows for pricing so the order reflects the current product data
+	for i := range cartItems {
+		cartItems[i].Product = lockedProducts[cartItems[i].ProductID]
+		if cartItems[i].VariantID != nil {
+			cartItems[i].Variant = lockedVariants[*cartItems[i].VariantID]
+		}
+	}
+
+	// Calculate totals
+	var subtotal float64
+	for _, item := range cartItems {
+		subtotal += item.GetSubtotal()
+	}
+
+	// Apply voucher; the voucher row is locked so usage limits hold under concurrency
+	var voucher *models.VoucherResult
+	if req.VoucherCode != "" {
+		voucher, err = models.ApplyVoucher(tx, req.VoucherCode, user.ID, cartItems, true)
+		if err != nil {
+			tx.Rollback()
+			var voucherErr *models.VoucherError
+			if errors.As(err, &voucherErr) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": voucherErr.Message})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memeriksa voucher"})
+			return
+		}
+	}
 
 	// Generate order number
 	orderNumber := models.GenerateOrderNumber()
@@ -171,6 +268,11 @@ func ProcessCheckout(c *gin.Context) {
 	if req.Notes != "" {
 		order.Notes = &req.Notes
 	}
+	if voucher != nil {
+		order.VoucherID = &voucher.Voucher.ID
+		order.VoucherCode = &voucher.Code
+		order.DiscountAmount = voucher.Discount
+	}
 
 	if err := tx.Create(&order).Error; err != nil {
 		tx.Rollback()
@@ -178,16 +280,33 @@ func ProcessCheckout(c *gin.Context) {
 		return
 	}
 
+	if err := models.RecordOrderCreated(tx, &order, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat pesanan"})
+		return
+	}
+
+	if voucher != nil {
+		if err := models.RedeemVoucher(tx, voucher, user.ID, order.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menggunakan voucher"})
+			return
+		}
+	}
+
 	// Create order items
 	for _, item := range cartItems {
-		if item.Product == nil {
-			continue
-		}
 		orderItem := models.OrderItem{
 			OrderID:         order.ID,
 			ProductID:       item.ProductID,
 			Quantity:        item.Quantity,
-			PriceAtPurchase: item.Product.GetEffectivePrice(item.Quantity),
+			PriceAtPurchase: item.GetUnitPrice(),
+			CostAtPurchase:  item.GetUnitCost(),
+		}
+		if item.Variant != nil {
+			orderItem.VariantID = &item.Variant.ID
+			orderItem.VariantName = &item.Variant.Name
+			orderItem.SKU = &item.Variant.SKU
 		}
 		if err := tx.Create(&orderItem).Error; err != nil {
 			tx.Rollback()
@@ -195,17 +314,40 @@ func ProcessCheckout(c *gin.Context) {
 			return
 		}
 
-		// Reduce stock
-		tx.Model(&models.Product{}).
-			Where("id = ?", item.ProductID).
-			Update("stock", item.Product.Stock-item.Quantity)
+		// Reduce stock through the ledger; its stock guard is a second line of
+		// defence behind the row lock
+		_, err := inventory.Apply(tx, inventory.Change{
+			ProductID:     item.ProductID,
+			VariantID:     orderItem.VariantID,
+			Delta:         -item.Quantity,
+			Type:          models.MovementSale,
+			Actor:         user,
+			ReferenceType: models.ReferenceOrder,
+			ReferenceID:   order.ID,
+		})
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, inventory.ErrInsufficientStock) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Stok tidak mencukupi"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui stok"})
+			return
+		}
 	}
 
 	// Clear cart
-	tx.Where("user_id = ?", user.ID).Delete(&models.CartItem{})
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.CartItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengosongkan keranjang"})
+		return
+	}
 
 	// Commit transaction
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan pesanan"})
+		return
+	}
 
 	// Generate QR code for order (async)
 	go utils.GenerateQRCode(orderNumber, config.AppConfig.UploadPath)
@@ -216,14 +358,12 @@ func ProcessCheckout(c *gin.Context) {
 	// Send order notification email to admins (async)
 	var orderItems []utils.OrderItemInfo
 	for _, item := range cartItems {
-		if item.Product != nil {
-			orderItems = append(orderItems, utils.OrderItemInfo{
-				ProductName: item.Product.Name,
-				Quantity:    item.Quantity,
-				Price:       item.Product.GetEffectivePrice(item.Quantity),
-				Subtotal:    item.Product.GetEffectivePrice(item.Quantity) * float64(item.Quantity),
-			})
-		}
+		orderItems = append(orderItems, utils.OrderItemInfo{
+			ProductName: item.GetDisplayName(),
+			Quantity:    item.Quantity,
+			Price:       item.GetUnitPrice(),
+			Subtotal:    item.GetSubtotal(),
+		})
 	}
 
 	phone := ""
@@ -240,6 +380,7 @@ func ProcessCheckout(c *gin.Context) {
 		orderItems,
 		order.GetGrandTotal(),
 		shippingCost,
+		order.DiscountAmount,
 		"Transfer Bank",
 	)
 
@@ -256,6 +397,159 @@ func ProcessCheckout(c *gin.Context) {
 	})
 }
 
+var (
+	errNoDestination   = errors.New("destination subdistrict not set")
+	errServiceNotFound = errors.New("courier service not available")
+	// errShippingCostChanged is returned when the client's price differs from the quote
+	errShippingCostChanged = errors.New("shipping cost does not match quote")
+)
+
+// courierShippingCost quotes the courier service of a checkout and checks it
+// against the price the client showed. On a mismatch the quote is returned
+// with errShippingCostChanged so the client can show the current price.
+func courierShippingCost(user *models.User, cartItems []models.CartItem, req *CheckoutRequest) (float64, error) {
+	quoted, err := quoteShippingCost(user, cartItems, req.Courier, req.CourierService)
+	if err != nil {
+		return 0, err
+	}
+	if req.ShippingCost != quoted {
+		return quoted, errShippingCostChanged
+	}
+	return quoted, nil
+}
+
+// quoteShippingCost asks RajaOngkir for the price of the chosen courier service
+// to the user's subdistrict, using the same weight the storefront quotes with
+func quoteShippingCost(user *models.User, cartItems []models.CartItem, courier, service string) (float64, error) {
+	if user.SubdistrictID == nil || *user.SubdistrictID == "" {
+		return 0, errNoDestination
+	}
+
+	totalWeight := 0
+	for _, item := range cartItems {
+		totalWeight += item.GetTotalWeight()
+	}
+	if totalWeight <= 0 {
+		totalWeight = 500
+	}
+
+	client := utils.NewRajaOngkirClient()
+	origin := client.GetStoreOrigin()
+	if origin == "" {
+		return 0, errors.New("store origin not configured")
+	}
+
+	costs, err := client.CalculateShippingCost(origin, *user.SubdistrictID, totalWeight, courier)
+	if err != nil {
+		return 0, err
+	}
+
+	cost, ok := utils.FindServiceCost(costs, courier, service)
+	if !ok {
+		return 0, errServiceNotFound
+	}
+	return float64(cost), nil
+}
+
+// InsufficientStockItem describes a cart line that can no longer be filled
+type InsufficientStockItem struct {
+	ProductID   uint   `json:"product_id"`
+	VariantID   *uint  `json:"variant_id,omitempty"`
+	ProductName string `json:"product_name"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+}
+
+// lockCartProducts loads the products in the cart with SELECT ... FOR UPDATE.
+// Rows are locked in ID order to keep concurrent checkouts from deadlocking.
+func lockCartProducts(tx *gorm.DB, cartItems []models.CartItem) (map[uint]*models.Product, error) {
+	ids := make([]uint, 0, len(cartItems))
+	for _, item := range cartItems {
+		ids = append(ids, item.ProductID)
+	}
+
+	var products []models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id ASC").
+		Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	locked := make(map[uint]*models.Product, len(products))
+	for i := range products {
+		locked[products[i].ID] = &products[i]
+	}
+	return locked, nil
+}
+
+// lockCartVariants locks the variants referenced by the cart, after the products
+// and in ID order, matching the lock order of lockCartProducts
+func lockCartVariants(tx *gorm.DB, cartItems []models.CartItem) (map[uint]*models.ProductVariant, error) {
+	ids := make([]uint, 0, len(cartItems))
+	for _, item := range cartItems {
+		if item.VariantID != nil {
+			ids = append(ids, *item.VariantID)
+		}
+	}
+
+	locked := make(map[uint]*models.ProductVariant, len(ids))
+	if len(ids) == 0 {
+		return locked, nil
+	}
+
+	var variants []models.ProductVariant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id ASC").
+		Find(&variants).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range variants {
+		locked[variants[i].ID] = &variants[i]
+	}
+	return locked, nil
+}
+
+// findInsufficientStock returns every cart line whose quantity exceeds the locked stock
+func findInsufficientStock(cartItems []models.CartItem, locked map[uint]*models.Product, lockedVariants map[uint]*models.ProductVariant) []InsufficientStockItem {
+	var insufficient []InsufficientStockItem
+	for _, item := range cartItems {
+		name := item.GetDisplayName()
+		product, ok := locked[item.ProductID]
+		stock := 0
+		if ok {
+			stock = product.Stock
+			name = product.Name
+		}
+		if ok && item.VariantID != nil {
+			// The variant may have been deleted after it was added to the cart
+			variant, found := lockedVariants[*item.VariantID]
+			stock = 0
+			if found && variant.ProductID == product.ID {
+				stock = variant.Stock
+				name = product.Name + " - " + variant.Name
+			}
+		}
+
+		// A missing product was deleted after it was added to the cart
+		if !ok || stock < item.Quantity {
+			if stock < 0 {
+				stock = 0
+			}
+			insufficient = append(insufficient, InsufficientStockItem{
+				ProductID:   item.ProductID,
+				VariantID:   item.VariantID,
+				ProductName: name,
+				Requested:   item.Quantity,
+				Available:   stock,
+			})
+		}
+	}
+	return insufficient
+}
+
 // GetOrder returns order details
 func GetOrder(c *gin.Context) {
 	user := middleware.GetCurrentUser(c)
@@ -274,7 +568,9 @@ func GetOrder(c *gin.Context) {
 	query := database.DB.
 		Preload("Items").
 		Preload("Items.Product").
-		Preload("PaymentProofs")
+		Preload("PaymentProofs").
+		Preload("Charges").
+		Scopes(models.PreloadStatusHistory)
 
 	// Non-admin users can only see their own orders
 	if user.Role != models.RoleAdmin && user.Role != models.RoleSubAdmin {
@@ -338,6 +634,62 @@ func GetOrders(c *gin.Context) {
 	})
 }
 
+// CancelOrder lets a customer cancel their own order while it is still unpaid
+func CancelOrder(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// Reason is optional for customers
+	_ = c.ShouldBindJSON(&req)
+
+	var order *models.Order
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = orders.LockOrder(tx, uint(id))
+		if err != nil {
+			return err
+		}
+		if order.UserID != user.ID {
+			return gorm.ErrRecordNotFound
+		}
+		if !orders.CanCustomerCancel(order) {
+			return orders.ErrNotCancellable
+		}
+		return orders.Cancel(tx, order, user, req.Reason)
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pesanan tidak ditemukan"})
+		return
+	case errors.Is(err, orders.ErrNotCancellable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pesanan tidak dapat dibatalkan karena sudah diproses atau dibayar"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membatalkan pesanan"})
+		return
+	}
+
+	go utils.SendOrderCancelledToAdmins(order.OrderNumber, user.Name, user.Email, req.Reason)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pesanan berhasil dibatalkan",
+		"order":   order,
+	})
+}
+
 // UploadPaymentProof handles payment proof upload
 func UploadPaymentProof(c *gin.Context) {
 	user := middleware.GetCurrentUser(c)
@@ -364,6 +716,10 @@ func UploadPaymentProof(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Pembayaran sudah diverifikasi"})
 		return
 	}
+	if order.Status == models.OrderCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pesanan sudah dibatalkan"})
+		return
+	}
 
 	// Get file
 	file, err := c.FormFile("image")
@@ -386,22 +742,23 @@ func UploadPaymentProof(c *gin.Context) {
 		return
 	}
 
-	// Create payment proof record
-	proof := models.PaymentProof{
-		OrderID:   order.ID,
-		ImagePath: imagePath,
-		Status:    models.ProofPending,
-	}
-
-	if err := database.DB.Create(&proof).Error; err != nil {
+	// Create payment proof record and update the payment status together
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		proof := models.PaymentProof{
+			OrderID:   order.ID,
+			ImagePath: imagePath,
+			Status:    models.ProofPending,
+		}
+		if err := tx.Create(&proof).Error; err != nil {
+			return err
+		}
+		return order.SetPaymentStatus(tx, models.PaymentUploaded, user, "Bukti pembayaran diunggah")
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan bukti pembayaran"})
 		return
 	}
 
-	// Update order payment status
-	order.PaymentStatus = models.PaymentUploaded
-	database.DB.Save(&order)
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Bukti pembayaran berhasil diunggah. Menunggu verifikasi admin.",
 	})
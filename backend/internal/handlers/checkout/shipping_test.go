@@ -0,0 +1,184 @@
+package checkout
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/utils"
+)
+
+// rajaOngkirStub serves canned RajaOngkir cost responses
+type rajaOngkirStub struct {
+	// rates maps a lowercase courier code to the services it offers
+	rates map[string][]utils.CostResult
+	// requests counts cost calculations
+	requests atomic.Int64
+}
+
+func (h *rajaOngkirStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/cost/domestic-cost") {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, `{"error":"invalid form"}`, http.StatusBadRequest)
+		return
+	}
+	h.requests.Add(1)
+
+	type stubCost struct {
+		Value int    `json:"value"`
+		ETD   string `json:"etd"`
+	}
+	type stubService struct {
+		Service     string     `json:"service"`
+		Description string     `json:"description"`
+		Cost        []stubCost `json:"cost"`
+	}
+
+	courier := strings.ToLower(r.PostForm.Get("courier"))
+	var costs []stubService
+	for _, s := range h.rates[courier] {
+		costs = append(costs, stubService{
+			Service:     s.Service,
+			Description: s.Description,
+			Cost:        []stubCost{{Value: s.Cost, ETD: s.ETD}},
+		})
+	}
+
+	var results []map[string]interface{}
+	if len(costs) > 0 {
+		results = append(results, map[string]interface{}{
+			"code":  courier,
+			"name":  strings.ToUpper(courier),
+			"costs": costs,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{"results": results},
+	})
+}
+
+// withRajaOngkirStub points the RajaOngkir client at a stub with a JNE rate table
+func withRajaOngkirStub(t *testing.T) *rajaOngkirStub {
+	t.Helper()
+
+	stub := &rajaOngkirStub{
+		rates: map[string][]utils.CostResult{
+			"jne": {
+				{Service: "REG", Description: "Layanan Reguler", Cost: 18000, ETD: "2-3 day"},
+				{Service: "YES", Description: "Yakin Esok Sampai", Cost: 32000, ETD: "1 day"},
+			},
+		},
+	}
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{
+		RajaOngkirBaseURL:        srv.URL,
+		StoreOriginSubdistrictID: "1",
+	}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	return stub
+}
+
+func checkoutFixture() (*models.User, []models.CartItem) {
+	destination := "2"
+	user := &models.User{SubdistrictID: &destination}
+	cartItems := []models.CartItem{
+		{Quantity: 2, Product: &models.Product{Weight: 300}},
+	}
+	return user, cartItems
+}
+
+func TestQuoteShippingCost(t *testing.T) {
+	stub := withRajaOngkirStub(t)
+	user, cartItems := checkoutFixture()
+
+	tests := []struct {
+		name    string
+		courier string
+		service string
+		want    float64
+		wantErr error
+	}{
+		{name: "known service", courier: "jne", service: "REG", want: 18000},
+		{name: "service is case-insensitive", courier: "JNE", service: "yes", want: 32000},
+		{name: "unknown service", courier: "jne", service: "OKE", wantErr: errServiceNotFound},
+		{name: "unknown courier", courier: "sicepat", service: "REG", wantErr: errServiceNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := quoteShippingCost(user, cartItems, tt.courier, tt.service)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if stub.requests.Load() != int64(len(tests)) {
+		t.Errorf("RajaOngkir consulted %d times, want %d", stub.requests.Load(), len(tests))
+	}
+}
+
+func TestQuoteShippingCostWithoutDestination(t *testing.T) {
+	stub := withRajaOngkirStub(t)
+	_, cartItems := checkoutFixture()
+
+	_, err := quoteShippingCost(&models.User{}, cartItems, "jne", "REG")
+	if !errors.Is(err, errNoDestination) {
+		t.Fatalf("err = %v, want %v", err, errNoDestination)
+	}
+	if stub.requests.Load() != 0 {
+		t.Errorf("RajaOngkir consulted without a destination")
+	}
+}
+
+func TestCourierShippingCost(t *testing.T) {
+	withRajaOngkirStub(t)
+	user, cartItems := checkoutFixture()
+
+	tests := []struct {
+		name         string
+		service      string
+		shippingCost float64
+		want         float64
+		wantErr      error
+	}{
+		{name: "matching price", service: "REG", shippingCost: 18000, want: 18000},
+		{name: "client price too low", service: "REG", shippingCost: 1000, want: 18000, wantErr: errShippingCostChanged},
+		{name: "price of another service", service: "YES", shippingCost: 18000, want: 32000, wantErr: errShippingCostChanged},
+		{name: "unknown service", service: "OKE", shippingCost: 18000, wantErr: errServiceNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &CheckoutRequest{
+				ShippingMethod: "courier",
+				Courier:        "jne",
+				CourierService: tt.service,
+				ShippingCost:   tt.shippingCost,
+			}
+			got, err := courierShippingCost(user, cartItems, req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package payments
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/orders"
+	"gsm-motor/internal/payment"
+	"gsm-motor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListProviders returns the payment providers customers can choose from
+func ListProviders(c *gin.Context) {
+	var list []gin.H
+	for _, p := range payment.Available() {
+		list = append(list, gin.H{
+			"name":     p.Name(),
+			"channels": p.Channels(),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreatePaymentRequest represents the request to start a payment
+type CreatePaymentRequest struct {
+	Provider string `json:"provider" binding:"required"`
+	Channel  string `json:"channel" binding:"required"`
+}
+
+// CreatePayment starts a payment for an order with the chosen provider.
+// An open charge for the same provider and channel is returned instead of creating a new one.
+func CreatePayment(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	var req CreatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid"})
+		return
+	}
+
+	provider, err := payment.Get(req.Provider)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Metode pembayaran tidak tersedia"})
+		return
+	}
+	if !payment.SupportsChannel(provider, req.Channel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel pembayaran tidak tersedia"})
+		return
+	}
+
+	var order models.Order
+	if err := database.DB.Where("id = ? AND user_id = ?", id, user.ID).First(&order).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pesanan tidak ditemukan"})
+		return
+	}
+
+	if order.PaymentStatus == models.PaymentVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pembayaran sudah diverifikasi"})
+		return
+	}
+	if order.Status != models.OrderPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pesanan tidak dapat dibayar pada status ini"})
+		return
+	}
+
+	// Reuse an open charge so refreshing the page does not create new VA numbers
+	var existing models.PaymentCharge
+	if err := database.DB.
+		Where("order_id = ? AND provider = ? AND channel = ? AND status = ?", order.ID, req.Provider, req.Channel, models.ChargePending).
+		Order("created_at DESC").
+		First(&existing).Error; err == nil && existing.IsOpen() && existing.Amount == order.GetGrandTotal() {
+		c.JSON(http.StatusOK, gin.H{"charge": existing})
+		return
+	}
+
+	charge, err := provider.CreateCharge(c.Request.Context(), &order, req.Channel)
+	if err != nil {
+		log.Printf("Payment provider %s failed for order %s: %v", req.Provider, order.OrderNumber, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Gagal membuat pembayaran, silakan coba lagi"})
+		return
+	}
+
+	record := models.PaymentCharge{
+		OrderID:    order.ID,
+		Provider:   provider.Name(),
+		Channel:    charge.Channel,
+		ExternalID: charge.ExternalID,
+		Amount:     charge.Amount,
+		Status:     models.ChargePending,
+		ExpiresAt:  charge.ExpiresAt,
+	}
+	if charge.Reference != "" {
+		record.Reference = &charge.Reference
+	}
+	if charge.QRString != "" {
+		record.QRString = &charge.QRString
+	}
+	if charge.VANumber != "" {
+		record.VANumber = &charge.VANumber
+	}
+	if charge.Bank != "" {
+		record.Bank = &charge.Bank
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// The manual provider uses the order number as its ID, so keep a single row for it
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "provider"}, {Name: "external_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"amount", "status", "updated_at"}),
+		}).Create(&record).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_provider", provider.Name()).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan pembayaran"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"charge":       record,
+		"instructions": charge.Instructions,
+	})
+}
+
+// Webhook receives signed payment notifications from a provider and verifies
+// the order payment automatically once the charge is paid
+func Webhook(c *gin.Context) {
+	provider, err := payment.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+
+	notification, err := provider.ParseNotification(c.Request)
+	switch {
+	case errors.Is(err, payment.ErrInvalidSignature):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Signature tidak valid"})
+		return
+	case errors.Is(err, payment.ErrNotificationsUnsupported):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Notifikasi tidak valid"})
+		return
+	}
+
+	if err := applyNotification(provider.Name(), notification); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pembayaran tidak ditemukan"})
+			return
+		}
+		log.Printf("Payment webhook %s/%s failed: %v", provider.Name(), notification.ExternalID, err)
+		// A non-2xx response makes the provider retry the notification later
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses notifikasi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// applyNotification updates the charge and, for a paid charge, verifies the order
+// payment and moves the order to processing. Providers resend notifications, so
+// every step is safe to repeat.
+func applyNotification(providerName string, n *payment.Notification) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var charge models.PaymentCharge
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND external_id = ?", providerName, n.ExternalID).
+			First(&charge).Error; err != nil {
+			return err
+		}
+
+		// Already settled; nothing left to do
+		if charge.Status == models.ChargePaid || charge.Status == models.ChargeMismatch ||
+			charge.Status == models.ChargeRefundRequired {
+			return nil
+		}
+
+		charge.RawPayload = &n.Raw
+		if n.Reference != "" {
+			charge.Reference = &n.Reference
+		}
+
+		if n.Status != models.ChargePaid {
+			charge.Status = n.Status
+			return tx.Save(&charge).Error
+		}
+
+		now := time.Now()
+		charge.PaidAt = &now
+		charge.PaidAmount = &n.Amount
+
+		if math.Abs(n.Amount-charge.Amount) >= 1 {
+			// Money arrived, but not the amount asked for. Record it instead of
+			// failing, which would only make the provider retry forever.
+			charge.Status = models.ChargeMismatch
+			if err := tx.Save(&charge).Error; err != nil {
+				return err
+			}
+			var order models.Order
+			if err := tx.Select("id, order_number").First(&order, charge.OrderID).Error; err != nil {
+				return err
+			}
+			log.Printf("Payment for order %s via %s paid %.2f, expected %.2f; manual check required",
+				order.OrderNumber, providerName, n.Amount, charge.Amount)
+			go utils.SendPaymentMismatchToAdmins(order.OrderNumber, providerName, charge.Amount, n.Amount)
+			return nil
+		}
+
+		order, err := orders.LockOrder(tx, charge.OrderID)
+		if err != nil {
+			return err
+		}
+
+		if order.Status == models.OrderCancelled {
+			// Money arrived after the order was cancelled; mark it for a manual refund
+			charge.Status = models.ChargeRefundRequired
+			if err := tx.Save(&charge).Error; err != nil {
+				return err
+			}
+			log.Printf("Payment received for cancelled order %s via %s, refund required", order.OrderNumber, providerName)
+			go utils.SendPaymentRefundRequiredToAdmins(order.OrderNumber, providerName, n.Amount)
+			return nil
+		}
+
+		charge.Status = models.ChargePaid
+		if err := tx.Save(&charge).Error; err != nil {
+			return err
+		}
+
+		note := fmt.Sprintf("Pembayaran otomatis via %s (%s)", providerName, charge.Channel)
+		if order.PaymentStatus != models.PaymentVerified {
+			if err := order.SetPaymentStatus(tx, models.PaymentVerified, nil, note); err != nil {
+				return err
+			}
+		}
+		if order.Status == models.OrderPending {
+			return order.TransitionTo(tx, models.OrderProcessing, nil, note)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,274 @@
+package products
+
+import (
+	"strconv"
+	"strings"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/search"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Facet dimensions; each facet is counted with every filter except its own,
+// so the storefront can show how many results picking another value would give
+const (
+	facetCategory = "category"
+	facetPrice    = "price"
+	facetStock    = "stock"
+	facetTier     = "tier"
+	facetVehicle  = "vehicle"
+)
+
+// priceBuckets are the upper bounds (exclusive) of the price facet; the last bucket is open-ended
+var priceBuckets = []float64{50000, 100000, 250000, 500000, 1000000}
+
+// productFilters are the storefront listing filters
+type productFilters struct {
+	CategoryIDs       []uint
+	MinPrice          *float64
+	MaxPrice          *float64
+	IncludeOutOfStock bool
+	Tier              string // "3", "5" or "any": only products with a quantity discount
+	VehicleID         uint
+}
+
+// parseProductFilters reads the listing filters from the query string.
+// Categories can be given as ?category=a,b or repeated ?category=a&category=b.
+func parseProductFilters(c *gin.Context) productFilters {
+	var f productFilters
+
+	var slugs []string
+	for _, value := range c.QueryArray("category") {
+		for _, slug := range strings.Split(value, ",") {
+			if slug = strings.TrimSpace(slug); slug != "" {
+				slugs = append(slugs, slug)
+			}
+		}
+	}
+	if len(slugs) > 0 {
+		database.DB.Model(&models.Category{}).Where("slug IN ?", slugs).Pluck("id", &f.CategoryIDs)
+		if len(f.CategoryIDs) == 0 {
+			// Unknown slugs match nothing rather than everything
+			f.CategoryIDs = []uint{0}
+		}
+	}
+
+	if v, err := strconv.ParseFloat(c.Query("min_price"), 64); err == nil && v >= 0 {
+		f.MinPrice = &v
+	}
+	if v, err := strconv.ParseFloat(c.Query("max_price"), 64); err == nil && v >= 0 {
+		f.MaxPrice = &v
+	}
+
+	// Customers see in-stock products unless they ask for everything
+	f.IncludeOutOfStock = c.Query("in_stock") == "false" || c.Query("in_stock") == "0"
+
+	switch c.Query("tier") {
+	case "3", "5", "any":
+		f.Tier = c.Query("tier")
+	}
+
+	if v, _ := strconv.ParseUint(c.Query("vehicle_id"), 10, 32); v > 0 {
+		f.VehicleID = uint(v)
+	}
+
+	return f
+}
+
+// apply adds every filter except the one named by skip. Prices of products
+// with variants are matched on their cheapest variant, which is products.price.
+func (f productFilters) apply(db *gorm.DB, skip string) *gorm.DB {
+	if skip != facetCategory && len(f.CategoryIDs) > 0 {
+		db = db.Where("products.category_id IN ?", f.CategoryIDs)
+	}
+	if skip != facetPrice {
+		if f.MinPrice != nil {
+			db = db.Where("products.price >= ?", *f.MinPrice)
+		}
+		if f.MaxPrice != nil {
+			db = db.Where("products.price <= ?", *f.MaxPrice)
+		}
+	}
+	if skip != facetStock && !f.IncludeOutOfStock {
+		db = db.Where("products.stock > 0")
+	}
+	if skip != facetTier {
+		switch f.Tier {
+		case "3":
+			db = db.Where("products.price_3_items > 0")
+		case "5":
+			db = db.Where("products.price_5_items > 0")
+		case "any":
+			db = db.Where("(products.price_3_items > 0 OR products.price_5_items > 0)")
+		}
+	}
+	if skip != facetVehicle && f.VehicleID > 0 {
+		db = db.Scopes(models.FitsVehicle(f.VehicleID))
+	}
+	return db
+}
+
+// CategoryFacet is the number of matching products in a category
+type CategoryFacet struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Count int64  `json:"count"`
+}
+
+// PriceFacet is the number of matching products in a price range; Max is nil for the last bucket
+type PriceFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int64    `json:"count"`
+}
+
+// VehicleFacet is the number of matching products that fit a vehicle
+type VehicleFacet struct {
+	ID    uint   `json:"id"`
+	Label string `json:"label"`
+	Count int64  `json:"count"`
+}
+
+// Facets is the facet block of the product listing
+type Facets struct {
+	Categories []CategoryFacet `json:"categories"`
+	Prices     []PriceFacet    `json:"prices"`
+	Vehicles   []VehicleFacet  `json:"vehicles"`
+	Stock      struct {
+		InStock    int64 `json:"in_stock"`
+		OutOfStock int64 `json:"out_of_stock"`
+	} `json:"stock"`
+	Tier struct {
+		Any   int64 `json:"any"`
+		Three int64 `json:"3"`
+		Five  int64 `json:"5"`
+	} `json:"tier"`
+}
+
+// buildFacets counts the listing per facet using the same filters and search as the list
+func buildFacets(f productFilters, q search.Query) (*Facets, error) {
+	base := func(skip string) *gorm.DB {
+		db := f.apply(database.DB.Model(&models.Product{}), skip)
+		return search.Filter(db, q)
+	}
+
+	facets := &Facets{
+		Categories: []CategoryFacet{},
+		Prices:     []PriceFacet{},
+		Vehicles:   []VehicleFacet{},
+	}
+
+	// Categories
+	var categoryRows []CategoryFacet
+	if err := base(facetCategory).
+		Select("categories.id, categories.name, categories.slug, COUNT(*) AS count").
+		Joins("JOIN categories ON categories.id = products.category_id").
+		Group("categories.id, categories.name, categories.slug").
+		Order("categories.name ASC").
+		Scan(&categoryRows).Error; err != nil {
+		return nil, err
+	}
+	if categoryRows != nil {
+		facets.Categories = categoryRows
+	}
+
+	// Price buckets, counted in one query
+	var cases strings.Builder
+	cases.WriteString("CASE")
+	for i, upper := range priceBuckets {
+		cases.WriteString(" WHEN products.price < " + strconv.FormatFloat(upper, 'f', 0, 64) + " THEN " + strconv.Itoa(i))
+	}
+	cases.WriteString(" ELSE " + strconv.Itoa(len(priceBuckets)) + " END")
+
+	var priceRows []struct {
+		Bucket int
+		Count  int64
+	}
+	if err := base(facetPrice).
+		Select(cases.String() + " AS bucket, COUNT(*) AS count").
+		Group("bucket").
+		Scan(&priceRows).Error; err != nil {
+		return nil, err
+	}
+	counts := make([]int64, len(priceBuckets)+1)
+	for _, row := range priceRows {
+		counts[row.Bucket] = row.Count
+	}
+	lower := float64(0)
+	for i := range counts {
+		bucket := PriceFacet{Min: lower, Count: counts[i]}
+		if i < len(priceBuckets) {
+			upper := priceBuckets[i]
+			bucket.Max = &upper
+			lower = upper
+		}
+		facets.Prices = append(facets.Prices, bucket)
+	}
+
+	// Stock
+	var stockRow struct {
+		InStock    int64
+		OutOfStock int64
+	}
+	if err := base(facetStock).
+		Select("COALESCE(SUM(CASE WHEN products.stock > 0 THEN 1 ELSE 0 END), 0) AS in_stock, COALESCE(SUM(CASE WHEN products.stock > 0 THEN 0 ELSE 1 END), 0) AS out_of_stock").
+		Scan(&stockRow).Error; err != nil {
+		return nil, err
+	}
+	facets.Stock.InStock, facets.Stock.OutOfStock = stockRow.InStock, stockRow.OutOfStock
+
+	// Quantity discount tiers
+	var tierRow struct {
+		AnyTier int64
+		Three   int64
+		Five    int64
+	}
+	if err := base(facetTier).
+		Select("COALESCE(SUM(CASE WHEN products.price_3_items > 0 OR products.price_5_items > 0 THEN 1 ELSE 0 END), 0) AS any_tier, " +
+			"COALESCE(SUM(CASE WHEN products.price_3_items > 0 THEN 1 ELSE 0 END), 0) AS three, " +
+			"COALESCE(SUM(CASE WHEN products.price_5_items > 0 THEN 1 ELSE 0 END), 0) AS five").
+		Scan(&tierRow).Error; err != nil {
+		return nil, err
+	}
+	facets.Tier.Any, facets.Tier.Three, facets.Tier.Five = tierRow.AnyTier, tierRow.Three, tierRow.Five
+
+	// Fitment: the vehicles with the most matching parts
+	var vehicleRows []struct {
+		VehicleID uint
+		Count     int64
+	}
+	if err := base(facetVehicle).
+		Select("pf.vehicle_id, COUNT(DISTINCT products.id) AS count").
+		Joins("JOIN product_fitments pf ON pf.product_id = products.id").
+		Group("pf.vehicle_id").
+		Order("count DESC").
+		Limit(20).
+		Scan(&vehicleRows).Error; err != nil {
+		return nil, err
+	}
+	if len(vehicleRows) > 0 {
+		ids := make([]uint, len(vehicleRows))
+		for i, row := range vehicleRows {
+			ids[i] = row.VehicleID
+		}
+		var vehicles []models.Vehicle
+		if err := database.DB.Where("id IN ?", ids).Find(&vehicles).Error; err != nil {
+			return nil, err
+		}
+		byID := make(map[uint]*models.Vehicle, len(vehicles))
+		for i := range vehicles {
+			byID[vehicles[i].ID] = &vehicles[i]
+		}
+		for _, row := range vehicleRows {
+			if v, ok := byID[row.VehicleID]; ok {
+				facets.Vehicles = append(facets.Vehicles, VehicleFacet{ID: v.ID, Label: v.Label(), Count: row.Count})
+			}
+		}
+	}
+
+	return facets, nil
+}
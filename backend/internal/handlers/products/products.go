@@ -6,17 +6,25 @@ import (
 
 	"gsm-motor/internal/database"
 	"gsm-motor/internal/models"
+	"gsm-motor/internal/search"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// ListProducts returns a paginated list of products with optional search and filtering
+// ListProducts returns a paginated list of products with optional search, filters and facet counts
 func ListProducts(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "20"))
-	search := c.Query("search")
-	categorySlug := c.Query("category")
-	sortBy := c.DefaultQuery("sort", "latest")
+	searchText := c.Query("search")
+	filters := parseProductFilters(c)
+	sortBy := c.Query("sort")
+	if sortBy == "" {
+		sortBy = "latest"
+		if searchText != "" {
+			sortBy = "relevance"
+		}
+	}
 
 	if page < 1 {
 		page = 1
@@ -31,28 +39,17 @@ func ListProducts(c *gin.Context) {
 		Preload("Category").
 		Preload("Images")
 
-	// Apply search
-	if search != "" {
-		query = query.Scopes(models.ProductSearch(search))
-	}
-
-	// Apply category filter
-	if categorySlug != "" {
-		var category models.Category
-		if err := database.DB.Where("slug = ?", categorySlug).First(&category).Error; err == nil {
-			query = query.Where("category_id = ?", category.ID)
-		}
-	}
+	// Apply category, price, stock, tier discount and fitment filters
+	query = filters.apply(query, "")
 
-	// Only show in-stock products to customers
-	query = query.Where("stock > 0")
-
-	// Get total count
-	var total int64
-	query.Count(&total)
+	// Apply search and get total count; a query with a typo is retried corrected
+	query, result := search.Match(query, searchText)
+	total := result.Total
 
 	// Apply sorting
 	switch sortBy {
+	case "relevance":
+		query = search.OrderByRelevance(query, result.Query)
 	case "price_asc":
 		query = query.Order("price ASC")
 	case "price_desc":
@@ -70,13 +67,27 @@ func ListProducts(c *gin.Context) {
 		return
 	}
 
+	// Show the price range and total stock of products sold in variants
+	if err := models.AttachVariantSummaries(database.DB, products); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat produk"})
+		return
+	}
+
+	facets, err := buildFacets(filters, result.Query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat produk"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"data": products,
+		"data":   products,
+		"facets": facets,
 		"meta": gin.H{
-			"current_page": page,
-			"per_page":     perPage,
-			"total":        total,
-			"total_pages":  (total + int64(perPage) - 1) / int64(perPage),
+			"current_page":     page,
+			"per_page":         perPage,
+			"total":            total,
+			"total_pages":      (total + int64(perPage) - 1) / int64(perPage),
+			"search_corrected": result.Corrected,
 		},
 	})
 }
@@ -89,6 +100,12 @@ func GetProduct(c *gin.Context) {
 	if err := database.DB.
 		Preload("Category").
 		Preload("Images").
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Order("price ASC, id ASC")
+		}).
+		Preload("Vehicles", func(db *gorm.DB) *gorm.DB {
+			return db.Order("brand ASC, model ASC, year_start ASC")
+		}).
 		Where("slug = ?", slug).
 		First(&product).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Produk tidak ditemukan"})
@@ -103,6 +120,11 @@ func GetProduct(c *gin.Context) {
 		Limit(4).
 		Find(&related)
 
+	products := []models.Product{product}
+	models.AttachVariantSummaries(database.DB, products)
+	product = products[0]
+	models.AttachVariantSummaries(database.DB, related)
+
 	c.JSON(http.StatusOK, gin.H{
 		"product": product,
 		"related": related,
@@ -117,15 +139,23 @@ func SearchProducts(c *gin.Context) {
 		return
 	}
 
+	db := database.DB.Model(&models.Product{}).Where("stock > 0")
+	if vehicleID, _ := strconv.ParseUint(c.Query("vehicle_id"), 10, 32); vehicleID > 0 {
+		db = db.Scopes(models.FitsVehicle(uint(vehicleID)))
+	}
+
+	db, result := search.Match(db, query)
+
 	var products []models.Product
-	database.DB.
+	search.OrderByRelevance(db, result.Query).
 		Select("id, name, slug, price, image_path").
-		Scopes(models.ProductSearch(query)).
-		Where("stock > 0").
 		Limit(10).
 		Find(&products)
 
-	c.JSON(http.StatusOK, gin.H{"results": products})
+	c.JSON(http.StatusOK, gin.H{
+		"results":   products,
+		"corrected": result.Corrected,
+	})
 }
 
 // GetCategories returns all categories
@@ -168,6 +198,8 @@ func GetProductsByCategory(c *gin.Context) {
 		Limit(perPage).
 		Find(&products)
 
+	models.AttachVariantSummaries(database.DB, products)
+
 	c.JSON(http.StatusOK, gin.H{
 		"category": category,
 		"products": products,
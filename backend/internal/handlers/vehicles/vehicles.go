@@ -0,0 +1,145 @@
+package vehicles
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/middleware"
+	"gsm-motor/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListVehicles returns the fitment catalog, optionally filtered by brand, search text or year
+func ListVehicles(c *gin.Context) {
+	query := database.DB.Model(&models.Vehicle{})
+
+	if brand := strings.TrimSpace(c.Query("brand")); brand != "" {
+		query = query.Where("brand = ?", brand)
+	}
+	// Match "Vario 125" or "honda nmax" against brand + model
+	for _, term := range strings.Fields(c.Query("q")) {
+		query = query.Where("CONCAT(brand, ' ', model) LIKE ?", "%"+term+"%")
+	}
+	if year, err := strconv.Atoi(c.Query("year")); err == nil && year > 0 {
+		query = query.Where("year_start <= ? AND (year_end IS NULL OR year_end >= ?)", year, year)
+	}
+
+	var vehicles []models.Vehicle
+	query.Order("brand ASC, model ASC, year_start ASC").Limit(200).Find(&vehicles)
+
+	c.JSON(http.StatusOK, gin.H{"data": vehicles})
+}
+
+// ListBrands returns the distinct vehicle brands for the bike picker
+func ListBrands(c *gin.Context) {
+	var brands []string
+	database.DB.Model(&models.Vehicle{}).
+		Distinct("brand").
+		Order("brand ASC").
+		Pluck("brand", &brands)
+
+	c.JSON(http.StatusOK, gin.H{"data": brands})
+}
+
+// SaveVehicleRequest represents a bike saved on the customer's profile
+type SaveVehicleRequest struct {
+	VehicleID uint   `json:"vehicle_id" binding:"required"`
+	Nickname  string `json:"nickname" binding:"max=100"`
+	Year      *int   `json:"year"`
+}
+
+// ListMyVehicles returns the bikes saved by the current user
+func ListMyVehicles(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var saved []models.UserVehicle
+	database.DB.
+		Preload("Vehicle").
+		Where("user_id = ?", user.ID).
+		Order("created_at ASC").
+		Find(&saved)
+
+	c.JSON(http.StatusOK, gin.H{"data": saved})
+}
+
+// AddMyVehicle saves a bike on the current user's profile
+func AddMyVehicle(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req SaveVehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data tidak valid"})
+		return
+	}
+
+	var vehicle models.Vehicle
+	if err := database.DB.First(&vehicle, req.VehicleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kendaraan tidak ditemukan"})
+		return
+	}
+
+	if req.Year != nil && !vehicle.CoversYear(*req.Year) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun tidak sesuai dengan tipe kendaraan"})
+		return
+	}
+
+	var existing models.UserVehicle
+	if err := database.DB.Where("user_id = ? AND vehicle_id = ?", user.ID, vehicle.ID).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kendaraan sudah tersimpan"})
+		return
+	}
+
+	saved := models.UserVehicle{
+		UserID:    user.ID,
+		VehicleID: vehicle.ID,
+		Year:      req.Year,
+	}
+	if nickname := strings.TrimSpace(req.Nickname); nickname != "" {
+		saved.Nickname = &nickname
+	}
+
+	if err := database.DB.Create(&saved).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan kendaraan"})
+		return
+	}
+	saved.Vehicle = &vehicle
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Kendaraan berhasil disimpan",
+		"vehicle": saved,
+	})
+}
+
+// RemoveMyVehicle removes a saved bike from the current user's profile
+func RemoveMyVehicle(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", id, user.ID).Delete(&models.UserVehicle{})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kendaraan tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Kendaraan berhasil dihapus"})
+}
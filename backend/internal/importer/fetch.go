@@ -0,0 +1,113 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gsm-motor/internal/config"
+)
+
+// imageClient downloads images referenced by URL. It refuses to connect to
+// loopback and private addresses so an import cannot reach internal services.
+var imageClient = &http.Client{
+	Timeout: 20 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+					ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+					return fmt.Errorf("address %s is not allowed", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+	},
+}
+
+func isURL(ref string) bool {
+	lower := strings.ToLower(ref)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return false
+	}
+	u, err := url.Parse(ref)
+	return err == nil && u.Host != ""
+}
+
+func maxImageBytes() int64 {
+	if config.AppConfig != nil && config.AppConfig.MaxImageSize > 0 {
+		return config.AppConfig.MaxImageSize
+	}
+	return 10 << 20
+}
+
+// downloadImage fetches an image URL. The caller closes the body.
+func downloadImage(ref string) (io.ReadCloser, error) {
+	resp, err := imageClient.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxImageBytes() {
+		resp.Body.Close()
+		return nil, errors.New("image is too large")
+	}
+	return resp.Body, nil
+}
+
+// parseAmount reads a price written either plainly ("150000", "150000.50") or
+// in Indonesian notation ("Rp 150.000", "150.000,50").
+func parseAmount(raw string) (float64, bool) {
+	s := strings.TrimSpace(raw)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "Rp"), "rp")
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if s == "" {
+		return 0, false
+	}
+
+	lastDot, lastComma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case lastComma > lastDot:
+		// 150.000,50: dots group thousands, the comma is the decimal separator
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	case lastDot >= 0 && strings.Count(s, ".") > 1:
+		// 1.500.000
+		s = strings.ReplaceAll(s, ".", "")
+	case lastDot >= 0 && len(s)-lastDot-1 == 3 && lastComma < 0:
+		// 150.000 is a thousands group; rupiah prices have no three-digit fraction
+		s = strings.ReplaceAll(s, ".", "")
+	default:
+		s = strings.ReplaceAll(s, ",", "")
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+// parseCount reads a whole number such as a stock level or weight in grams
+func parseCount(raw string) (int, bool) {
+	v, ok := parseAmount(raw)
+	if !ok || v != float64(int(v)) {
+		return 0, false
+	}
+	return int(v), true
+}
@@ -0,0 +1,657 @@
+// Package importer runs product spreadsheet imports in the background and
+// produces a row-by-row validation report.
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/inventory"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/utils"
+
+	"github.com/gosimple/slug"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxRows caps the data rows of one import
+const MaxRows = 20000
+
+// progressEvery is how often the processed row count is saved while running
+const progressEvery = 50
+
+// staleAfter is how long a running import may go without saving progress
+// before it is taken as interrupted, e.g. by a restart
+const staleAfter = 30 * time.Minute
+
+// Row actions in the report
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+)
+
+// RowResult is the outcome of one spreadsheet row. Row is the line number in the
+// file, counting the header as line 1.
+type RowResult struct {
+	Row       int      `json:"row"`
+	Key       string   `json:"key"` // SKU, slug or name identifying the row
+	Action    string   `json:"action,omitempty"`
+	ProductID *uint    `json:"product_id,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// OK reports whether the row was (or in a dry run, would be) imported
+func (r *RowResult) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// columnAliases maps accepted header names, English and Indonesian, to fields
+var columnAliases = map[string]string{
+	"sku": "sku", "kode": "sku",
+	"slug": "slug",
+	"name": "name", "nama": "name", "nama_produk": "name",
+	"category": "category", "kategori": "category",
+	"price": "price", "harga": "price",
+	"price_3_items": "price_3_items", "price_3": "price_3_items", "harga_3": "price_3_items",
+	"price_5_items": "price_5_items", "price_5": "price_5_items", "harga_5": "price_5_items",
+	"stock": "stock", "stok": "stock",
+	"weight": "weight", "berat": "weight",
+	"description": "description", "deskripsi": "description",
+	"submitted_by": "submitted_by",
+	"images":       "images", "image": "images", "gambar": "images", "image_urls": "images",
+}
+
+// Run claims a queued import and processes it. It returns without doing
+// anything when another worker already claimed the job.
+func Run(ctx context.Context, jobID uint) error {
+	now := time.Now()
+	claim := database.DB.Model(&models.ImportJob{}).
+		Where("id = ? AND status = ?", jobID, models.ImportQueued).
+		Updates(map[string]interface{}{"status": models.ImportRunning, "started_at": now})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return claim.Error
+	}
+
+	var job models.ImportJob
+	if err := database.DB.First(&job, jobID).Error; err != nil {
+		return err
+	}
+
+	results, err := process(ctx, &job)
+
+	updates := map[string]interface{}{"finished_at": time.Now(), "processed_rows": job.ProcessedRows}
+	if err != nil {
+		log.Printf("Import %d failed: %v", job.ID, err)
+		updates["status"] = models.ImportFailed
+		updates["error"] = err.Error()
+	} else {
+		updates["status"] = models.ImportCompleted
+	}
+	if results != nil {
+		report, _ := json.Marshal(results)
+		updates["report"] = string(report)
+		created, updated, failed := tally(results)
+		updates["created_count"] = created
+		updates["updated_count"] = updated
+		updates["failed_count"] = failed
+	}
+	return database.DB.Model(&models.ImportJob{}).Where("id = ?", job.ID).Updates(updates).Error
+}
+
+// RunQueued processes imports still waiting, e.g. after a restart
+func RunQueued(ctx context.Context) (int, error) {
+	var ids []uint
+	if err := database.DB.Model(&models.ImportJob{}).
+		Where("status = ?", models.ImportQueued).
+		Order("id ASC").
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			return i, nil
+		}
+		if err := Run(ctx, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// FailStale marks running imports that stopped saving progress as failed. The
+// rows before the interruption may already be applied, so they are not run
+// again; the admin can upload the file once more.
+func FailStale() (int64, error) {
+	now := time.Now()
+	result := database.DB.Model(&models.ImportJob{}).
+		Where("status = ? AND updated_at < ?", models.ImportRunning, now.Add(-staleAfter)).
+		Updates(map[string]interface{}{
+			"status":      models.ImportFailed,
+			"error":       "import was interrupted before it finished",
+			"finished_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+func tally(results []RowResult) (created, updated, failed int) {
+	for _, r := range results {
+		switch {
+		case !r.OK():
+			failed++
+		case r.Action == ActionCreate:
+			created++
+		case r.Action == ActionUpdate:
+			updated++
+		}
+	}
+	return created, updated, failed
+}
+
+// process validates and, unless it is a dry run, imports every row
+func process(ctx context.Context, job *models.ImportJob) ([]RowResult, error) {
+	rows, err := ReadRows(job.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("file cannot be read: %w", err)
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("file has no data rows")
+	}
+	if len(rows)-1 > MaxRows {
+		return nil, fmt.Errorf("file has more than %d rows", MaxRows)
+	}
+
+	columns, err := mapColumns(rows[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var images *zip.ReadCloser
+	if job.ImagesPath != nil {
+		if images, err = zip.OpenReader(*job.ImagesPath); err != nil {
+			return nil, fmt.Errorf("images zip cannot be read: %w", err)
+		}
+		defer images.Close()
+	}
+
+	var actor *models.User
+	if job.CreatedBy != nil {
+		var user models.User
+		if database.DB.First(&user, *job.CreatedBy).Error == nil {
+			actor = &user
+		}
+	}
+
+	database.DB.Model(job).UpdateColumn("total_rows", len(rows)-1)
+
+	imp := &productImport{
+		jobID:      job.ID,
+		dryRun:     job.DryRun,
+		actor:      actor,
+		images:     images,
+		categories: make(map[string]*models.Category),
+		seen:       make(map[string]int),
+	}
+
+	results := make([]RowResult, 0, len(rows)-1)
+	for i, cells := range rows[1:] {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+
+		record := make(map[string]string, len(columns))
+		for col, field := range columns {
+			if col < len(cells) {
+				record[field] = strings.TrimSpace(cells[col])
+			}
+		}
+		if isBlank(record) {
+			continue
+		}
+
+		results = append(results, imp.importRow(i+2, record))
+
+		job.ProcessedRows = i + 1
+		if job.ProcessedRows%progressEvery == 0 {
+			// Update also bumps updated_at, which FailStale reads as a heartbeat
+			database.DB.Model(job).Update("processed_rows", job.ProcessedRows)
+		}
+	}
+	job.ProcessedRows = len(rows) - 1
+	return results, nil
+}
+
+// mapColumns maps column positions to field names from the header row
+func mapColumns(header []string) (map[int]string, error) {
+	columns := make(map[int]string)
+	found := make(map[string]bool)
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\xEF\xBB\xBF")))
+		key = strings.NewReplacer(" ", "_", "-", "_", "+", "").Replace(key)
+		if field, ok := columnAliases[key]; ok && !found[field] {
+			columns[i] = field
+			found[field] = true
+		}
+	}
+	if !found["sku"] && !found["slug"] && !found["name"] {
+		return nil, errors.New("header needs at least one of the columns sku, slug or name")
+	}
+	return columns, nil
+}
+
+func isBlank(record map[string]string) bool {
+	for _, v := range record {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// productImport holds the state shared by the rows of one import
+type productImport struct {
+	jobID      uint
+	dryRun     bool
+	actor      *models.User
+	images     *zip.ReadCloser
+	categories map[string]*models.Category // Lookup cache by lowercased name or slug
+	seen       map[string]int              // Row that first used a SKU or slug in this file
+}
+
+// importRow validates one row and applies it unless this is a dry run
+func (imp *productImport) importRow(rowNum int, record map[string]string) RowResult {
+	result := RowResult{Row: rowNum, Key: firstNonEmpty(record["sku"], record["slug"], record["name"])}
+	addError := func(format string, args ...interface{}) {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+	}
+
+	// The same product may only appear once per file
+	for _, key := range []string{"sku:" + strings.ToUpper(record["sku"]), "slug:" + record["slug"]} {
+		if strings.HasSuffix(key, ":") {
+			continue
+		}
+		if first, ok := imp.seen[key]; ok {
+			addError("Duplikat dengan baris %d", first)
+			return result
+		}
+		imp.seen[key] = rowNum
+	}
+
+	product, err := imp.findProduct(record["sku"], record["slug"])
+	if err != nil {
+		addError("%s", err.Error())
+		return result
+	}
+	priceChange := &models.ProductPriceHistory{Source: models.PriceSourceImport, ReferenceID: &imp.jobID}
+	if imp.actor != nil {
+		priceChange.ChangedBy = &imp.actor.ID
+	}
+	result.Action = ActionUpdate
+	if product == nil {
+		result.Action = ActionCreate
+		product = &models.Product{Weight: 500, ReorderPoint: models.DefaultReorderPoint}
+	} else {
+		result.ProductID = &product.ID
+	}
+
+	var variantCount int64
+	if product.ID != 0 {
+		database.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+	}
+
+	// Apply the cells that are filled in; empty cells leave existing values alone.
+	// Only those fields are written to an existing product.
+	var filled []string
+	if sku := record["sku"]; sku != "" {
+		product.SKU = &sku
+		filled = append(filled, "SKU")
+	}
+	if name := record["name"]; name != "" {
+		product.Name = name
+		filled = append(filled, "Name")
+	}
+	if cat := record["category"]; cat != "" {
+		category := imp.findCategory(cat)
+		if category == nil {
+			addError("Kategori %q tidak ditemukan", cat)
+		} else {
+			product.CategoryID = category.ID
+			filled = append(filled, "CategoryID")
+		}
+	}
+
+	if raw := record["price"]; raw != "" {
+		if variantCount > 0 {
+			addError("Harga produk bervarian diatur per varian")
+		} else if price, ok := parseAmount(raw); !ok || price <= 0 {
+			addError("Harga %q tidak valid", raw)
+		} else {
+			product.Price = price
+			filled = append(filled, "Price")
+		}
+	}
+	tiers := []struct {
+		column string // Spreadsheet column
+		field  string // Product field written
+		target **float64
+	}{
+		{"price_3_items", "Price3Items", &product.Price3Items},
+		{"price_5_items", "Price5Items", &product.Price5Items},
+	}
+	for _, tier := range tiers {
+		raw := record[tier.column]
+		if raw == "" {
+			continue
+		}
+		amount, ok := parseAmount(raw)
+		if !ok || amount < 0 {
+			addError("Kolom %s %q tidak valid", tier.column, raw)
+			continue
+		}
+		if amount == 0 {
+			*tier.target = nil
+		} else {
+			*tier.target = &amount
+		}
+		filled = append(filled, tier.field)
+	}
+	if product.Price3Items != nil && *product.Price3Items >= product.Price {
+		addError("Harga 3 item harus lebih murah dari harga satuan")
+	}
+	if product.Price5Items != nil && *product.Price5Items >= product.Price {
+		addError("Harga 5 item harus lebih murah dari harga satuan")
+	}
+
+	var stock *int
+	if raw := record["stock"]; raw != "" {
+		n, ok := parseCount(raw)
+		switch {
+		case variantCount > 0:
+			addError("Stok produk bervarian diatur per varian")
+		case !ok || n < 0:
+			addError("Stok %q tidak valid", raw)
+		default:
+			stock = &n
+		}
+	}
+	if raw := record["weight"]; raw != "" {
+		if n, ok := parseCount(raw); !ok || n <= 0 {
+			addError("Berat %q tidak valid", raw)
+		} else {
+			product.Weight = n
+			filled = append(filled, "Weight")
+		}
+	}
+	if desc := record["description"]; desc != "" {
+		product.Description = &desc
+		filled = append(filled, "Description")
+	}
+	if submittedBy := record["submitted_by"]; submittedBy != "" {
+		product.SubmittedBy = &submittedBy
+		filled = append(filled, "SubmittedBy")
+	}
+
+	if product.ID == 0 {
+		if product.Name == "" {
+			addError("Nama wajib diisi untuk produk baru")
+		}
+		if product.CategoryID == 0 && record["category"] == "" {
+			addError("Kategori wajib diisi untuk produk baru")
+		}
+		if product.Price <= 0 && record["price"] == "" {
+			addError("Harga wajib diisi untuk produk baru")
+		}
+	}
+
+	imageRefs := splitList(record["images"])
+	for _, ref := range imageRefs {
+		if msg := imp.checkImage(ref); msg != "" {
+			addError("%s", msg)
+		}
+	}
+
+	if !result.OK() || imp.dryRun {
+		return result
+	}
+
+	// Images are processed before the write so a broken image fails the row
+	processor := utils.NewImageProcessor()
+	var saved []string
+	for _, ref := range imageRefs {
+		imagePath, err := imp.saveImage(processor, ref)
+		if err != nil {
+			for _, imagePath := range saved {
+				processor.DeleteImage(imagePath)
+			}
+			addError("Gambar %s gagal diproses: %v", ref, err)
+			return result
+		}
+		saved = append(saved, imagePath)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if product.ID == 0 {
+			product.Slug = record["slug"]
+			if product.Slug == "" {
+				product.Slug = slug.Make(product.Name)
+			}
+			unique, err := uniqueSlug(tx, product.Slug)
+			if err != nil {
+				return err
+			}
+			product.Slug = unique
+			if err := tx.Create(product).Error; err != nil {
+				return err
+			}
+		} else {
+			// Read under the lock, so a sale or price change made since the row
+			// was looked up is neither reverted nor missing from the history
+			var current models.Product
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Select("id, price, price_3_items, price_5_items").
+				First(&current, product.ID).Error; err != nil {
+				return err
+			}
+			oldPrice := current.Price
+			priceChange.OldPrice, priceChange.OldPrice3Items, priceChange.OldPrice5Items = &oldPrice, current.Price3Items, current.Price5Items
+
+			if len(filled) > 0 {
+				if err := tx.Model(product).Select(append(filled, "UpdatedAt")).Updates(product).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.First(product, product.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		// Products with variants are priced per variant and the import leaves those alone
+		if variantCount == 0 {
+			priceChange.ProductID = product.ID
+			priceChange.NewPrice, priceChange.NewPrice3Items, priceChange.NewPrice5Items = product.Price, product.Price3Items, product.Price5Items
+			if err := models.RecordPriceChange(tx, priceChange); err != nil {
+				return err
+			}
+		}
+
+		if stock != nil {
+			if _, err := inventory.Set(tx, product.ID, nil, *stock, models.MovementAdjustment, imp.actor, "Import produk"); err != nil {
+				return err
+			}
+		}
+
+		for _, imagePath := range saved {
+			if err := tx.Create(&models.ProductImage{ProductID: product.ID, ImagePath: imagePath}).Error; err != nil {
+				return err
+			}
+			if product.ImagePath == nil {
+				imagePath := imagePath
+				product.ImagePath = &imagePath
+				if err := tx.Model(product).Update("image_path", imagePath).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, imagePath := range saved {
+			processor.DeleteImage(imagePath)
+		}
+		addError("Gagal menyimpan produk: %v", err)
+		return result
+	}
+
+	result.ProductID = &product.ID
+	return result
+}
+
+// findProduct looks the row up by SKU first, then by slug. It returns nil when
+// the row is a new product.
+func (imp *productImport) findProduct(sku, productSlug string) (*models.Product, error) {
+	var product models.Product
+	if sku != "" {
+		err := database.DB.Where("sku = ?", sku).First(&product).Error
+		if err == nil {
+			return &product, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		// A SKU of another (possibly deleted) product or of a variant cannot be reused
+		if inUse, err := models.SKUInUse(database.DB, sku, 0, 0); err != nil {
+			return nil, err
+		} else if inUse {
+			return nil, fmt.Errorf("SKU %s sudah digunakan oleh varian atau produk terhapus", sku)
+		}
+	}
+	if productSlug != "" {
+		err := database.DB.Where("slug = ?", productSlug).First(&product).Error
+		if err == nil {
+			if sku != "" && product.SKU != nil && *product.SKU != sku {
+				return nil, fmt.Errorf("Slug %s sudah memakai SKU %s", productSlug, *product.SKU)
+			}
+			return &product, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// findCategory matches a category by slug or name, case-insensitively
+func (imp *productImport) findCategory(value string) *models.Category {
+	key := strings.ToLower(value)
+	if category, ok := imp.categories[key]; ok {
+		return category
+	}
+
+	var category models.Category
+	var found *models.Category
+	if err := database.DB.Where("slug = ? OR LOWER(name) = ?", slug.Make(value), key).First(&category).Error; err == nil {
+		found = &category
+	}
+	imp.categories[key] = found
+	return found
+}
+
+// checkImage validates an image reference without fetching it
+func (imp *productImport) checkImage(ref string) string {
+	if isURL(ref) {
+		return ""
+	}
+	if imp.images == nil {
+		return fmt.Sprintf("Gambar %s bukan URL dan tidak ada file zip gambar", ref)
+	}
+	if imp.zipEntry(ref) == nil {
+		return fmt.Sprintf("Gambar %s tidak ditemukan di zip", ref)
+	}
+	return ""
+}
+
+// saveImage downloads or unzips an image and stores it like an uploaded product image
+func (imp *productImport) saveImage(processor *utils.ImageProcessor, ref string) (string, error) {
+	var src io.ReadCloser
+	var err error
+	if isURL(ref) {
+		src, err = downloadImage(ref)
+	} else if entry := imp.zipEntry(ref); entry != nil {
+		src, err = entry.Open()
+	} else {
+		err = errors.New("not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	return processor.ProcessReaderAndSave(io.LimitReader(src, maxImageBytes()), "products", true)
+}
+
+// zipEntry finds a file in the images zip by its path or, failing that, its base name
+func (imp *productImport) zipEntry(name string) *zip.File {
+	name = strings.TrimPrefix(path.Clean(strings.ReplaceAll(name, "\\", "/")), "/")
+	for _, f := range imp.images.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	for _, f := range imp.images.File {
+		if !f.FileInfo().IsDir() && strings.EqualFold(path.Base(f.Name), path.Base(name)) {
+			return f
+		}
+	}
+	return nil
+}
+
+// uniqueSlug appends a number to s until no product (deleted ones included) uses it
+func uniqueSlug(tx *gorm.DB, s string) (string, error) {
+	if s == "" {
+		s = "produk"
+	}
+	candidate := s
+	for i := 2; ; i++ {
+		var count int64
+		if err := tx.Model(&models.Product{}).Unscoped().Where("slug = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = s + "-" + strconv.Itoa(i)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitList splits a cell holding several values separated by commas, semicolons or new lines
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '\r'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
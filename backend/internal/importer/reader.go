@@ -0,0 +1,289 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxSheetPartSize caps how much of one zip entry is read, so a crafted file cannot exhaust memory
+const maxSheetPartSize = 100 << 20
+
+// ReadRows reads every row of a .csv or .xlsx file. For workbooks only the first sheet is read.
+func ReadRows(filename string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return readCSV(filename)
+	case ".xlsx":
+		return readXLSX(filename)
+	}
+	return nil, errors.New("unsupported file type")
+}
+
+// readCSV reads a CSV file separated by commas or, as Excel writes it in
+// Indonesian locales, by semicolons
+func readCSV(filename string) ([][]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+
+	firstLine := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = ';'
+	}
+	return r.ReadAll()
+}
+
+// readXLSX reads the first sheet of a workbook. Only what an import needs is
+// supported: shared, inline and plain string cells, numbers and booleans.
+func readXLSX(filename string) ([][]string, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid xlsx file: %w", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if shared, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("sheet %s not found", sheetPath)
+	}
+	return readSheet(f, shared)
+}
+
+// firstSheetPath resolves the first sheet of the workbook through its relationships
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+
+	if err := decodePart(files, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if err := decodePart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("workbook has no sheets")
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return "", errors.New("first sheet not found")
+}
+
+func decodePart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%s not found", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, maxSheetPartSize)).Decode(v)
+}
+
+// readSharedStrings returns the shared string table, joining the runs of rich text
+func readSharedStrings(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var table []string
+	var current strings.Builder
+	inText, inPhonetic := false, false
+
+	dec := xml.NewDecoder(io.LimitReader(rc, maxSheetPartSize))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				table = append(table, current.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic = false
+			}
+		case xml.CharData:
+			if inText && !inPhonetic {
+				current.Write(t)
+			}
+		}
+	}
+}
+
+// readSheet streams the sheet XML into rows, placing cells by their reference
+// so skipped empty cells keep the columns aligned
+func readSheet(f *zip.File, shared []string) ([][]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var rows [][]string
+	var row []string
+	var cellType, cellRef string
+	var value strings.Builder
+	inValue := false
+
+	dec := xml.NewDecoder(io.LimitReader(rc, maxSheetPartSize))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = nil
+				if n := attr(t, "r"); n != "" {
+					// Keep row numbers aligned with the sheet when empty rows are left out
+					if num, err := strconv.Atoi(n); err == nil {
+						for len(rows) < num-1 {
+							rows = append(rows, nil)
+						}
+					}
+				}
+			case "c":
+				cellType, cellRef = attr(t, "t"), attr(t, "r")
+				value.Reset()
+			case "v", "t":
+				inValue = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				col := len(row)
+				if cellRef != "" {
+					col = columnIndex(cellRef)
+				}
+				for len(row) < col {
+					row = append(row, "")
+				}
+				text := value.String()
+				switch cellType {
+				case "s":
+					if i, err := strconv.Atoi(text); err == nil && i >= 0 && i < len(shared) {
+						text = shared[i]
+					}
+				case "b":
+					if text == "1" {
+						text = "TRUE"
+					} else {
+						text = "FALSE"
+					}
+				}
+				if col < len(row) {
+					row[col] = text
+				} else {
+					row = append(row, text)
+				}
+			case "row":
+				rows = append(rows, row)
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		}
+	}
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// columnIndex converts the letters of a cell reference to a zero-based column (B7 = 1)
+func columnIndex(ref string) int {
+	index := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+	}
+	return index - 1
+}
@@ -0,0 +1,159 @@
+// Package inventory is the only place that writes product and variant stock.
+// Every change is recorded in the inventory_movements ledger.
+package inventory
+
+import (
+	"errors"
+
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientStock is returned when a decrement would take stock below zero
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// Change is a stock movement to apply
+type Change struct {
+	ProductID uint
+	VariantID *uint
+	Delta     int
+	Type      models.MovementType
+	// Actor is nil for system changes (e.g. background jobs)
+	Actor         *models.User
+	ReferenceType string
+	ReferenceID   uint
+	Note          string
+	// UnitCost is the purchase cost per unit of received goods
+	UnitCost *float64
+}
+
+// Apply changes the stock of a product, or of a variant and its product, and
+// records the movement. Decrements are conditional on enough stock, so two
+// concurrent sales cannot oversell even without a prior row lock. Soft-deleted
+// products and variants are still updated so cancellations can restock them.
+func Apply(tx *gorm.DB, c Change) (*models.InventoryMovement, error) {
+	if c.Delta == 0 {
+		return nil, nil
+	}
+
+	if c.VariantID != nil {
+		if err := updateStock(tx, &models.ProductVariant{}, *c.VariantID, c.Delta); err != nil {
+			return nil, err
+		}
+	}
+	// The product stock of a variant product is the sum of its variants, so it
+	// moves by the same delta
+	if err := updateStock(tx, &models.Product{}, c.ProductID, c.Delta); err != nil {
+		return nil, err
+	}
+
+	balance, err := currentStock(tx, c.ProductID, c.VariantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return record(tx, c, balance)
+}
+
+// record writes a ledger entry without touching stock
+func record(tx *gorm.DB, c Change, balance int) (*models.InventoryMovement, error) {
+	movement := models.InventoryMovement{
+		ProductID: c.ProductID,
+		VariantID: c.VariantID,
+		Type:      c.Type,
+		Delta:     c.Delta,
+		Balance:   balance,
+		ActorRole: models.ActorSystem,
+	}
+	if c.Actor != nil {
+		movement.ActorID = &c.Actor.ID
+		movement.ActorRole = string(c.Actor.Role)
+	}
+	if c.ReferenceType != "" {
+		movement.ReferenceType = &c.ReferenceType
+		movement.ReferenceID = &c.ReferenceID
+	}
+	if c.Note != "" {
+		movement.Note = &c.Note
+	}
+	movement.UnitCost = c.UnitCost
+	if err := tx.Create(&movement).Error; err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// Set adjusts a product or variant to an absolute stock level, recording the
+// difference as a movement of the given type. It returns nil when nothing changed.
+func Set(tx *gorm.DB, productID uint, variantID *uint, stock int, typ models.MovementType, actor *models.User, note string) (*models.InventoryMovement, error) {
+	if stock < 0 {
+		return nil, ErrInsufficientStock
+	}
+
+	// Lock the row so the delta is computed against the stock we overwrite
+	var current int
+	var err error
+	if variantID != nil {
+		err = lockStock(tx, &models.ProductVariant{}, *variantID, &current)
+	} else {
+		err = lockStock(tx, &models.Product{}, productID, &current)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return Apply(tx, Change{
+		ProductID: productID,
+		VariantID: variantID,
+		Delta:     stock - current,
+		Type:      typ,
+		Actor:     actor,
+		Note:      note,
+	})
+}
+
+func updateStock(tx *gorm.DB, model interface{}, id uint, delta int) error {
+	query := tx.Unscoped().Model(model).Where("id = ?", id)
+	if delta < 0 {
+		query = query.Where("stock >= ?", -delta)
+	}
+	result := query.Update("stock", gorm.Expr("stock + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		if delta < 0 {
+			return ErrInsufficientStock
+		}
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func lockStock(tx *gorm.DB, model interface{}, id uint, stock *int) error {
+	result := tx.Unscoped().Model(model).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		Select("stock").
+		Scan(stock)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func currentStock(tx *gorm.DB, productID uint, variantID *uint) (int, error) {
+	var stock int
+	var err error
+	if variantID != nil {
+		err = tx.Unscoped().Model(&models.ProductVariant{}).Where("id = ?", *variantID).Select("stock").Scan(&stock).Error
+	} else {
+		err = tx.Unscoped().Model(&models.Product{}).Where("id = ?", productID).Select("stock").Scan(&stock).Error
+	}
+	return stock, err
+}
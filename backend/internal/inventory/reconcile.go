@@ -0,0 +1,75 @@
+package inventory
+
+import (
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Discrepancy is a product or variant whose stock differs from the sum of its ledger
+type Discrepancy struct {
+	ProductID   uint   `json:"product_id"`
+	VariantID   *uint  `json:"variant_id,omitempty"`
+	Name        string `json:"name"`
+	Stock       int    `json:"stock"`
+	LedgerTotal int    `json:"ledger_total"`
+	Difference  int    `json:"difference"` // Stock - LedgerTotal
+}
+
+// Reconcile compares the stock of every product (or only productID when it is
+// not zero) against its ledger. Products with variants are checked per variant.
+// Stock that existed before the ledger was introduced shows up here once.
+func Reconcile(db *gorm.DB, productID uint) ([]Discrepancy, error) {
+	var result []Discrepancy
+
+	products := db.Table("products p").
+		Select("p.id AS product_id, p.name, p.stock, COALESCE(SUM(m.delta), 0) AS ledger_total").
+		Joins("LEFT JOIN inventory_movements m ON m.product_id = p.id AND m.variant_id IS NULL").
+		Where("p.deleted_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id AND v.deleted_at IS NULL)").
+		Group("p.id, p.name, p.stock").
+		Having("p.stock <> COALESCE(SUM(m.delta), 0)")
+	if productID != 0 {
+		products = products.Where("p.id = ?", productID)
+	}
+	if err := products.Scan(&result).Error; err != nil {
+		return nil, err
+	}
+
+	var variantRows []Discrepancy
+	variants := db.Table("product_variants v").
+		Select("v.product_id, v.id AS variant_id, CONCAT(p.name, ' - ', v.name) AS name, v.stock, COALESCE(SUM(m.delta), 0) AS ledger_total").
+		Joins("JOIN products p ON p.id = v.product_id AND p.deleted_at IS NULL").
+		Joins("LEFT JOIN inventory_movements m ON m.variant_id = v.id").
+		Where("v.deleted_at IS NULL").
+		Group("v.product_id, v.id, p.name, v.name, v.stock").
+		Having("v.stock <> COALESCE(SUM(m.delta), 0)")
+	if productID != 0 {
+		variants = variants.Where("v.product_id = ?", productID)
+	}
+	if err := variants.Scan(&variantRows).Error; err != nil {
+		return nil, err
+	}
+	result = append(result, variantRows...)
+
+	for i := range result {
+		result[i].Difference = result[i].Stock - result[i].LedgerTotal
+	}
+	return result, nil
+}
+
+// Resolve records an adjustment that brings the ledger in line with the
+// current stock. Stock itself is not changed; the physical count is trusted.
+func Resolve(tx *gorm.DB, d Discrepancy, actor *models.User, note string) (*models.InventoryMovement, error) {
+	if d.Difference == 0 {
+		return nil, nil
+	}
+	return record(tx, Change{
+		ProductID: d.ProductID,
+		VariantID: d.VariantID,
+		Delta:     d.Difference,
+		Type:      models.MovementAdjustment,
+		Actor:     actor,
+		Note:      note,
+	}, d.Stock)
+}
@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+)
+
+// PruneAuthSessions deletes refresh tokens past their expiry. Revoked tokens are
+// kept until then so a reused token is still recognized.
+func PruneAuthSessions(ctx context.Context) (string, error) {
+	result := database.DB.Where("expires_at < ?", time.Now()).Delete(&models.AuthSession{})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return fmt.Sprintf("%d expired sessions deleted", result.RowsAffected), nil
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gsm-motor/internal/config"
+)
+
+// StartBackgroundJobs registers the built-in jobs and starts the scheduler
+func StartBackgroundJobs(ctx context.Context) {
+	cfg := config.AppConfig
+	if !cfg.JobsEnabled {
+		log.Println("Background jobs disabled")
+		return
+	}
+
+	interval := time.Duration(cfg.JobIntervalMinutes) * time.Minute
+
+	scheduler := NewScheduler()
+	scheduler.Register(Job{Name: "expire_unpaid_orders", Interval: interval, Run: ExpireUnpaidOrders})
+	scheduler.Register(Job{Name: "payment_reminders", Interval: interval, Run: SendPaymentReminders})
+	scheduler.Register(Job{Name: "product_imports", Interval: interval, Run: RunProductImports})
+	// Sales start and end close to the minute they are scheduled for
+	scheduler.Register(Job{Name: "price_schedules", Interval: time.Minute, Run: ApplyPriceSchedules})
+	scheduler.Register(Job{Name: "prune_auth_sessions", Interval: time.Hour, Run: PruneAuthSessions})
+	scheduler.Register(Job{
+		Name:     "sales_rollup",
+		Interval: time.Duration(cfg.AnalyticsRollupIntervalMinutes) * time.Minute,
+		Run:      RollupSales,
+	})
+	scheduler.Register(Job{
+		Name:     "low_stock_digest",
+		Interval: time.Duration(cfg.LowStockAlertIntervalMinutes) * time.Minute,
+		Run:      SendLowStockDigest,
+	})
+	scheduler.Start(ctx)
+}
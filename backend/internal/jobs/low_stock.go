@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/utils"
+)
+
+// lowStockRow is a product or variant below its reorder point
+type lowStockRow struct {
+	ProductID       uint
+	VariantID       *uint
+	Name            string
+	SKU             string
+	Stock           int
+	ReorderPoint    int
+	ReorderQuantity int
+	Supplier        string
+}
+
+// SendLowStockDigest emails one digest of the products and variants that fell
+// below their reorder point since the last run. Reported items are marked with
+// low_stock_alert_at, and the mark is cleared once they are restocked, so an
+// item is reported again only after it crosses its threshold again.
+func SendLowStockDigest(ctx context.Context) (string, error) {
+	if err := clearRestockedAlerts(); err != nil {
+		return "", err
+	}
+
+	var rows []lowStockRow
+	if err := database.DB.Model(&models.Product{}).
+		Select("products.id AS product_id, products.name, products.stock, products.reorder_point, products.reorder_quantity, suppliers.name AS supplier").
+		Joins("LEFT JOIN suppliers ON suppliers.id = products.supplier_id").
+		Scopes(models.LowStock).
+		Where("products.low_stock_alert_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id AND v.deleted_at IS NULL)").
+		Order("products.stock ASC").
+		Limit(batchSize).
+		Scan(&rows).Error; err != nil {
+		return "", err
+	}
+
+	var variantRows []lowStockRow
+	if err := database.DB.Model(&models.ProductVariant{}).
+		Select("products.id AS product_id, product_variants.id AS variant_id, CONCAT(products.name, ' - ', product_variants.name) AS name, product_variants.sku, product_variants.stock, products.reorder_point, products.reorder_quantity, suppliers.name AS supplier").
+		Joins("JOIN products ON products.id = product_variants.product_id AND products.deleted_at IS NULL").
+		Joins("LEFT JOIN suppliers ON suppliers.id = products.supplier_id").
+		Scopes(models.LowStockVariants).
+		Where("product_variants.low_stock_alert_at IS NULL").
+		Order("product_variants.stock ASC").
+		Limit(batchSize).
+		Scan(&variantRows).Error; err != nil {
+		return "", err
+	}
+	rows = append(rows, variantRows...)
+
+	if len(rows) == 0 || ctx.Err() != nil {
+		return "no new low-stock items", nil
+	}
+
+	// Claim the items before sending so a digest never lists them twice
+	var productIDs, variantIDs []uint
+	for _, row := range rows {
+		if row.VariantID != nil {
+			variantIDs = append(variantIDs, *row.VariantID)
+		} else {
+			productIDs = append(productIDs, row.ProductID)
+		}
+	}
+	now := time.Now()
+	if err := markLowStockAlerts(productIDs, variantIDs, &now); err != nil {
+		return "", err
+	}
+
+	items := make([]utils.LowStockItemInfo, 0, len(rows))
+	for _, row := range rows {
+		product := models.Product{ReorderPoint: row.ReorderPoint, ReorderQuantity: row.ReorderQuantity}
+		items = append(items, utils.LowStockItemInfo{
+			Name:          row.Name,
+			SKU:           row.SKU,
+			Stock:         row.Stock,
+			ReorderPoint:  row.ReorderPoint,
+			ReorderAmount: product.SuggestedReorderQuantity(row.Stock),
+			Supplier:      row.Supplier,
+		})
+	}
+
+	if err := utils.SendLowStockDigest(items); err != nil {
+		// Report these items again on the next run
+		markLowStockAlerts(productIDs, variantIDs, nil)
+		return "", err
+	}
+
+	return fmt.Sprintf("low-stock digest sent with %d items", len(items)), nil
+}
+
+// markLowStockAlerts sets (or with nil clears) the alert mark of the given items
+func markLowStockAlerts(productIDs, variantIDs []uint, at *time.Time) error {
+	if len(productIDs) > 0 {
+		if err := database.DB.Model(&models.Product{}).
+			Where("id IN ?", productIDs).
+			UpdateColumn("low_stock_alert_at", at).Error; err != nil {
+			return err
+		}
+	}
+	if len(variantIDs) > 0 {
+		if err := database.DB.Model(&models.ProductVariant{}).
+			Where("id IN ?", variantIDs).
+			UpdateColumn("low_stock_alert_at", at).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearRestockedAlerts re-arms the alert for items that are back at or above their reorder point
+func clearRestockedAlerts() error {
+	if err := database.DB.Model(&models.Product{}).
+		Where("low_stock_alert_at IS NOT NULL AND stock >= reorder_point").
+		UpdateColumn("low_stock_alert_at", nil).Error; err != nil {
+		return err
+	}
+	return database.DB.Exec("UPDATE product_variants v JOIN products p ON p.id = v.product_id " +
+		"SET v.low_stock_alert_at = NULL WHERE v.low_stock_alert_at IS NOT NULL AND v.stock >= p.reorder_point").Error
+}
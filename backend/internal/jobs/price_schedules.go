@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/pricing"
+)
+
+// ApplyPriceSchedules applies scheduled price changes and starts and ends sales
+func ApplyPriceSchedules(ctx context.Context) (string, error) {
+	summary, err := pricing.ApplyDue(database.DB, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d price changes applied, %d sales started, %d sales ended, %d schedules cancelled",
+		summary.Changed, summary.Started, summary.Ended, summary.Cancelled), nil
+}
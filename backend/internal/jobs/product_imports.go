@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"gsm-motor/internal/importer"
+)
+
+// RunProductImports picks up imports that were queued but never started, such
+// as those interrupted by a restart before their goroutine ran, and fails
+// imports that a restart stopped halfway
+func RunProductImports(ctx context.Context) (string, error) {
+	stale, err := importer.FailStale()
+	if err != nil {
+		return "", err
+	}
+	count, err := importer.RunQueued(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d imports processed, %d interrupted", count, stale), nil
+}
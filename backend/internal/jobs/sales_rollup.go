@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"gsm-motor/internal/analytics"
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/database"
+)
+
+// RollupSales refreshes the daily sales rollups of the recent days. Orders are
+// paid, completed or cancelled some days after they are placed, so the last
+// few days are recomputed on every run.
+func RollupSales(ctx context.Context) (string, error) {
+	days, err := analytics.RollupRecent(database.DB, config.AppConfig.AnalyticsRollupDays)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d days of sales rolled up", days), nil
+}
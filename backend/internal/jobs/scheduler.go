@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// Job is a unit of background work run on a fixed interval.
+// Run returns a short summary that is stored in the job run log.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) (string, error)
+}
+
+// Scheduler runs registered jobs. Each run takes a lease in job_locks first,
+// so when several instances are deployed only one of them executes a job at a time.
+type Scheduler struct {
+	jobs     []Job
+	instance string
+}
+
+// NewScheduler creates a scheduler with a unique instance name
+func NewScheduler() *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		instance: fmt.Sprintf("%s-%d-%04d", host, os.Getpid(), rand.Intn(10000)),
+	}
+}
+
+// Register adds a job to the scheduler
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every registered job in its own goroutine until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+	log.Printf("✓ Background jobs started (%d jobs, instance %s)", len(s.jobs), s.instance)
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	// Run once shortly after startup, then on every tick
+	s.runOnce(ctx, job)
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	if !s.acquire(job) {
+		return
+	}
+	stop := s.keepLease(job)
+	defer stop()
+
+	run := models.JobRun{
+		JobName:   job.Name,
+		Instance:  s.instance,
+		Status:    models.JobRunning,
+		StartedAt: time.Now(),
+	}
+	database.DB.Create(&run)
+
+	summary, err := s.safeRun(ctx, job)
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = models.JobSucceeded
+	if err != nil {
+		run.Status = models.JobFailed
+		summary = err.Error()
+		log.Printf("Job %s failed: %v", job.Name, err)
+	} else if summary != "" {
+		log.Printf("Job %s: %s", job.Name, summary)
+	}
+	if summary != "" {
+		run.Message = &summary
+	}
+	database.DB.Save(&run)
+}
+
+// safeRun keeps a panicking job from taking the whole server down
+func (s *Scheduler) safeRun(ctx context.Context, job Job) (summary string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// leaseFor is how long a job lease lasts from the time it is taken or extended
+func leaseFor(job Job) time.Duration {
+	return job.Interval * 9 / 10
+}
+
+// acquire takes the job lease if it has expired. The lease is held for most of
+// the interval and is not released after the run, so across all instances the
+// job runs at most about once per interval. A crashed instance only blocks the
+// job until its lease runs out.
+func (s *Scheduler) acquire(job Job) bool {
+	now := time.Now()
+	lease := now.Add(leaseFor(job))
+
+	// Make sure the lock row exists; a concurrent insert is harmless
+	database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.JobLock{
+		Name:        job.Name,
+		Owner:       s.instance,
+		LockedUntil: now.Add(-time.Second),
+	})
+
+	result := database.DB.Model(&models.JobLock{}).
+		Where("name = ? AND locked_until < ?", job.Name, now).
+		Updates(map[string]interface{}{"owner": s.instance, "locked_until": lease})
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// keepLease extends the job lease while a run takes longer than the lease, so
+// another instance cannot start the same job halfway through. The returned
+// function stops extending it.
+func (s *Scheduler) keepLease(job Job) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(leaseFor(job) / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := database.DB.Model(&models.JobLock{}).
+					Where("name = ? AND owner = ?", job.Name, s.instance).
+					Update("locked_until", time.Now().Add(leaseFor(job))).Error
+				if err != nil {
+					log.Printf("Failed to extend lease of job %s: %v", job.Name, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/database"
+	"gsm-motor/internal/models"
+	"gsm-motor/internal/orders"
+	"gsm-motor/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// batchSize caps how many orders a single run handles
+const batchSize = 100
+
+// unpaidOrders selects pending orders that never had a payment proof uploaded
+// and have no gateway charge the customer can still pay. Charges whose amount
+// did not match are waiting for an admin, so they keep the order too.
+func unpaidOrders(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Order{}).
+		Where("status = ? AND payment_status = ?", models.OrderPending, models.PaymentPending).
+		Where("NOT EXISTS (SELECT 1 FROM payment_proofs pp WHERE pp.order_id = orders.id AND pp.deleted_at IS NULL)").
+		Where(`NOT EXISTS (SELECT 1 FROM payment_charges pc WHERE pc.order_id = orders.id AND
+			((pc.status = ? AND (pc.expires_at IS NULL OR pc.expires_at > ?)) OR pc.status = ?))`,
+			models.ChargePending, time.Now(), models.ChargeMismatch)
+}
+
+// ExpireUnpaidOrders cancels unpaid orders older than the configured window and
+// restores their stock. Every order is re-checked under a row lock, so running
+// it twice (or on two instances) cancels each order only once.
+func ExpireUnpaidOrders(ctx context.Context) (string, error) {
+	window := time.Duration(config.AppConfig.UnpaidOrderExpireHours) * time.Hour
+	cutoff := time.Now().Add(-window)
+
+	var ids []uint
+	if err := unpaidOrders(database.DB).
+		Where("created_at < ?", cutoff).
+		Order("created_at ASC").
+		Limit(batchSize).
+		Pluck("id", &ids).Error; err != nil {
+		return "", err
+	}
+
+	reason := fmt.Sprintf("Dibatalkan otomatis: pembayaran tidak diterima dalam %d jam", config.AppConfig.UnpaidOrderExpireHours)
+	cancelled := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+
+		var order *models.Order
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			order, err = orders.LockOrder(tx, id)
+			if err != nil {
+				return err
+			}
+
+			// Re-check now that the row is locked
+			var stillUnpaid int64
+			unpaidOrders(tx).Where("orders.id = ?", id).Count(&stillUnpaid)
+			if stillUnpaid == 0 {
+				order = nil
+				return nil
+			}
+			return orders.Cancel(tx, order, nil, reason)
+		})
+		if err != nil {
+			return fmt.Sprintf("%d orders cancelled", cancelled), fmt.Errorf("order %d: %w", id, err)
+		}
+		if order == nil {
+			continue
+		}
+
+		cancelled++
+		var user models.User
+		if err := database.DB.First(&user, order.UserID).Error; err == nil {
+			go utils.SendOrderCancelledEmail(user.Email, order.OrderNumber, user.Name, reason)
+		}
+	}
+
+	return fmt.Sprintf("%d of %d unpaid orders cancelled", cancelled, len(ids)), nil
+}
+
+// SendPaymentReminders emails customers whose orders are still unpaid after the
+// reminder delay. Each order is claimed by setting payment_reminder_sent_at
+// before the email goes out, so a reminder is never sent twice.
+func SendPaymentReminders(ctx context.Context) (string, error) {
+	cfg := config.AppConfig
+	cutoff := time.Now().Add(-time.Duration(cfg.PaymentReminderHours) * time.Hour)
+
+	var pending []models.Order
+	if err := unpaidOrders(database.DB).
+		Preload("User").
+		Where("created_at < ? AND payment_reminder_sent_at IS NULL", cutoff).
+		Order("created_at ASC").
+		Limit(batchSize).
+		Find(&pending).Error; err != nil {
+		return "", err
+	}
+
+	sent := 0
+	for _, order := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+
+		claim := database.DB.Model(&models.Order{}).
+			Where("id = ? AND payment_reminder_sent_at IS NULL", order.ID).
+			Update("payment_reminder_sent_at", time.Now())
+		if claim.Error != nil || claim.RowsAffected == 0 || order.User == nil {
+			continue
+		}
+
+		deadline := order.CreatedAt.Add(time.Duration(cfg.UnpaidOrderExpireHours) * time.Hour)
+		go utils.SendPaymentReminderEmail(order.User.Email, order.OrderNumber, order.User.Name, order.GetGrandTotal(), deadline)
+		sent++
+	}
+
+	return fmt.Sprintf("%d payment reminders sent", sent), nil
+}
@@ -4,21 +4,13 @@ import (
 	"net/http"
 	"strings"
 
-	"gsm-motor/internal/config"
 	"gsm-motor/internal/database"
 	"gsm-motor/internal/models"
+	"gsm-motor/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
-	jwt.RegisteredClaims
-}
-
 // AuthMiddleware validates JWT token from cookie or Authorization header
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -43,18 +35,21 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Parse and validate token
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.AppConfig.JWTSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		// Parse and validate token; refresh tokens are refused here
+		claims, err := utils.ParseToken(tokenString, utils.TokenAccess)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid"})
 			c.Abort()
 			return
 		}
 
+		// Tokens of a session that was logged out stop working right away
+		if !sessionActive(claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Sesi telah berakhir"})
+			c.Abort()
+			return
+		}
+
 		// Get user from database
 		var user models.User
 		if err := database.DB.First(&user, claims.UserID).Error; err != nil {
@@ -67,6 +62,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("user", &user)
 		c.Set("user_id", user.ID)
 		c.Set("user_role", user.Role)
+		c.Set("session_id", claims.SessionID)
 
 		c.Next()
 	}
@@ -89,17 +85,14 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		}
 
 		if tokenString != "" {
-			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return []byte(config.AppConfig.JWTSecret), nil
-			})
-
-			if err == nil && token.Valid {
+			claims, err := utils.ParseToken(tokenString, utils.TokenAccess)
+			if err == nil && sessionActive(claims) {
 				var user models.User
 				if err := database.DB.First(&user, claims.UserID).Error; err == nil {
 					c.Set("user", &user)
 					c.Set("user_id", user.ID)
 					c.Set("user_role", user.Role)
+					c.Set("session_id", claims.SessionID)
 				}
 			}
 		}
@@ -108,6 +101,16 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// sessionActive reports whether the token's session has not been revoked.
+// Tokens issued before the session store carry no session and expire on their own.
+func sessionActive(claims *utils.Claims) bool {
+	if claims.SessionID == "" {
+		return true
+	}
+	active, err := models.SessionActive(database.DB, claims.UserID, claims.SessionID)
+	return err == nil && active
+}
+
 // AdminMiddleware requires user to be admin or subadmin
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
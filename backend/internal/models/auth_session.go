@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session revocation reasons
+const (
+	SessionRotated        = "rotated"         // Replaced by the next refresh token of the family
+	SessionLogout         = "logout"          // The user logged out on this device
+	SessionLogoutAll      = "logout_all"      // The user logged out of every device
+	SessionReused         = "reuse"           // An already rotated refresh token was presented again
+	SessionPasswordReset  = "password_reset"  // The password was reset
+	SessionPasswordChange = "password_change" // The password was changed on another device
+)
+
+// AuthSession is one refresh token. Every refresh rotates the token: the old row
+// is revoked and a new row joins the same family, so the family is the login on
+// one device. A rotated token that is used again means it was stolen, and the
+// whole family is revoked.
+type AuthSession struct {
+	ID            uint       `gorm:"primaryKey" json:"-"`
+	JTI           string     `gorm:"size:64;uniqueIndex;not null" json:"-"` // Token ID of the refresh token
+	FamilyID      string     `gorm:"size:64;index;not null" json:"family_id"`
+	UserID        uint       `gorm:"not null;index" json:"-"`
+	Device        string     `gorm:"size:100" json:"device"`
+	IPAddress     string     `gorm:"size:45" json:"ip_address"`
+	UserAgent     string     `gorm:"type:text" json:"user_agent"`
+	ExpiresAt     time.Time  `gorm:"not null;index" json:"expires_at"`
+	RevokedAt     *time.Time `json:"-"`
+	RevokedReason *string    `gorm:"size:20" json:"-"`
+	ReplacedBy    *string    `gorm:"size:64" json:"-"` // JTI of the token that replaced this one
+	LoginAt       time.Time  `json:"login_at"`         // When the family was created
+	CreatedAt     time.Time  `json:"last_active_at"`   // Issued on the last refresh
+}
+
+func (AuthSession) TableName() string {
+	return "auth_sessions"
+}
+
+// activeSessions matches refresh tokens that are neither revoked nor expired
+func activeSessions(db *gorm.DB) *gorm.DB {
+	return db.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+}
+
+// SessionActive reports whether the session family still has a live refresh token
+func SessionActive(db *gorm.DB, userID uint, familyID string) (bool, error) {
+	var count int64
+	err := db.Model(&AuthSession{}).
+		Scopes(activeSessions).
+		Where("user_id = ? AND family_id = ?", userID, familyID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ActiveSessions lists the live sessions of a user, most recently used first
+func ActiveSessions(db *gorm.DB, userID uint) ([]AuthSession, error) {
+	var sessions []AuthSession
+	err := db.Scopes(activeSessions).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSessionFamily revokes every live token of a session family
+func RevokeSessionFamily(db *gorm.DB, userID uint, familyID, reason string) (int64, error) {
+	result := db.Model(&AuthSession{}).
+		Where("user_id = ? AND family_id = ? AND revoked_at IS NULL", userID, familyID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeLegacyTokens ends the refresh tokens of a user that were signed before
+// the session store. They have no session row, so they are cut off by issue time.
+func RevokeLegacyTokens(db *gorm.DB, userID uint) error {
+	return db.Model(&User{}).Where("id = ?", userID).UpdateColumn("sessions_revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions revokes every live session of a user, e.g. on "log out of
+// all devices" or a password change. The family in keepFamilyID, if any, is kept.
+func RevokeUserSessions(db *gorm.DB, userID uint, reason, keepFamilyID string) (int64, error) {
+	query := db.Model(&AuthSession{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepFamilyID != "" {
+		query = query.Where("family_id <> ?", keepFamilyID)
+	}
+	result := query.Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+	return result.RowsAffected, result.Error
+}
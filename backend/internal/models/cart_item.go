@@ -4,35 +4,86 @@ import (
 	"time"
 )
 
+// CartItem is one line of a user's cart. The unique index keeps one line per
+// variant; MySQL lets NULLs repeat, so AddToCart keeps lines without a variant
+// unique itself.
 type CartItem struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null;uniqueIndex:cart_items_user_id_product_id_unique" json:"user_id"`
-	ProductID uint      `gorm:"not null;uniqueIndex:cart_items_user_id_product_id_unique" json:"product_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:cart_items_user_product_variant_unique" json:"user_id"`
+	ProductID uint      `gorm:"not null;uniqueIndex:cart_items_user_product_variant_unique" json:"product_id"`
+	VariantID *uint     `gorm:"uniqueIndex:cart_items_user_product_variant_unique" json:"variant_id,omitempty"`
 	Quantity  int       `gorm:"not null;default:1" json:"quantity"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
 	// Relations
-	User    *User    `gorm:"foreignKey:UserID" json:"-"`
-	Product *Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	User    *User           `gorm:"foreignKey:UserID" json:"-"`
+	Product *Product        `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
 }
 
 func (CartItem) TableName() string {
 	return "cart_items"
 }
 
-// GetSubtotal returns the subtotal for this cart item
-func (ci *CartItem) GetSubtotal() float64 {
+// GetUnitPrice returns the tiered unit price of the variant, or of the product if there is none
+func (ci *CartItem) GetUnitPrice() float64 {
+	if ci.Variant != nil {
+		return ci.Variant.GetEffectivePrice(ci.Quantity)
+	}
 	if ci.Product == nil {
 		return 0
 	}
-	return ci.Product.GetEffectivePrice(ci.Quantity) * float64(ci.Quantity)
+	return ci.Product.GetEffectivePrice(ci.Quantity)
+}
+
+// GetUnitCost returns the unit cost of the variant, falling back to the product cost.
+// It is nil when no cost is known.
+func (ci *CartItem) GetUnitCost() *float64 {
+	if ci.Variant != nil && ci.Variant.CostPrice != nil {
+		return ci.Variant.CostPrice
+	}
+	if ci.Product == nil {
+		return nil
+	}
+	return ci.Product.CostPrice
+}
+
+// GetSubtotal returns the subtotal for this cart item
+func (ci *CartItem) GetSubtotal() float64 {
+	return ci.GetUnitPrice() * float64(ci.Quantity)
 }
 
 // GetTotalWeight returns total weight for this cart item
 func (ci *CartItem) GetTotalWeight() int {
+	if ci.Variant != nil {
+		return ci.Variant.Weight * ci.Quantity
+	}
 	if ci.Product == nil {
 		return 0
 	}
 	return ci.Product.Weight * ci.Quantity
 }
+
+// GetAvailableStock returns the stock of the variant, or of the product if there is none
+func (ci *CartItem) GetAvailableStock() int {
+	if ci.Variant != nil {
+		return ci.Variant.Stock
+	}
+	if ci.Product == nil {
+		return 0
+	}
+	return ci.Product.Stock
+}
+
+// GetDisplayName returns the product name including the variant
+func (ci *CartItem) GetDisplayName() string {
+	name := ""
+	if ci.Product != nil {
+		name = ci.Product.Name
+	}
+	if ci.Variant != nil {
+		name += " - " + ci.Variant.Name
+	}
+	return name
+}
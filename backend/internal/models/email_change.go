@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// EmailChangeRequest is a pending move of an account to a new email address.
+// The address is swapped only after the code sent to it is entered. A user has
+// at most one; a new request replaces it.
+type EmailChangeRequest struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	NewEmail  string    `gorm:"size:255;not null" json:"new_email"`
+	CodeHash  string    `gorm:"size:64;not null" json:"-"` // SHA-256 of the emailed code
+	Attempts  int       `gorm:"not null;default:0" json:"-"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (EmailChangeRequest) TableName() string {
+	return "email_change_requests"
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+type ImportStatus string
+
+const (
+	ImportQueued    ImportStatus = "queued"
+	ImportRunning   ImportStatus = "running"
+	ImportCompleted ImportStatus = "completed"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportJob is a product spreadsheet import processed in the background.
+// A dry run validates every row and reports what would change without writing.
+type ImportJob struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	Status        ImportStatus `gorm:"type:enum('queued','running','completed','failed');default:'queued';index" json:"status"`
+	DryRun        bool         `gorm:"not null;default:false" json:"dry_run"`
+	Filename      string       `gorm:"size:255;not null" json:"filename"` // Original name of the uploaded spreadsheet
+	FilePath      string       `gorm:"size:500;not null" json:"-"`
+	ImagesPath    *string      `gorm:"size:500" json:"-"` // Optional zip of images referenced by filename
+	TotalRows     int          `gorm:"not null;default:0" json:"total_rows"`
+	ProcessedRows int          `gorm:"not null;default:0" json:"processed_rows"`
+	CreatedCount  int          `gorm:"not null;default:0" json:"created_count"`
+	UpdatedCount  int          `gorm:"not null;default:0" json:"updated_count"`
+	FailedCount   int          `gorm:"not null;default:0" json:"failed_count"`
+	Error         *string      `gorm:"type:text" json:"error,omitempty"`  // Why the whole import failed
+	Report        *string      `gorm:"type:longtext" json:"-"`            // Row results as JSON
+	CreatedBy     *uint        `gorm:"index" json:"created_by,omitempty"` // Admin who started the import
+	AppliedJobID  *uint        `json:"applied_job_id,omitempty"`          // Import that ran this dry run for real
+	StartedAt     *time.Time   `json:"started_at,omitempty"`
+	FinishedAt    *time.Time   `json:"finished_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+func (ImportJob) TableName() string {
+	return "import_jobs"
+}
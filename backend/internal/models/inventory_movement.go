@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+type MovementType string
+
+const (
+	MovementSale          MovementType = "sale"
+	MovementCancelRestock MovementType = "cancel_restock"
+	MovementAdjustment    MovementType = "adjustment"
+	MovementReceiving     MovementType = "receiving"
+	MovementReturn        MovementType = "return"
+)
+
+// Reference types recorded on movements
+const (
+	ReferenceOrder = "order"
+)
+
+// InventoryMovement is one entry of the stock ledger. Products with variants
+// keep their ledger per variant; the product's own stock is the variant sum.
+type InventoryMovement struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	ProductID     uint         `gorm:"not null;index:inventory_movements_product_variant_index" json:"product_id"`
+	VariantID     *uint        `gorm:"index:inventory_movements_product_variant_index" json:"variant_id,omitempty"`
+	Type          MovementType `gorm:"type:enum('sale','cancel_restock','adjustment','receiving','return');not null" json:"type"`
+	Delta         int          `gorm:"not null" json:"delta"`
+	Balance       int          `gorm:"not null" json:"balance"`                       // Stock of the product or variant after the movement
+	UnitCost      *float64     `gorm:"type:decimal(12,2)" json:"unit_cost,omitempty"` // Purchase cost per unit for receiving
+	ActorID       *uint        `gorm:"index" json:"actor_id,omitempty"`
+	ActorRole     string       `gorm:"size:20;not null" json:"actor_role"`
+	ReferenceType *string      `gorm:"size:50;index:inventory_movements_reference_index" json:"reference_type,omitempty"`
+	ReferenceID   *uint        `gorm:"index:inventory_movements_reference_index" json:"reference_id,omitempty"`
+	Note          *string      `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+
+	// Relations
+	Product *Product        `gorm:"foreignKey:ProductID" json:"-"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
+	Actor   *User           `gorm:"foreignKey:ActorID" json:"-"`
+}
+
+func (InventoryMovement) TableName() string {
+	return "inventory_movements"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+type JobRunStatus string
+
+const (
+	JobRunning   JobRunStatus = "running"
+	JobSucceeded JobRunStatus = "succeeded"
+	JobFailed    JobRunStatus = "failed"
+)
+
+// JobRun logs a single execution of a background job
+type JobRun struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	JobName    string       `gorm:"size:100;not null;index" json:"job_name"`
+	Instance   string       `gorm:"size:255;not null" json:"instance"`
+	Status     JobRunStatus `gorm:"type:enum('running','succeeded','failed');default:'running'" json:"status"`
+	Message    *string      `gorm:"type:text" json:"message,omitempty"`
+	StartedAt  time.Time    `gorm:"not null;index" json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
+
+func (JobRun) TableName() string {
+	return "job_runs"
+}
+
+// JobLock is a lease that lets only one instance run a job at a time
+type JobLock struct {
+	Name        string    `gorm:"primaryKey;size:100" json:"name"`
+	Owner       string    `gorm:"size:255;not null" json:"owner"`
+	LockedUntil time.Time `gorm:"not null" json:"locked_until"`
+}
+
+func (JobLock) TableName() string {
+	return "job_locks"
+}
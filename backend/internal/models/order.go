@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -31,27 +32,36 @@ const (
 )
 
 type Order struct {
-	ID              uint           `gorm:"primaryKey" json:"id"`
-	OrderNumber     string         `gorm:"size:255;uniqueIndex;not null" json:"order_number"`
-	UserID          uint           `gorm:"not null;index" json:"user_id"`
-	TotalPrice      float64        `gorm:"type:decimal(12,2);not null" json:"total_price"`
-	ShippingCost    float64        `gorm:"type:decimal(12,2);default:0" json:"shipping_cost"`
-	Courier         *string        `gorm:"size:255" json:"courier,omitempty"`
-	CourierService  *string        `gorm:"size:255" json:"courier_service,omitempty"`
-	TrackingNumber  *string        `gorm:"size:255" json:"tracking_number,omitempty"`
-	Status          OrderStatus    `gorm:"type:enum('pending','processing','shipped','completed','cancelled');default:'pending'" json:"status"`
-	ShippingMethod  ShippingMethod `gorm:"type:enum('pickup','courier','ojol');default:'courier'" json:"shipping_method"`
-	ShippingAddress string         `gorm:"type:text;not null" json:"shipping_address"`
-	PaymentStatus   PaymentStatus  `gorm:"type:enum('pending','uploaded','verified','failed');default:'pending'" json:"payment_status"`
-	Notes           *string        `gorm:"type:text" json:"notes,omitempty"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                    uint           `gorm:"primaryKey" json:"id"`
+	OrderNumber           string         `gorm:"size:255;uniqueIndex;not null" json:"order_number"`
+	UserID                uint           `gorm:"not null;index" json:"user_id"`
+	TotalPrice            float64        `gorm:"type:decimal(12,2);not null" json:"total_price"`
+	ShippingCost          float64        `gorm:"type:decimal(12,2);default:0" json:"shipping_cost"`
+	VoucherID             *uint          `gorm:"index" json:"voucher_id,omitempty"`
+	VoucherCode           *string        `gorm:"size:50" json:"voucher_code,omitempty"`
+	DiscountAmount        float64        `gorm:"type:decimal(12,2);default:0" json:"discount_amount"`
+	Courier               *string        `gorm:"size:255" json:"courier,omitempty"`
+	CourierService        *string        `gorm:"size:255" json:"courier_service,omitempty"`
+	TrackingNumber        *string        `gorm:"size:255" json:"tracking_number,omitempty"`
+	Status                OrderStatus    `gorm:"type:enum('pending','processing','shipped','completed','cancelled');default:'pending'" json:"status"`
+	ShippingMethod        ShippingMethod `gorm:"type:enum('pickup','courier','ojol');default:'courier'" json:"shipping_method"`
+	ShippingAddress       string         `gorm:"type:text;not null" json:"shipping_address"`
+	PaymentStatus         PaymentStatus  `gorm:"type:enum('pending','uploaded','verified','failed');default:'pending'" json:"payment_status"`
+	PaymentProvider       string         `gorm:"size:50;default:'manual'" json:"payment_provider"`
+	Notes                 *string        `gorm:"type:text" json:"notes,omitempty"`
+	CancelReason          *string        `gorm:"type:text" json:"cancel_reason,omitempty"`
+	CancelledAt           *time.Time     `json:"cancelled_at,omitempty"`
+	PaymentReminderSentAt *time.Time     `json:"-"`
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relations
-	User          *User          `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Items         []OrderItem    `gorm:"foreignKey:OrderID" json:"items,omitempty"`
-	PaymentProofs []PaymentProof `gorm:"foreignKey:OrderID" json:"payment_proofs,omitempty"`
+	User          *User                `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Items         []OrderItem          `gorm:"foreignKey:OrderID" json:"items,omitempty"`
+	PaymentProofs []PaymentProof       `gorm:"foreignKey:OrderID" json:"payment_proofs,omitempty"`
+	StatusHistory []OrderStatusHistory `gorm:"foreignKey:OrderID" json:"status_history,omitempty"`
+	Charges       []PaymentCharge      `gorm:"foreignKey:OrderID" json:"charges,omitempty"`
 }
 
 func (Order) TableName() string {
@@ -69,9 +79,19 @@ func GenerateOrderNumber() string {
 	return fmt.Sprintf("GSM-%s-%s", date, string(suffix))
 }
 
-// GetGrandTotal returns total price + shipping cost
+// GetGrandTotal returns total price + shipping cost - voucher discount
 func (o *Order) GetGrandTotal() float64 {
-	return o.TotalPrice + o.ShippingCost
+	return o.TotalPrice + o.ShippingCost - o.DiscountAmount
+}
+
+// MarshalJSON adds grand_total, the amount the customer pays, so clients do not
+// have to recompute it from the price, shipping cost and discount
+func (o Order) MarshalJSON() ([]byte, error) {
+	type order Order // Without the method, so this does not recurse
+	return json.Marshal(struct {
+		order
+		GrandTotal float64 `json:"grand_total"`
+	}{order(o), o.GetGrandTotal()})
 }
 
 // GetStatusLabel returns human-readable status in Indonesian
@@ -8,14 +8,19 @@ type OrderItem struct {
 	ID              uint      `gorm:"primaryKey" json:"id"`
 	OrderID         uint      `gorm:"not null;index" json:"order_id"`
 	ProductID       uint      `gorm:"not null;index" json:"product_id"`
+	VariantID       *uint     `gorm:"index" json:"variant_id,omitempty"`
+	VariantName     *string   `gorm:"size:255" json:"variant_name,omitempty"` // Snapshot at purchase
+	SKU             *string   `gorm:"size:100" json:"sku,omitempty"`          // Snapshot at purchase
 	Quantity        int       `gorm:"not null" json:"quantity"`
 	PriceAtPurchase float64   `gorm:"type:decimal(12,2);not null" json:"price_at_purchase"`
+	CostAtPurchase  *float64  `gorm:"type:decimal(12,2)" json:"-"` // Unit cost snapshot; nil when unknown
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 
 	// Relations
-	Order   *Order   `gorm:"foreignKey:OrderID" json:"-"`
-	Product *Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Order   *Order          `gorm:"foreignKey:OrderID" json:"-"`
+	Product *Product        `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
 }
 
 func (OrderItem) TableName() string {
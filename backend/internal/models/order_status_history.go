@@ -0,0 +1,179 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type StatusHistoryKind string
+
+const (
+	HistoryOrderStatus   StatusHistoryKind = "status"
+	HistoryPaymentStatus StatusHistoryKind = "payment"
+
+	// ActorSystem is recorded when a transition is not made by a user (e.g. background jobs)
+	ActorSystem = "system"
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+// Pickup and ojol orders never ship, so processing may complete directly.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:    {OrderProcessing, OrderCancelled},
+	OrderProcessing: {OrderShipped, OrderCompleted, OrderCancelled},
+	OrderShipped:    {OrderCompleted},
+	OrderCompleted:  {},
+	OrderCancelled:  {},
+}
+
+// paymentTransitions lists the payment statuses reachable from each payment status.
+// A rejected (failed) payment can be retried by uploading a new proof.
+var paymentTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentPending:  {PaymentUploaded, PaymentVerified, PaymentFailed},
+	PaymentUploaded: {PaymentUploaded, PaymentVerified, PaymentFailed},
+	PaymentFailed:   {PaymentUploaded, PaymentVerified},
+	PaymentVerified: {},
+}
+
+// ErrStaleOrder is returned when the order changed between reading and writing it
+var ErrStaleOrder = errors.New("order was modified concurrently")
+
+// TransitionError is returned for a transition the state machine does not allow
+type TransitionError struct {
+	Kind StatusHistoryKind
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("invalid %s transition from %q to %q", e.Kind, e.From, e.To)
+}
+
+// OrderStatusHistory records every order and payment status change
+type OrderStatusHistory struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	OrderID   uint              `gorm:"not null;index" json:"order_id"`
+	Kind      StatusHistoryKind `gorm:"type:enum('status','payment');default:'status'" json:"kind"`
+	FromState string            `gorm:"size:20" json:"from_state"`
+	ToState   string            `gorm:"size:20;not null" json:"to_state"`
+	ActorID   *uint             `gorm:"index" json:"actor_id,omitempty"`
+	ActorRole string            `gorm:"size:20;not null" json:"actor_role"`
+	Note      *string           `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+
+	// Relations
+	Order *Order `gorm:"foreignKey:OrderID" json:"-"`
+	Actor *User  `gorm:"foreignKey:ActorID" json:"-"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+// IsValid reports whether s is a known order status
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether the state machine allows moving from s to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid reports whether s is a known payment status
+func (s PaymentStatus) IsValid() bool {
+	_, ok := paymentTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether the payment status may move from s to next
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	for _, allowed := range paymentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo moves the order to a new status and records it in the history.
+// The update is conditional on the status read earlier, so a concurrent change
+// surfaces as ErrStaleOrder instead of being overwritten. A nil actor means the system.
+func (o *Order) TransitionTo(tx *gorm.DB, to OrderStatus, actor *User, note string) error {
+	from := o.Status
+	if !to.IsValid() || !from.CanTransitionTo(to) {
+		return &TransitionError{Kind: HistoryOrderStatus, From: string(from), To: string(to)}
+	}
+
+	result := tx.Model(&Order{}).
+		Where("id = ? AND status = ?", o.ID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleOrder
+	}
+
+	o.Status = to
+	return recordHistory(tx, o.ID, HistoryOrderStatus, string(from), string(to), actor, note)
+}
+
+// SetPaymentStatus moves the payment to a new status and records it in the history
+func (o *Order) SetPaymentStatus(tx *gorm.DB, to PaymentStatus, actor *User, note string) error {
+	from := o.PaymentStatus
+	if !to.IsValid() || !from.CanTransitionTo(to) {
+		return &TransitionError{Kind: HistoryPaymentStatus, From: string(from), To: string(to)}
+	}
+
+	result := tx.Model(&Order{}).
+		Where("id = ? AND payment_status = ?", o.ID, from).
+		Update("payment_status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleOrder
+	}
+
+	o.PaymentStatus = to
+	return recordHistory(tx, o.ID, HistoryPaymentStatus, string(from), string(to), actor, note)
+}
+
+// RecordOrderCreated writes the initial history entry for a new order
+func RecordOrderCreated(tx *gorm.DB, o *Order, actor *User) error {
+	return recordHistory(tx, o.ID, HistoryOrderStatus, "", string(o.Status), actor, "Pesanan dibuat")
+}
+
+func recordHistory(tx *gorm.DB, orderID uint, kind StatusHistoryKind, from, to string, actor *User, note string) error {
+	entry := OrderStatusHistory{
+		OrderID:   orderID,
+		Kind:      kind,
+		FromState: from,
+		ToState:   to,
+		ActorRole: ActorSystem,
+	}
+	if actor != nil {
+		entry.ActorID = &actor.ID
+		entry.ActorRole = string(actor.Role)
+	}
+	if note != "" {
+		entry.Note = &note
+	}
+	return tx.Create(&entry).Error
+}
+
+// PreloadStatusHistory preloads the history in chronological order
+func PreloadStatusHistory(db *gorm.DB) *gorm.DB {
+	return db.Preload("StatusHistory", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC, id ASC")
+	})
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// PasswordResetToken is a pending password reset. An email has at most one; a
+// new request replaces its token and code. Only hashes of the link token and the code are
+// stored, so a leaked table cannot be used to reset passwords.
+type PasswordResetToken struct {
+	Email     string    `gorm:"primaryKey;size:255" json:"-"`
+	Token     string    `gorm:"size:255;not null" json:"-"` // SHA-256 of the link token
+	CodeHash  string    `gorm:"size:64;not null" json:"-"`  // SHA-256 of the emailed code
+	ExpiresAt time.Time `json:"-"`
+	CreatedAt time.Time `json:"-"`
+
+	// Limits per email over a day. They carry over when a reset is replaced, so
+	// requesting a new code does not buy more guesses.
+	Attempts        int       `gorm:"not null;default:0" json:"-"` // Wrong codes or tokens
+	Requests        int       `gorm:"not null;default:0" json:"-"` // Reset emails sent
+	WindowStartedAt time.Time `json:"-"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+// HashSecret returns the stored form of an emailed code or link token
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
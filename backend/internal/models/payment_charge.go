@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+type ChargeStatus string
+
+const (
+	ChargePending ChargeStatus = "pending"
+	ChargePaid    ChargeStatus = "paid"
+	ChargeFailed  ChargeStatus = "failed"
+	ChargeExpired ChargeStatus = "expired"
+	// ChargeMismatch is a paid notification for an amount other than the charge;
+	// the order is not verified and an admin has to settle it by hand
+	ChargeMismatch ChargeStatus = "mismatch"
+	// ChargeRefundRequired is a payment that arrived after the order was cancelled
+	ChargeRefundRequired ChargeStatus = "refund_required"
+)
+
+// PaymentCharge is a payment request created with a payment provider for an order
+type PaymentCharge struct {
+	ID         uint         `gorm:"primaryKey" json:"id"`
+	OrderID    uint         `gorm:"not null;index" json:"order_id"`
+	Provider   string       `gorm:"size:50;not null;uniqueIndex:payment_charges_provider_external_id_unique" json:"provider"`
+	Channel    string       `gorm:"size:50;not null" json:"channel"`
+	ExternalID string       `gorm:"size:100;not null;uniqueIndex:payment_charges_provider_external_id_unique" json:"external_id"` // ID sent to the provider
+	Reference  *string      `gorm:"size:255" json:"reference,omitempty"`                                                          // Provider transaction ID
+	Amount     float64      `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Status     ChargeStatus `gorm:"type:enum('pending','paid','failed','expired','mismatch','refund_required');default:'pending'" json:"status"`
+	QRString   *string      `gorm:"type:text" json:"qr_string,omitempty"`
+	VANumber   *string      `gorm:"size:50" json:"va_number,omitempty"`
+	Bank       *string      `gorm:"size:50" json:"bank,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	PaidAt     *time.Time   `json:"paid_at,omitempty"`
+	PaidAmount *float64     `gorm:"type:decimal(12,2)" json:"paid_amount,omitempty"` // Amount the provider reported as paid
+	RawPayload *string      `gorm:"type:text" json:"-"`                              // Last provider notification, for audits
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+
+	// Relations
+	Order *Order `gorm:"foreignKey:OrderID" json:"-"`
+}
+
+func (PaymentCharge) TableName() string {
+	return "payment_charges"
+}
+
+// IsOpen reports whether the charge can still be paid
+func (pc *PaymentCharge) IsOpen() bool {
+	if pc.Status != ChargePending {
+		return false
+	}
+	return pc.ExpiresAt == nil || time.Now().Before(*pc.ExpiresAt)
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// PriceBatch is one bulk price update. The old and new prices of every product
+// and variant it touched are kept so the batch can be rolled back.
+type PriceBatch struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Percentage   float64    `gorm:"type:decimal(6,2);not null" json:"percentage"`
+	Scope        string     `gorm:"type:text" json:"scope"` // Filters the batch was applied with, as JSON
+	ItemCount    int        `gorm:"not null;default:0" json:"item_count"`
+	Forced       bool       `gorm:"not null;default:false" json:"forced"` // Applied despite margin violations
+	CreatedBy    *uint      `json:"created_by,omitempty"`
+	RolledBackAt *time.Time `json:"rolled_back_at,omitempty"`
+	RolledBackBy *uint      `json:"rolled_back_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	// Relations
+	Items []PriceBatchItem `gorm:"foreignKey:BatchID" json:"items,omitempty"`
+}
+
+func (PriceBatch) TableName() string {
+	return "price_batches"
+}
+
+// PriceBatchItem records the price change of one product, or one variant when
+// VariantID is set
+type PriceBatchItem struct {
+	ID             uint     `gorm:"primaryKey" json:"id"`
+	BatchID        uint     `gorm:"not null;index" json:"batch_id"`
+	ProductID      uint     `gorm:"not null;index" json:"product_id"`
+	VariantID      *uint    `gorm:"index" json:"variant_id,omitempty"`
+	Name           string   `gorm:"size:255" json:"name"`
+	OldPrice       float64  `gorm:"type:decimal(12,2);not null" json:"old_price"`
+	OldPrice3Items *float64 `gorm:"type:decimal(12,2)" json:"old_price_3_items,omitempty"`
+	OldPrice5Items *float64 `gorm:"type:decimal(12,2)" json:"old_price_5_items,omitempty"`
+	NewPrice       float64  `gorm:"type:decimal(12,2);not null" json:"new_price"`
+	NewPrice3Items *float64 `gorm:"type:decimal(12,2)" json:"new_price_3_items,omitempty"`
+	NewPrice5Items *float64 `gorm:"type:decimal(12,2)" json:"new_price_5_items,omitempty"`
+}
+
+func (PriceBatchItem) TableName() string {
+	return "price_batch_items"
+}
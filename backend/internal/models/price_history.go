@@ -0,0 +1,65 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PriceSource string
+
+const (
+	PriceSourceManual   PriceSource = "manual"
+	PriceSourceBulk     PriceSource = "bulk"
+	PriceSourceImport   PriceSource = "import"
+	PriceSourceSchedule PriceSource = "schedule"
+)
+
+// ProductPriceHistory records one change to the base or tier prices of a
+// product, or of one of its variants when VariantID is set. The old prices are
+// empty for the first price of a new product or variant.
+type ProductPriceHistory struct {
+	ID             uint        `gorm:"primaryKey" json:"id"`
+	ProductID      uint        `gorm:"not null;index:idx_price_history_product,priority:1" json:"product_id"`
+	VariantID      *uint       `gorm:"index" json:"variant_id,omitempty"`
+	OldPrice       *float64    `gorm:"type:decimal(15,2)" json:"old_price"`
+	OldPrice3Items *float64    `gorm:"type:decimal(12,2)" json:"old_price_3_items,omitempty"`
+	OldPrice5Items *float64    `gorm:"type:decimal(12,2)" json:"old_price_5_items,omitempty"`
+	NewPrice       float64     `gorm:"type:decimal(15,2);not null" json:"new_price"`
+	NewPrice3Items *float64    `gorm:"type:decimal(12,2)" json:"new_price_3_items,omitempty"`
+	NewPrice5Items *float64    `gorm:"type:decimal(12,2)" json:"new_price_5_items,omitempty"`
+	Source         PriceSource `gorm:"type:enum('manual','bulk','import','schedule');not null" json:"source"`
+	ReferenceID    *uint       `json:"reference_id,omitempty"` // Price batch, import job or price schedule
+	Note           *string     `gorm:"size:255" json:"note,omitempty"`
+	ChangedBy      *uint       `json:"changed_by,omitempty"`
+	CreatedAt      time.Time   `gorm:"index:idx_price_history_product,priority:2" json:"created_at"`
+
+	// Relations
+	User *User `gorm:"foreignKey:ChangedBy" json:"user,omitempty"`
+}
+
+func (ProductPriceHistory) TableName() string {
+	return "product_price_history"
+}
+
+// PricesEqual reports whether two sets of base and tier prices are equal to the
+// cent. An empty tier and a zero tier are the same.
+func PricesEqual(price float64, price3, price5 *float64, other float64, other3, other5 *float64) bool {
+	sameTier := func(a, b *float64) bool {
+		if a == nil || *a == 0 {
+			return b == nil || *b == 0
+		}
+		return b != nil && math.Abs(*a-*b) < 0.005
+	}
+	return math.Abs(price-other) < 0.005 && sameTier(price3, other3) && sameTier(price5, other5)
+}
+
+// RecordPriceChange stores a price history entry, unless the old and new prices are the same
+func RecordPriceChange(tx *gorm.DB, entry *ProductPriceHistory) error {
+	if entry.OldPrice != nil && PricesEqual(*entry.OldPrice, entry.OldPrice3Items, entry.OldPrice5Items,
+		entry.NewPrice, entry.NewPrice3Items, entry.NewPrice5Items) {
+		return nil
+	}
+	return tx.Create(entry).Error
+}
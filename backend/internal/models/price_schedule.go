@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+type PriceScheduleKind string
+type PriceScheduleStatus string
+
+const (
+	// PriceChange replaces the base and tier prices once it starts
+	PriceChange PriceScheduleKind = "change"
+	// PriceSale sells at a lower price between its start and end and then reverts by itself
+	PriceSale PriceScheduleKind = "sale"
+
+	ScheduleScheduled PriceScheduleStatus = "scheduled"
+	ScheduleActive    PriceScheduleStatus = "active" // Sales only
+	ScheduleCompleted PriceScheduleStatus = "completed"
+	ScheduleCancelled PriceScheduleStatus = "cancelled"
+)
+
+// PriceSchedule is a future price change or a time-boxed sale for a product, or
+// for one variant of a product sold in variants
+type PriceSchedule struct {
+	ID          uint                `gorm:"primaryKey" json:"id"`
+	ProductID   uint                `gorm:"not null;index" json:"product_id"`
+	VariantID   *uint               `gorm:"index" json:"variant_id,omitempty"`
+	Kind        PriceScheduleKind   `gorm:"type:enum('change','sale');not null" json:"kind"`
+	Status      PriceScheduleStatus `gorm:"type:enum('scheduled','active','completed','cancelled');default:'scheduled';index" json:"status"`
+	Price       float64             `gorm:"type:decimal(15,2);not null" json:"price"` // New base price, or the sale price
+	Price3Items *float64            `gorm:"type:decimal(12,2)" json:"price_3_items,omitempty"`
+	Price5Items *float64            `gorm:"type:decimal(12,2)" json:"price_5_items,omitempty"`
+	StartsAt    time.Time           `gorm:"not null;index" json:"starts_at"`
+	EndsAt      *time.Time          `gorm:"index" json:"ends_at,omitempty"` // Sales only
+	Note        *string             `gorm:"size:255" json:"note,omitempty"`
+	CreatedBy   *uint               `json:"created_by,omitempty"`
+	AppliedAt   *time.Time          `json:"applied_at,omitempty"`
+	EndedAt     *time.Time          `json:"ended_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+func (PriceSchedule) TableName() string {
+	return "price_schedules"
+}
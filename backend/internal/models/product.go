@@ -1,7 +1,6 @@
 package models
 
 import (
-	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -10,24 +9,41 @@ import (
 type Product struct {
 	ID              uint           `gorm:"primaryKey" json:"id"`
 	CategoryID      uint           `gorm:"not null;index" json:"category_id"`
+	SupplierID      *uint          `gorm:"index" json:"supplier_id,omitempty"`        // Default supplier for restocking
+	SKU             *string        `gorm:"size:100;uniqueIndex" json:"sku,omitempty"` // Products sold in variants use the variant SKUs
 	Name            string         `gorm:"size:255;not null;index" json:"name"`
 	Slug            string         `gorm:"size:255;uniqueIndex;not null" json:"slug"`
 	Description     *string        `gorm:"type:text" json:"description,omitempty"`
 	Price           float64        `gorm:"type:decimal(15,2);not null" json:"price"`
 	Price3Items     *float64       `gorm:"type:decimal(12,2)" json:"price_3_items,omitempty"`
 	Price5Items     *float64       `gorm:"type:decimal(12,2)" json:"price_5_items,omitempty"`
+	CostPrice       *float64       `gorm:"type:decimal(15,2)" json:"-"` // Purchase cost per unit; admin only
 	Stock           int            `gorm:"default:0" json:"stock"`
-	Weight          int            `gorm:"default:500" json:"weight"` // in grams
+	ReorderPoint    int            `gorm:"default:10" json:"reorder_point"`   // Low stock when stock falls below this
+	ReorderQuantity int            `gorm:"default:0" json:"reorder_quantity"` // Restock amount; 0 refills to twice the reorder point
+	Weight          int            `gorm:"default:500" json:"weight"`         // in grams
 	ImagePath       *string        `gorm:"size:255" json:"image_path,omitempty"`
 	SubmittedBy     *string        `gorm:"size:255" json:"submitted_by,omitempty"` // Case-sensitive subadmin name
 	LastPriceUpdate *time.Time     `json:"last_price_update,omitempty"`
+	SalePrice       *float64       `gorm:"type:decimal(15,2)" json:"sale_price,omitempty"` // Set by an active price schedule sale
+	SaleEndsAt      *time.Time     `json:"sale_ends_at,omitempty"`
+	LowStockAlertAt *time.Time     `json:"-"` // Set once the low-stock digest reported this product
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Aggregated from variants for listings (not stored)
+	PriceMin     float64 `gorm:"-" json:"price_min"`
+	PriceMax     float64 `gorm:"-" json:"price_max"`
+	TotalStock   int     `gorm:"-" json:"total_stock"`
+	VariantCount int     `gorm:"-" json:"variant_count"`
+
 	// Relations
-	Category *Category      `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
-	Images   []ProductImage `gorm:"foreignKey:ProductID" json:"images,omitempty"`
+	Category *Category        `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Supplier *Supplier        `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Images   []ProductImage   `gorm:"foreignKey:ProductID" json:"images,omitempty"`
+	Variants []ProductVariant `gorm:"foreignKey:ProductID" json:"variants,omitempty"`
+	Vehicles []Vehicle        `gorm:"many2many:product_fitments" json:"vehicles,omitempty"`
 }
 
 // GetImageURL returns full URL for the primary image
@@ -38,33 +54,79 @@ func (p *Product) GetImageURL(baseURL string) string {
 	return baseURL + "/uploads/" + *p.ImagePath
 }
 
-// GetEffectivePrice returns price based on quantity
+// GetEffectivePrice returns price based on quantity and any active sale
 func (p *Product) GetEffectivePrice(quantity int) float64 {
-	if quantity >= 5 && p.Price5Items != nil && *p.Price5Items > 0 {
-		return *p.Price5Items
+	return salePrice(tierPrice(p.Price, p.Price3Items, p.Price5Items, quantity), p.SalePrice, p.SaleEndsAt)
+}
+
+// tierPrice applies the 3- and 5-item price tiers shared by products and variants
+func tierPrice(price float64, price3, price5 *float64, quantity int) float64 {
+	if quantity >= 5 && price5 != nil && *price5 > 0 {
+		return *price5
 	}
-	if quantity >= 3 && p.Price3Items != nil && *p.Price3Items > 0 {
-		return *p.Price3Items
+	if quantity >= 3 && price3 != nil && *price3 > 0 {
+		return *price3
 	}
-	return p.Price
+	return price
 }
 
-func (Product) TableName() string {
-	return "products"
+// salePrice returns the sale price while the sale runs and is cheaper than the
+// tier price. The end time is checked here so a sale stops on time even before
+// the price schedule job clears it.
+func salePrice(price float64, sale *float64, endsAt *time.Time) float64 {
+	if sale == nil || *sale <= 0 || *sale >= price || endsAt == nil || !time.Now().Before(*endsAt) {
+		return price
+	}
+	return *sale
+}
+
+// MarginPercent returns the gross margin of a selling price over a cost, as a
+// percentage of the selling price
+func MarginPercent(price, cost float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	return (price - cost) / price * 100
+}
+
+// SKUInUse reports whether sku belongs to a product other than productID or a
+// variant other than variantID. Product and variant SKUs share one namespace,
+// deleted rows included.
+func SKUInUse(db *gorm.DB, sku string, productID, variantID uint) (bool, error) {
+	var count int64
+	if err := db.Model(&Product{}).Unscoped().Where("sku = ? AND id != ?", sku, productID).Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	if err := db.Model(&ProductVariant{}).Unscoped().Where("sku = ? AND id != ?", sku, variantID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
-// Scope for searching products
-func ProductSearch(query string) func(db *gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		if query == "" {
-			return db
-		}
-		terms := strings.Fields(query)
-		for _, term := range terms {
-			if term != "" {
-				db = db.Where("name LIKE ?", "%"+term+"%")
-			}
-		}
-		return db
+// DefaultReorderPoint is the reorder point of products that were never given one
+const DefaultReorderPoint = 10
+
+// SuggestedReorderQuantity returns how many units to order for the given stock
+func (p *Product) SuggestedReorderQuantity(stock int) int {
+	if p.ReorderQuantity > 0 {
+		return p.ReorderQuantity
 	}
+	return max(2*p.ReorderPoint-stock, 1)
+}
+
+// LowStock limits a product query to products below their own reorder point.
+// A product sold in variants is compared by its total stock; LowStockVariants
+// checks each variant on its own.
+func LowStock(db *gorm.DB) *gorm.DB {
+	return db.Where("products.stock < products.reorder_point")
+}
+
+// LowStockVariants limits a variant query, joined with products, to variants
+// below the reorder point of their product
+func LowStockVariants(db *gorm.DB) *gorm.DB {
+	return db.Where("product_variants.stock < products.reorder_point")
+}
+
+func (Product) TableName() string {
+	return "products"
 }
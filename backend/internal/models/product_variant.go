@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductVariant is a sellable option of a product (size, color or bike fitment)
+// with its own SKU, price tiers, stock and weight
+type ProductVariant struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	ProductID       uint           `gorm:"not null;index" json:"product_id"`
+	SKU             string         `gorm:"size:100;uniqueIndex;not null" json:"sku"`
+	Name            string         `gorm:"size:255;not null" json:"name"` // e.g. "Merah / L"
+	Size            *string        `gorm:"size:100" json:"size,omitempty"`
+	Color           *string        `gorm:"size:100" json:"color,omitempty"`
+	Fitment         *string        `gorm:"size:255" json:"fitment,omitempty"`
+	Price           float64        `gorm:"type:decimal(15,2);not null" json:"price"`
+	Price3Items     *float64       `gorm:"type:decimal(12,2)" json:"price_3_items,omitempty"`
+	Price5Items     *float64       `gorm:"type:decimal(12,2)" json:"price_5_items,omitempty"`
+	CostPrice       *float64       `gorm:"type:decimal(15,2)" json:"-"`                    // Falls back to the product cost; admin only
+	SalePrice       *float64       `gorm:"type:decimal(15,2)" json:"sale_price,omitempty"` // Set by an active price schedule sale
+	SaleEndsAt      *time.Time     `json:"sale_ends_at,omitempty"`
+	Stock           int            `gorm:"default:0" json:"stock"`
+	Weight          int            `gorm:"default:500" json:"weight"` // in grams
+	LowStockAlertAt *time.Time     `json:"-"`                         // Set once the low-stock digest reported this variant
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relations
+	Product *Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+func (ProductVariant) TableName() string {
+	return "product_variants"
+}
+
+// GetEffectivePrice returns the variant price based on quantity and any active sale
+func (v *ProductVariant) GetEffectivePrice(quantity int) float64 {
+	return salePrice(tierPrice(v.Price, v.Price3Items, v.Price5Items, quantity), v.SalePrice, v.SaleEndsAt)
+}
+
+// VariantSummary aggregates the variants of a product
+type VariantSummary struct {
+	ProductID  uint
+	PriceMin   float64
+	PriceMax   float64
+	TotalStock int
+	Count      int
+}
+
+// SyncProductFromVariants keeps the parent product's stock equal to the sum of
+// its variant stock and its price equal to the cheapest variant, so listings,
+// sorting and "in stock" filters keep working on the products table
+func SyncProductFromVariants(tx *gorm.DB, productID uint) error {
+	var summary VariantSummary
+	if err := tx.Model(&ProductVariant{}).
+		Select("product_id, MIN(price) AS price_min, MAX(price) AS price_max, COALESCE(SUM(stock), 0) AS total_stock, COUNT(*) AS count").
+		Where("product_id = ?", productID).
+		Group("product_id").
+		Scan(&summary).Error; err != nil {
+		return err
+	}
+	if summary.Count == 0 {
+		return nil
+	}
+	return tx.Unscoped().Model(&Product{}).
+		Where("id = ?", productID).
+		Updates(map[string]interface{}{
+			"stock": summary.TotalStock,
+			"price": summary.PriceMin,
+		}).Error
+}
+
+// AttachVariantSummaries fills the price range and total stock of each product
+// from its variants; products without variants use their own price and stock
+func AttachVariantSummaries(db *gorm.DB, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(products))
+	for i := range products {
+		ids[i] = products[i].ID
+	}
+
+	var summaries []VariantSummary
+	if err := db.Model(&ProductVariant{}).
+		Select("product_id, MIN(price) AS price_min, MAX(price) AS price_max, COALESCE(SUM(stock), 0) AS total_stock, COUNT(*) AS count").
+		Where("product_id IN ?", ids).
+		Group("product_id").
+		Scan(&summaries).Error; err != nil {
+		return err
+	}
+
+	byProduct := make(map[uint]VariantSummary, len(summaries))
+	for _, s := range summaries {
+		byProduct[s.ProductID] = s
+	}
+
+	for i := range products {
+		p := &products[i]
+		if s, ok := byProduct[p.ID]; ok {
+			p.PriceMin, p.PriceMax, p.TotalStock, p.VariantCount = s.PriceMin, s.PriceMax, s.TotalStock, s.Count
+		} else {
+			p.PriceMin, p.PriceMax, p.TotalStock = p.Price, p.Price, p.Stock
+		}
+	}
+	return nil
+}
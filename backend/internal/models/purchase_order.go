@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PurchaseOrderStatus string
+
+const (
+	PurchaseDraft             PurchaseOrderStatus = "draft"
+	PurchaseOrdered           PurchaseOrderStatus = "ordered"
+	PurchasePartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseReceived          PurchaseOrderStatus = "received"
+	PurchaseCancelled         PurchaseOrderStatus = "cancelled"
+	// PurchaseClosed is a partially received order whose rest will not be delivered
+	PurchaseClosed PurchaseOrderStatus = "closed"
+
+	// ReferencePurchaseOrder marks inventory movements from goods receiving
+	ReferencePurchaseOrder = "purchase_order"
+)
+
+// Supplier is a vendor the shop buys stock from
+type Supplier struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"size:255;not null" json:"name"`
+	ContactName *string        `gorm:"size:255" json:"contact_name,omitempty"`
+	Phone       *string        `gorm:"size:50" json:"phone,omitempty"`
+	Email       *string        `gorm:"size:255" json:"email,omitempty"`
+	Address     *string        `gorm:"type:text" json:"address,omitempty"`
+	Notes       *string        `gorm:"type:text" json:"notes,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Supplier) TableName() string {
+	return "suppliers"
+}
+
+// PurchaseOrder is an order for stock placed with a supplier
+type PurchaseOrder struct {
+	ID         uint                `gorm:"primaryKey" json:"id"`
+	Number     string              `gorm:"size:50;uniqueIndex;not null" json:"number"`
+	SupplierID uint                `gorm:"not null;index" json:"supplier_id"`
+	Status     PurchaseOrderStatus `gorm:"type:enum('draft','ordered','partially_received','received','cancelled','closed');default:'draft'" json:"status"`
+	Notes      *string             `gorm:"type:text" json:"notes,omitempty"`
+	CreatedBy  *uint               `json:"created_by,omitempty"`
+	OrderedAt  *time.Time          `json:"ordered_at,omitempty"`
+	ReceivedAt *time.Time          `json:"received_at,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt      `gorm:"index" json:"-"`
+
+	// Relations
+	Supplier *Supplier           `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Items    []PurchaseOrderItem `gorm:"foreignKey:PurchaseOrderID" json:"items,omitempty"`
+}
+
+func (PurchaseOrder) TableName() string {
+	return "purchase_orders"
+}
+
+// PurchaseOrderItem is one line of a purchase order
+type PurchaseOrderItem struct {
+	ID               uint    `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID  uint    `gorm:"not null;index" json:"purchase_order_id"`
+	ProductID        uint    `gorm:"not null;index" json:"product_id"`
+	VariantID        *uint   `gorm:"index" json:"variant_id,omitempty"`
+	Quantity         int     `gorm:"not null" json:"quantity"`
+	ReceivedQuantity int     `gorm:"default:0" json:"received_quantity"`
+	UnitCost         float64 `gorm:"type:decimal(12,2);default:0" json:"unit_cost"` // Expected cost, updated to the actual cost on receiving
+
+	// Relations
+	Product *Product        `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Variant *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
+}
+
+func (PurchaseOrderItem) TableName() string {
+	return "purchase_order_items"
+}
+
+// Outstanding returns how many units are still to be received
+func (i *PurchaseOrderItem) Outstanding() int {
+	if i.ReceivedQuantity >= i.Quantity {
+		return 0
+	}
+	return i.Quantity - i.ReceivedQuantity
+}
+
+// GeneratePurchaseOrderNumber creates a purchase order number: PO-YYYYMMDD-XXXXX
+func GeneratePurchaseOrderNumber() string {
+	date := time.Now().Format("20060102")
+	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	suffix := make([]byte, 5)
+	for i := range suffix {
+		suffix[i] = chars[rand.Intn(len(chars))]
+	}
+	return fmt.Sprintf("PO-%s-%s", date, string(suffix))
+}
+
+// IsEditable reports whether the purchase order lines may still change
+func (po *PurchaseOrder) IsEditable() bool {
+	return po.Status == PurchaseDraft
+}
+
+// CanReceive reports whether goods can be received against the purchase order
+func (po *PurchaseOrder) CanReceive() bool {
+	return po.Status == PurchaseOrdered || po.Status == PurchasePartiallyReceived
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// Sales rollups are pre-aggregated per day from paid orders (payment verified,
+// not cancelled) by the sales_rollup job, so analytics never scan the order tables.
+
+// SalesDaily holds the order totals of one day
+type SalesDaily struct {
+	Date       time.Time `gorm:"type:date;primaryKey" json:"date"`
+	Orders     int       `gorm:"not null;default:0" json:"orders"`
+	Items      int       `gorm:"not null;default:0" json:"items"`
+	Customers  int       `gorm:"not null;default:0" json:"customers"`
+	GrossSales float64   `gorm:"type:decimal(15,2);not null;default:0" json:"gross_sales"` // Sum of order subtotals
+	Discount   float64   `gorm:"type:decimal(15,2);not null;default:0" json:"discount"`
+	Shipping   float64   `gorm:"type:decimal(15,2);not null;default:0" json:"shipping"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (SalesDaily) TableName() string {
+	return "sales_daily"
+}
+
+// SalesDailyProduct holds the units and revenue of one product on one day
+type SalesDailyProduct struct {
+	Date       time.Time `gorm:"type:date;primaryKey" json:"date"`
+	ProductID  uint      `gorm:"primaryKey" json:"product_id"`
+	CategoryID uint      `gorm:"not null;index" json:"category_id"`
+	Quantity   int       `gorm:"not null;default:0" json:"quantity"`
+	Revenue    float64   `gorm:"type:decimal(15,2);not null;default:0" json:"revenue"`
+}
+
+func (SalesDailyProduct) TableName() string {
+	return "sales_daily_products"
+}
+
+// SalesDailyShipping holds the orders of one day per shipping method and courier
+type SalesDailyShipping struct {
+	Date           time.Time      `gorm:"type:date;primaryKey" json:"date"`
+	ShippingMethod ShippingMethod `gorm:"size:20;primaryKey" json:"shipping_method"`
+	Courier        string         `gorm:"size:100;primaryKey" json:"courier"` // Empty for pickup and ojol
+	Orders         int            `gorm:"not null;default:0" json:"orders"`
+	Revenue        float64        `gorm:"type:decimal(15,2);not null;default:0" json:"revenue"`
+	Shipping       float64        `gorm:"type:decimal(15,2);not null;default:0" json:"shipping"`
+}
+
+func (SalesDailyShipping) TableName() string {
+	return "sales_daily_shipping"
+}
+
+// SalesDailyCustomer holds the orders of one customer on one day, for repeat and cohort analysis
+type SalesDailyCustomer struct {
+	Date    time.Time `gorm:"type:date;primaryKey" json:"date"`
+	UserID  uint      `gorm:"primaryKey;index" json:"user_id"`
+	Orders  int       `gorm:"not null;default:0" json:"orders"`
+	Revenue float64   `gorm:"type:decimal(15,2);not null;default:0" json:"revenue"`
+}
+
+func (SalesDailyCustomer) TableName() string {
+	return "sales_daily_customers"
+}
@@ -41,6 +41,15 @@ type User struct {
 	OTPCode      *string    `gorm:"size:6" json:"-"`
 	OTPExpiresAt *time.Time `json:"-"`
 
+	// Login lockout
+	FailedLoginAttempts int        `gorm:"not null;default:0" json:"-"`
+	LastFailedLoginAt   *time.Time `json:"-"`
+	LockedUntil         *time.Time `json:"locked_until,omitempty"` // Password and OTP checks are refused until then
+
+	// Refresh tokens signed before the session store have no session to revoke;
+	// those issued before this time are rejected
+	SessionsRevokedAt *time.Time `json:"-"`
+
 	// Timestamp
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -90,3 +99,12 @@ func (u *User) GetFullAddress() string {
 func (User) TableName() string {
 	return "users"
 }
+
+// ResetLoginFailures clears the failed login counter and any lockout of a user
+func ResetLoginFailures(db *gorm.DB, userID uint) error {
+	return db.Model(&User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+		"failed_login_attempts": 0,
+		"last_failed_login_at":  nil,
+		"locked_until":          nil,
+	}).Error
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Vehicle is a motorcycle model in the fitment catalog, e.g. Honda Vario 125 2018-2022
+type Vehicle struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Brand     string         `gorm:"size:100;not null;uniqueIndex:vehicles_brand_model_year_unique" json:"brand"`
+	Model     string         `gorm:"size:150;not null;uniqueIndex:vehicles_brand_model_year_unique" json:"model"`
+	YearStart int            `gorm:"not null;uniqueIndex:vehicles_brand_model_year_unique" json:"year_start"`
+	YearEnd   *int           `json:"year_end,omitempty"` // nil = still in production
+	EngineCC  *int           `json:"engine_cc,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relations
+	Products []Product `gorm:"many2many:product_fitments" json:"-"`
+}
+
+func (Vehicle) TableName() string {
+	return "vehicles"
+}
+
+// Label returns a customer-facing name such as "Honda Vario 125 (2018-2022)"
+func (v *Vehicle) Label() string {
+	years := fmt.Sprintf("%d-sekarang", v.YearStart)
+	if v.YearEnd != nil {
+		years = fmt.Sprintf("%d-%d", v.YearStart, *v.YearEnd)
+		if *v.YearEnd == v.YearStart {
+			years = fmt.Sprintf("%d", v.YearStart)
+		}
+	}
+	return fmt.Sprintf("%s %s (%s)", v.Brand, v.Model, years)
+}
+
+// CoversYear reports whether the vehicle was produced in the given year
+func (v *Vehicle) CoversYear(year int) bool {
+	if year < v.YearStart {
+		return false
+	}
+	return v.YearEnd == nil || year <= *v.YearEnd
+}
+
+// UserVehicle is a bike a customer saved on their profile
+type UserVehicle struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:user_vehicles_user_vehicle_unique" json:"user_id"`
+	VehicleID uint      `gorm:"not null;uniqueIndex:user_vehicles_user_vehicle_unique" json:"vehicle_id"`
+	Nickname  *string   `gorm:"size:100" json:"nickname,omitempty"` // e.g. "Motor kantor"
+	Year      *int      `json:"year,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relations
+	User    *User    `gorm:"foreignKey:UserID" json:"-"`
+	Vehicle *Vehicle `gorm:"foreignKey:VehicleID" json:"vehicle,omitempty"`
+}
+
+func (UserVehicle) TableName() string {
+	return "user_vehicles"
+}
+
+// FitsVehicle limits a product query to parts listed as fitting the vehicle
+func FitsVehicle(vehicleID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("EXISTS (SELECT 1 FROM product_fitments pf WHERE pf.product_id = products.id AND pf.vehicle_id = ?)", vehicleID)
+	}
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type VoucherType string
+
+const (
+	VoucherPercentage VoucherType = "percentage"
+	VoucherFixed      VoucherType = "fixed"
+)
+
+type Voucher struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	Code         string         `gorm:"size:50;uniqueIndex;not null" json:"code"`
+	Description  *string        `gorm:"type:text" json:"description,omitempty"`
+	Type         VoucherType    `gorm:"type:enum('percentage','fixed');not null" json:"type"`
+	Value        float64        `gorm:"type:decimal(12,2);not null" json:"value"`
+	MaxDiscount  *float64       `gorm:"type:decimal(12,2)" json:"max_discount,omitempty"` // Cap for percentage vouchers
+	MinSpend     float64        `gorm:"type:decimal(12,2);default:0" json:"min_spend"`
+	UsageLimit   *int           `json:"usage_limit,omitempty"`    // Total redemptions, nil = unlimited
+	PerUserLimit *int           `json:"per_user_limit,omitempty"` // Redemptions per customer, nil = unlimited
+	UsedCount    int            `gorm:"default:0" json:"used_count"`
+	StartsAt     *time.Time     `json:"starts_at,omitempty"`
+	EndsAt       *time.Time     `json:"ends_at,omitempty"`
+	IsActive     bool           `gorm:"not null" json:"is_active"` // No default tag, so a false value is inserted
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Restrictions; when both are empty the voucher applies to the whole cart
+	Categories []Category `gorm:"many2many:voucher_categories" json:"categories,omitempty"`
+	Products   []Product  `gorm:"many2many:voucher_products" json:"products,omitempty"`
+}
+
+func (Voucher) TableName() string {
+	return "vouchers"
+}
+
+// VoucherRedemption records a voucher used on an order; it is removed when the order is cancelled
+type VoucherRedemption struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	VoucherID      uint      `gorm:"not null;index" json:"voucher_id"`
+	UserID         uint      `gorm:"not null;index" json:"user_id"`
+	OrderID        uint      `gorm:"not null;uniqueIndex" json:"order_id"`
+	DiscountAmount float64   `gorm:"type:decimal(12,2);not null" json:"discount_amount"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// Relations
+	Voucher *Voucher `gorm:"foreignKey:VoucherID" json:"-"`
+}
+
+func (VoucherRedemption) TableName() string {
+	return "voucher_redemptions"
+}
+
+// VoucherError explains to the customer why a voucher cannot be used
+type VoucherError struct {
+	Message string
+}
+
+func (e *VoucherError) Error() string {
+	return e.Message
+}
+
+// VoucherResult is a voucher applied to a cart
+type VoucherResult struct {
+	Voucher          *Voucher `json:"-"`
+	Code             string   `json:"code"`
+	EligibleSubtotal float64  `json:"eligible_subtotal"`
+	Discount         float64  `json:"discount"`
+}
+
+// NormalizeVoucherCode makes codes case-insensitive
+func NormalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// IsRunning reports whether the voucher is active and inside its validity window
+func (v *Voucher) IsRunning(now time.Time) bool {
+	if !v.IsActive {
+		return false
+	}
+	if v.StartsAt != nil && now.Before(*v.StartsAt) {
+		return false
+	}
+	if v.EndsAt != nil && now.After(*v.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// appliesTo reports whether a product is covered by the voucher restrictions
+func (v *Voucher) appliesTo(p *Product) bool {
+	if len(v.Categories) == 0 && len(v.Products) == 0 {
+		return true
+	}
+	for _, vp := range v.Products {
+		if vp.ID == p.ID {
+			return true
+		}
+	}
+	for _, vc := range v.Categories {
+		if vc.ID == p.CategoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// EligibleSubtotal sums the cart lines the voucher applies to
+func (v *Voucher) EligibleSubtotal(items []CartItem) float64 {
+	var subtotal float64
+	for i := range items {
+		if items[i].Product != nil && v.appliesTo(items[i].Product) {
+			subtotal += items[i].GetSubtotal()
+		}
+	}
+	return subtotal
+}
+
+// CalculateDiscount returns the discount for an eligible subtotal, never more than the subtotal
+func (v *Voucher) CalculateDiscount(eligible float64) float64 {
+	var discount float64
+	switch v.Type {
+	case VoucherPercentage:
+		discount = math.Floor(eligible * v.Value / 100)
+		if v.MaxDiscount != nil && *v.MaxDiscount > 0 && discount > *v.MaxDiscount {
+			discount = *v.MaxDiscount
+		}
+	case VoucherFixed:
+		discount = v.Value
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	if discount < 0 {
+		discount = 0
+	}
+	return discount
+}
+
+// ApplyVoucher validates a voucher code for a user's cart and computes the discount.
+// Pass a transaction with lock=true at checkout so usage limits cannot be raced.
+func ApplyVoucher(db *gorm.DB, code string, userID uint, items []CartItem, lock bool) (*VoucherResult, error) {
+	code = NormalizeVoucherCode(code)
+	if code == "" {
+		return nil, &VoucherError{Message: "Kode voucher kosong"}
+	}
+
+	query := db.Preload("Categories").Preload("Products")
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+
+	var voucher Voucher
+	if err := query.Where("code = ?", code).First(&voucher).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &VoucherError{Message: "Kode voucher tidak ditemukan"}
+		}
+		return nil, err
+	}
+
+	if !voucher.IsRunning(time.Now()) {
+		return nil, &VoucherError{Message: "Voucher tidak aktif atau sudah kadaluarsa"}
+	}
+	if voucher.UsageLimit != nil && voucher.UsedCount >= *voucher.UsageLimit {
+		return nil, &VoucherError{Message: "Kuota voucher sudah habis"}
+	}
+	if voucher.PerUserLimit != nil {
+		var used int64
+		if err := db.Model(&VoucherRedemption{}).
+			Where("voucher_id = ? AND user_id = ?", voucher.ID, userID).
+			Count(&used).Error; err != nil {
+			return nil, err
+		}
+		if used >= int64(*voucher.PerUserLimit) {
+			return nil, &VoucherError{Message: "Anda sudah mencapai batas penggunaan voucher ini"}
+		}
+	}
+
+	eligible := voucher.EligibleSubtotal(items)
+	if eligible <= 0 {
+		return nil, &VoucherError{Message: "Voucher tidak berlaku untuk produk di keranjang"}
+	}
+	if eligible < voucher.MinSpend {
+		return nil, &VoucherError{Message: "Belum mencapai minimum belanja voucher"}
+	}
+
+	return &VoucherResult{
+		Voucher:          &voucher,
+		Code:             voucher.Code,
+		EligibleSubtotal: eligible,
+		Discount:         voucher.CalculateDiscount(eligible),
+	}, nil
+}
+
+// RedeemVoucher records the voucher on an order and counts the usage
+func RedeemVoucher(tx *gorm.DB, result *VoucherResult, userID, orderID uint) error {
+	redemption := VoucherRedemption{
+		VoucherID:      result.Voucher.ID,
+		UserID:         userID,
+		OrderID:        orderID,
+		DiscountAmount: result.Discount,
+	}
+	if err := tx.Create(&redemption).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Voucher{}).
+		Where("id = ?", result.Voucher.ID).
+		Update("used_count", gorm.Expr("used_count + 1")).Error
+}
+
+// ReleaseVoucher gives the voucher usage back when an order is cancelled
+func ReleaseVoucher(tx *gorm.DB, orderID uint) error {
+	var redemption VoucherRedemption
+	if err := tx.Where("order_id = ?", orderID).First(&redemption).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := tx.Delete(&redemption).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&Voucher{}).
+		Where("id = ? AND used_count > 0", redemption.VoucherID).
+		Update("used_count", gorm.Expr("used_count - 1")).Error
+}
@@ -0,0 +1,81 @@
+package orders
+
+import (
+	"errors"
+	"time"
+
+	"gsm-motor/internal/inventory"
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotCancellable is returned when an order is past the point where it can be cancelled
+var ErrNotCancellable = errors.New("order can no longer be cancelled")
+
+// LockOrder loads an order with SELECT ... FOR UPDATE inside tx
+func LockOrder(tx *gorm.DB, id uint) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CanCustomerCancel reports whether the customer may still cancel the order:
+// it must be pending and the payment must not have been verified
+func CanCustomerCancel(order *models.Order) bool {
+	return order.Status == models.OrderPending && order.PaymentStatus != models.PaymentVerified
+}
+
+// Cancel cancels a locked order, returns every item quantity to stock and
+// records the reason. It must run inside the transaction that locked the order.
+// A nil actor means the system cancelled the order.
+func Cancel(tx *gorm.DB, order *models.Order, actor *models.User, reason string) error {
+	if !order.Status.CanTransitionTo(models.OrderCancelled) {
+		return ErrNotCancellable
+	}
+
+	if err := order.TransitionTo(tx, models.OrderCancelled, actor, reason); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"cancelled_at": now}
+	if reason != "" {
+		updates["cancel_reason"] = reason
+		order.CancelReason = &reason
+	}
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	order.CancelledAt = &now
+
+	if err := models.ReleaseVoucher(tx, order.ID); err != nil {
+		return err
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	// Restock even if the product or variant was soft-deleted in the meantime
+	for _, item := range items {
+		_, err := inventory.Apply(tx, inventory.Change{
+			ProductID:     item.ProductID,
+			VariantID:     item.VariantID,
+			Delta:         item.Quantity,
+			Type:          models.MovementCancelRestock,
+			Actor:         actor,
+			ReferenceType: models.ReferenceOrder,
+			ReferenceID:   order.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
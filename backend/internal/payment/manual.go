@@ -0,0 +1,40 @@
+package payment
+
+import (
+	"context"
+	"net/http"
+
+	"gsm-motor/internal/config"
+	"gsm-motor/internal/models"
+)
+
+// ManualProvider is the original flow: the customer transfers to the store's
+// bank account and uploads a screenshot that an admin verifies
+type ManualProvider struct{}
+
+func (ManualProvider) Name() string { return "manual" }
+
+func (ManualProvider) Channels() []string { return []string{ChannelBankTransfer} }
+
+func (ManualProvider) CreateCharge(ctx context.Context, order *models.Order, channel string) (*Charge, error) {
+	if channel != ChannelBankTransfer {
+		return nil, ErrUnsupportedChannel
+	}
+
+	cfg := config.AppConfig
+	return &Charge{
+		ExternalID: order.OrderNumber,
+		Channel:    channel,
+		Amount:     order.GetGrandTotal(),
+		Bank:       cfg.BankName,
+		Instructions: map[string]string{
+			"bank_name":      cfg.BankName,
+			"account_name":   cfg.BankAccount,
+			"account_number": cfg.BankNumber,
+		},
+	}, nil
+}
+
+func (ManualProvider) ParseNotification(r *http.Request) (*Notification, error) {
+	return nil, ErrNotificationsUnsupported
+}
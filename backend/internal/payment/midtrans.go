@@ -0,0 +1,169 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gsm-motor/internal/models"
+)
+
+// MidtransProvider charges QRIS and bank virtual accounts through the Midtrans Core API
+type MidtransProvider struct {
+	ServerKey  string
+	BaseURL    string // https://api.sandbox.midtrans.com or https://api.midtrans.com
+	VABank     string // bca, bni, bri, permata
+	HTTPClient *http.Client
+}
+
+// NewMidtransProvider creates a Midtrans provider
+func NewMidtransProvider(serverKey, baseURL, vaBank string) *MidtransProvider {
+	if vaBank == "" {
+		vaBank = "bca"
+	}
+	return &MidtransProvider{
+		ServerKey:  serverKey,
+		BaseURL:    baseURL,
+		VABank:     vaBank,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *MidtransProvider) Name() string { return "midtrans" }
+
+func (p *MidtransProvider) Channels() []string { return []string{ChannelQRIS, ChannelVA} }
+
+func (p *MidtransProvider) CreateCharge(ctx context.Context, order *models.Order, channel string) (*Charge, error) {
+	externalID := fmt.Sprintf("%s-%d", order.OrderNumber, time.Now().Unix())
+	amount := int64(order.GetGrandTotal())
+
+	payload := map[string]interface{}{
+		"transaction_details": map[string]interface{}{
+			"order_id":     externalID,
+			"gross_amount": amount,
+		},
+	}
+	switch channel {
+	case ChannelQRIS:
+		payload["payment_type"] = "qris"
+	case ChannelVA:
+		payload["payment_type"] = "bank_transfer"
+		payload["bank_transfer"] = map[string]string{"bank": p.VABank}
+	default:
+		return nil, ErrUnsupportedChannel
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.BaseURL+"/v2/charge", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(p.ServerKey, "")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		StatusCode    string `json:"status_code"`
+		StatusMessage string `json:"status_message"`
+		TransactionID string `json:"transaction_id"`
+		QRString      string `json:"qr_string"`
+		ExpiryTime    string `json:"expiry_time"`
+		VANumbers     []struct {
+			Bank     string `json:"bank"`
+			VANumber string `json:"va_number"`
+		} `json:"va_numbers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	// Midtrans reports errors in the body, with 201 meaning the charge was created
+	if resp.StatusCode >= 300 || result.StatusCode != "201" {
+		return nil, fmt.Errorf("midtrans error: %s - %s", result.StatusCode, result.StatusMessage)
+	}
+
+	charge := &Charge{
+		ExternalID: externalID,
+		Reference:  result.TransactionID,
+		Channel:    channel,
+		Amount:     float64(amount),
+		QRString:   result.QRString,
+	}
+	if len(result.VANumbers) > 0 {
+		charge.VANumber = result.VANumbers[0].VANumber
+		charge.Bank = result.VANumbers[0].Bank
+	}
+	if expiry, err := time.ParseInLocation("2006-01-02 15:04:05", result.ExpiryTime, time.Local); err == nil {
+		charge.ExpiresAt = &expiry
+	}
+	return charge, nil
+}
+
+// ParseNotification verifies the signature_key Midtrans puts in every HTTP
+// notification: SHA512(order_id + status_code + gross_amount + server_key)
+func (p *MidtransProvider) ParseNotification(r *http.Request) (*Notification, error) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var n struct {
+		OrderID           string `json:"order_id"`
+		TransactionID     string `json:"transaction_id"`
+		TransactionStatus string `json:"transaction_status"`
+		FraudStatus       string `json:"fraud_status"`
+		StatusCode        string `json:"status_code"`
+		GrossAmount       string `json:"gross_amount"`
+		SignatureKey      string `json:"signature_key"`
+	}
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return nil, fmt.Errorf("failed to decode notification: %w", err)
+	}
+
+	sum := sha512.Sum512([]byte(n.OrderID + n.StatusCode + n.GrossAmount + p.ServerKey))
+	expected := hex.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(n.SignatureKey)) != 1 {
+		return nil, ErrInvalidSignature
+	}
+
+	amount, _ := strconv.ParseFloat(n.GrossAmount, 64)
+
+	status := models.ChargePending
+	switch n.TransactionStatus {
+	case "settlement":
+		status = models.ChargePaid
+	case "capture":
+		if n.FraudStatus == "" || n.FraudStatus == "accept" {
+			status = models.ChargePaid
+		}
+	case "expire":
+		status = models.ChargeExpired
+	case "deny", "cancel", "failure":
+		status = models.ChargeFailed
+	}
+
+	return &Notification{
+		ExternalID: n.OrderID,
+		Reference:  n.TransactionID,
+		Status:     status,
+		Amount:     amount,
+		Raw:        string(raw),
+	}, nil
+}
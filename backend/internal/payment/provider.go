@@ -0,0 +1,108 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"gsm-motor/internal/models"
+)
+
+var (
+	// ErrUnknownProvider is returned when a provider is not registered
+	ErrUnknownProvider = errors.New("unknown payment provider")
+	// ErrUnsupportedChannel is returned when a provider does not offer the requested channel
+	ErrUnsupportedChannel = errors.New("unsupported payment channel")
+	// ErrInvalidSignature is returned when a webhook notification fails verification
+	ErrInvalidSignature = errors.New("invalid notification signature")
+	// ErrNotificationsUnsupported is returned by providers that have no webhooks
+	ErrNotificationsUnsupported = errors.New("provider does not send notifications")
+)
+
+// Channel names shared by the providers
+const (
+	ChannelBankTransfer = "bank_transfer"
+	ChannelQRIS         = "qris"
+	ChannelVA           = "va"
+)
+
+// Charge is what the customer needs to complete a payment
+type Charge struct {
+	ExternalID string
+	Reference  string
+	Channel    string
+	Amount     float64
+	QRString   string
+	VANumber   string
+	Bank       string
+	ExpiresAt  *time.Time
+	// Instructions carries provider specific display data (e.g. bank account details)
+	Instructions map[string]string
+}
+
+// Notification is a verified payment update received from a provider webhook
+type Notification struct {
+	ExternalID string
+	Reference  string
+	Status     models.ChargeStatus
+	Amount     float64
+	Raw        string
+}
+
+// Provider is a way for customers to pay for an order. Gateways create a
+// charge (QRIS string or virtual account) and confirm it through a signed
+// webhook; the manual provider only shows bank details for a transfer.
+type Provider interface {
+	Name() string
+	Channels() []string
+	CreateCharge(ctx context.Context, order *models.Order, channel string) (*Charge, error)
+	ParseNotification(r *http.Request) (*Notification, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{}
+)
+
+// Register makes a provider available by its name
+func Register(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name()] = p
+}
+
+// Get returns a registered provider
+func Get(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Available returns the registered providers sorted by name
+func Available() []Provider {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// SupportsChannel reports whether the provider offers the channel
+func SupportsChannel(p Provider, channel string) bool {
+	for _, ch := range p.Channels() {
+		if ch == channel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package payment
+
+import (
+	"log"
+	"strings"
+
+	"gsm-motor/internal/config"
+)
+
+// Setup registers the providers enabled in PAYMENT_PROVIDERS
+func Setup() {
+	cfg := config.AppConfig
+
+	for _, name := range strings.Split(cfg.PaymentProviders, ",") {
+		switch strings.TrimSpace(name) {
+		case "manual":
+			Register(ManualProvider{})
+		case "midtrans":
+			if cfg.MidtransServerKey == "" {
+				log.Println("Warning: midtrans enabled but MIDTRANS_SERVER_KEY is empty, skipping")
+				continue
+			}
+			Register(NewMidtransProvider(cfg.MidtransServerKey, cfg.MidtransBaseURL, cfg.MidtransVABank))
+		case "fake":
+			// Never let the fake gateway mark real orders as paid
+			if cfg.AppEnv == "production" {
+				log.Println("Warning: fake payment provider is not allowed in production, skipping")
+				continue
+			}
+			// A guessable secret would let anyone sign a paid webhook
+			if cfg.FakePaymentSecret == "" {
+				log.Println("Warning: fake payment provider enabled but FAKE_PAYMENT_SECRET is empty, skipping")
+				continue
+			}
+			Register(&FakeProvider{Secret: cfg.FakePaymentSecret})
+		case "":
+		default:
+			log.Printf("Warning: unknown payment provider %q", name)
+		}
+	}
+
+	// The manual transfer flow is always available as a fallback
+	if _, err := Get("manual"); err != nil {
+		Register(ManualProvider{})
+	}
+}
@@ -0,0 +1,202 @@
+// Package pricing applies scheduled price changes and starts and ends sales.
+package pricing
+
+import (
+	"errors"
+	"time"
+
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Summary counts what one ApplyDue run did
+type Summary struct {
+	Changed   int
+	Started   int
+	Ended     int
+	Cancelled int // Schedules whose product or variant no longer exists
+}
+
+// ApplyDue applies the price changes whose start time has passed, starts the
+// sales that are due and ends the sales that are over. Each schedule is handled
+// in its own transaction, so one failure does not hold back the others.
+func ApplyDue(db *gorm.DB, now time.Time) (Summary, error) {
+	var summary Summary
+
+	var due []models.PriceSchedule
+	if err := db.Where("status = ? AND starts_at <= ?", models.ScheduleScheduled, now).
+		Or("status = ? AND ends_at <= ?", models.ScheduleActive, now).
+		Order("starts_at ASC, id ASC").
+		Find(&due).Error; err != nil {
+		return summary, err
+	}
+
+	for _, schedule := range due {
+		var outcome string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			outcome, err = applySchedule(tx, schedule.ID, now)
+			return err
+		})
+		if err != nil {
+			return summary, err
+		}
+		switch outcome {
+		case "changed":
+			summary.Changed++
+		case "started":
+			summary.Started++
+		case "ended":
+			summary.Ended++
+		case "cancelled":
+			summary.Cancelled++
+		}
+	}
+	return summary, nil
+}
+
+// applySchedule moves one schedule forward and returns what happened to it
+func applySchedule(tx *gorm.DB, id uint, now time.Time) (string, error) {
+	var schedule models.PriceSchedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, id).Error; err != nil {
+		return "", err
+	}
+
+	target, err := lockTarget(tx, &schedule)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "cancelled", tx.Model(&schedule).Updates(map[string]interface{}{
+			"status":   models.ScheduleCancelled,
+			"ended_at": now,
+		}).Error
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case schedule.Kind == models.PriceChange && schedule.Status == models.ScheduleScheduled && !schedule.StartsAt.After(now):
+		if err := changePrice(tx, &schedule, target, now); err != nil {
+			return "", err
+		}
+		return "changed", tx.Model(&schedule).Updates(map[string]interface{}{
+			"status":     models.ScheduleCompleted,
+			"applied_at": now,
+		}).Error
+
+	case schedule.Kind == models.PriceSale && schedule.EndsAt != nil && !schedule.EndsAt.After(now) &&
+		(schedule.Status == models.ScheduleScheduled || schedule.Status == models.ScheduleActive):
+		// A sale that ended before the job ever started it is completed without running
+		if schedule.Status == models.ScheduleActive {
+			if err := target.Updates(map[string]interface{}{"sale_price": nil, "sale_ends_at": nil}).Error; err != nil {
+				return "", err
+			}
+		}
+		return "ended", tx.Model(&schedule).Updates(map[string]interface{}{
+			"status":   models.ScheduleCompleted,
+			"ended_at": now,
+		}).Error
+
+	case schedule.Kind == models.PriceSale && schedule.Status == models.ScheduleScheduled && !schedule.StartsAt.After(now):
+		if err := target.Updates(map[string]interface{}{
+			"sale_price":   schedule.Price,
+			"sale_ends_at": schedule.EndsAt,
+		}).Error; err != nil {
+			return "", err
+		}
+		return "started", tx.Model(&schedule).Updates(map[string]interface{}{
+			"status":     models.ScheduleActive,
+			"applied_at": now,
+		}).Error
+	}
+
+	// Already handled by another run
+	return "", nil
+}
+
+// EndSale clears the sale price of an active sale's product or variant, e.g.
+// when the sale is cancelled early
+func EndSale(tx *gorm.DB, schedule *models.PriceSchedule) error {
+	if schedule.Kind != models.PriceSale || schedule.Status != models.ScheduleActive {
+		return nil
+	}
+	target, err := lockTarget(tx, schedule)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return target.Updates(map[string]interface{}{"sale_price": nil, "sale_ends_at": nil}).Error
+}
+
+// targetPrices holds the current prices of a schedule's product or variant
+type targetPrices struct {
+	Price       float64
+	Price3Items *float64
+	Price5Items *float64
+}
+
+// lockTarget locks the schedule's product or variant and returns a query
+// scoped to it
+func lockTarget(tx *gorm.DB, schedule *models.PriceSchedule) (*gorm.DB, error) {
+	var query *gorm.DB
+	if schedule.VariantID != nil {
+		query = tx.Model(&models.ProductVariant{}).Where("id = ? AND product_id = ?", *schedule.VariantID, schedule.ProductID)
+	} else {
+		query = tx.Model(&models.Product{}).Where("id = ?", schedule.ProductID)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var count int64
+	if err := query.Clauses(clause.Locking{Strength: "UPDATE"}).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return query, nil
+}
+
+// changePrice sets the scheduled base and tier prices and records them in the price history
+func changePrice(tx *gorm.DB, schedule *models.PriceSchedule, target *gorm.DB, now time.Time) error {
+	var current targetPrices
+	if err := target.Select("price, price_3_items, price_5_items").Take(&current).Error; err != nil {
+		return err
+	}
+
+	if err := target.Updates(map[string]interface{}{
+		"price":         schedule.Price,
+		"price_3_items": schedule.Price3Items,
+		"price_5_items": schedule.Price5Items,
+	}).Error; err != nil {
+		return err
+	}
+
+	scheduleID := schedule.ID
+	if err := models.RecordPriceChange(tx, &models.ProductPriceHistory{
+		ProductID:      schedule.ProductID,
+		VariantID:      schedule.VariantID,
+		OldPrice:       &current.Price,
+		OldPrice3Items: current.Price3Items,
+		OldPrice5Items: current.Price5Items,
+		NewPrice:       schedule.Price,
+		NewPrice3Items: schedule.Price3Items,
+		NewPrice5Items: schedule.Price5Items,
+		Source:         models.PriceSourceSchedule,
+		ReferenceID:    &scheduleID,
+		Note:           schedule.Note,
+		ChangedBy:      schedule.CreatedBy,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Product{}).Where("id = ?", schedule.ProductID).Update("last_price_update", now).Error; err != nil {
+		return err
+	}
+	if schedule.VariantID != nil {
+		return models.SyncProductFromVariants(tx, schedule.ProductID)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package search
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Vocabulary is the set of words that appear in the catalog, used to correct
+// typos in queries that match nothing. It is reloaded when older than TTL.
+type Vocabulary struct {
+	Load func() ([]string, error)
+	TTL  time.Duration
+
+	mu       sync.Mutex
+	words    map[string]bool
+	loadedAt time.Time
+}
+
+// Words returns the current vocabulary, reloading it when stale
+func (v *Vocabulary) Words() map[string]bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.words != nil && time.Since(v.loadedAt) < v.TTL {
+		return v.words
+	}
+
+	texts, err := v.Load()
+	if err != nil {
+		// Keep serving the previous vocabulary if the reload fails
+		if v.words == nil {
+			return map[string]bool{}
+		}
+		return v.words
+	}
+
+	words := make(map[string]bool)
+	for _, text := range texts {
+		for _, word := range strings.Fields(Normalize(text)) {
+			words[word] = true
+		}
+	}
+	v.words = words
+	v.loadedAt = time.Now()
+	return words
+}
+
+// Correct replaces every query word that is not in the vocabulary with the
+// closest vocabulary word. It reports false when nothing was changed.
+func (v *Vocabulary) Correct(q Query) (Query, bool) {
+	words := v.Words()
+	if len(words) == 0 {
+		return q, false
+	}
+
+	changed := false
+	corrected := make([]string, 0, len(q.Terms))
+	for _, term := range q.Terms {
+		if words[term.Word] {
+			corrected = append(corrected, term.Word)
+			continue
+		}
+		if best, ok := closestWord(term.Word, words); ok {
+			corrected = append(corrected, best)
+			changed = true
+			continue
+		}
+		corrected = append(corrected, term.Word)
+	}
+
+	if !changed {
+		return q, false
+	}
+	return Parse(strings.Join(corrected, " ")), true
+}
+
+// maxDistance allows one edit for short words and two for longer ones
+func maxDistance(word string) int {
+	n := utf8.RuneCountInString(word)
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func closestWord(word string, words map[string]bool) (string, bool) {
+	limit := maxDistance(word)
+	if limit == 0 {
+		return "", false
+	}
+
+	best, bestDistance := "", limit+1
+	for candidate := range words {
+		// Cheap length check before computing the distance
+		diff := utf8.RuneCountInString(candidate) - utf8.RuneCountInString(word)
+		if diff > limit || -diff > limit {
+			continue
+		}
+		d := levenshtein(word, candidate)
+		if d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best, bestDistance <= limit
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,155 @@
+package search
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gsm-motor/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// minTokenSize mirrors InnoDB's default innodb_ft_min_token_size; shorter words
+// are not in the FULLTEXT index and are matched with LIKE instead
+const minTokenSize = 3
+
+const (
+	nameIndex   = "products_name_fulltext"
+	searchIndex = "products_search_fulltext"
+)
+
+// MySQLEngine searches products with InnoDB FULLTEXT indexes on name and
+// description, plus variant SKUs and category names
+type MySQLEngine struct {
+	Vocabulary *Vocabulary
+}
+
+// NewMySQLEngine returns an engine whose typo vocabulary is built from db
+func NewMySQLEngine(db *gorm.DB) *MySQLEngine {
+	return &MySQLEngine{
+		Vocabulary: &Vocabulary{
+			TTL: 10 * time.Minute,
+			Load: func() ([]string, error) {
+				var texts []string
+				if err := db.Model(&models.Product{}).Pluck("name", &texts).Error; err != nil {
+					return nil, err
+				}
+				var categories []string
+				if err := db.Model(&models.Category{}).Pluck("name", &categories).Error; err != nil {
+					return nil, err
+				}
+				return append(texts, categories...), nil
+			},
+		},
+	}
+}
+
+// Migrate creates the FULLTEXT indexes; AutoMigrate cannot declare an index
+// over a column that already has a regular one
+func (e *MySQLEngine) Migrate(db *gorm.DB) error {
+	indexes := map[string]string{
+		nameIndex:   "ALTER TABLE products ADD FULLTEXT INDEX " + nameIndex + " (name)",
+		searchIndex: "ALTER TABLE products ADD FULLTEXT INDEX " + searchIndex + " (name, description)",
+	}
+	for name, ddl := range indexes {
+		if db.Migrator().HasIndex(&models.Product{}, name) {
+			continue
+		}
+		if err := db.Exec(ddl).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filter implements Engine
+func (e *MySQLEngine) Filter(db *gorm.DB, q Query) *gorm.DB {
+	if q.IsEmpty() {
+		return db
+	}
+
+	var conds []string
+	var args []interface{}
+
+	// Every word (or one of its synonyms) must appear in the name or description
+	if against := booleanQuery(q, true); against != "" {
+		conds = append(conds, "MATCH(products.name, products.description) AGAINST (? IN BOOLEAN MODE)")
+		args = append(args, against)
+	}
+	for _, term := range q.Terms {
+		if isIndexed(term.Word) {
+			continue
+		}
+		var likes []string
+		for _, word := range term.Alternatives() {
+			likes = append(likes, "products.name LIKE ?")
+			args = append(args, "%"+word+"%")
+		}
+		conds = append(conds, "("+strings.Join(likes, " OR ")+")")
+	}
+	textMatch := strings.Join(conds, " AND ")
+
+	// Also match product and variant SKUs ignoring hyphens, and category names
+	compact := strings.ReplaceAll(q.Text, " ", "")
+	where := "(" + textMatch + ")" +
+		" OR REPLACE(LOWER(products.sku), '-', '') LIKE ?" +
+		" OR EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = products.id AND pv.deleted_at IS NULL AND REPLACE(LOWER(pv.sku), '-', '') LIKE ?)" +
+		" OR products.category_id IN (SELECT id FROM categories WHERE LOWER(name) LIKE ?)"
+	args = append(args, "%"+compact+"%", "%"+compact+"%", "%"+q.Text+"%")
+
+	return db.Where(where, args...)
+}
+
+// OrderByRelevance implements Engine; matches in the name weigh double
+func (e *MySQLEngine) OrderByRelevance(db *gorm.DB, q Query) *gorm.DB {
+	against := booleanQuery(q, false)
+	if against == "" {
+		return db.Order("products.created_at DESC")
+	}
+	return db.Clauses(clause.OrderBy{
+		Expression: clause.Expr{
+			SQL:                "MATCH(products.name) AGAINST (? IN BOOLEAN MODE) * 2 + MATCH(products.name, products.description) AGAINST (? IN BOOLEAN MODE) DESC, products.created_at DESC",
+			Vars:               []interface{}{against, against},
+			WithoutParentheses: true,
+		},
+	})
+}
+
+// Suggest implements Engine
+func (e *MySQLEngine) Suggest(q Query) (Query, bool) {
+	if e.Vocabulary == nil {
+		return q, false
+	}
+	return e.Vocabulary.Correct(q)
+}
+
+// booleanQuery builds a FULLTEXT boolean-mode expression such as
+// "+(ban* tire* tyre*) +(depan*)". Words are prefix-matched so partial input
+// works for autocomplete. With required=false the groups are optional, which
+// is what ranking needs.
+func booleanQuery(q Query, required bool) string {
+	var groups []string
+	for _, term := range q.Terms {
+		if !isIndexed(term.Word) {
+			continue
+		}
+		var words []string
+		for _, word := range term.Alternatives() {
+			if isIndexed(word) {
+				words = append(words, word+"*")
+			}
+		}
+		group := "(" + strings.Join(words, " ") + ")"
+		if required {
+			group = "+" + group
+		}
+		groups = append(groups, group)
+	}
+	return strings.Join(groups, " ")
+}
+
+func isIndexed(word string) bool {
+	return utf8.RuneCountInString(word) >= minTokenSize
+}
@@ -0,0 +1,147 @@
+// Package search implements product search: query normalization, synonym
+// expansion, a relevance-ranked backend and a typo-tolerant fallback.
+package search
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Engine is a product search backend. The db passed in is a query on the
+// products table that may already carry other filters.
+type Engine interface {
+	// Filter restricts db to products matching q
+	Filter(db *gorm.DB, q Query) *gorm.DB
+	// OrderByRelevance orders db by how well each product matches q, best first
+	OrderByRelevance(db *gorm.DB, q Query) *gorm.DB
+	// Suggest returns a corrected query when q likely contains a typo
+	Suggest(q Query) (Query, bool)
+}
+
+// Term is one word of a query together with its synonyms
+type Term struct {
+	Word     string
+	Synonyms []string
+}
+
+// Alternatives returns the word followed by its synonyms
+func (t Term) Alternatives() []string {
+	return append([]string{t.Word}, t.Synonyms...)
+}
+
+// Query is a normalized search query
+type Query struct {
+	Text  string
+	Terms []Term
+}
+
+// IsEmpty reports whether the query has nothing to search for
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0
+}
+
+// Normalize lowercases text and turns punctuation such as hyphens and slashes
+// into spaces, so "Kampas-Rem" and "kampas rem" are the same query
+func Normalize(text string) string {
+	mapped := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(mapped), " ")
+}
+
+// Parse normalizes text and expands each word with its synonyms
+func Parse(text string) Query {
+	normalized := Normalize(text)
+	q := Query{Text: normalized}
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(normalized) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		q.Terms = append(q.Terms, Term{Word: word, Synonyms: Synonyms(word)})
+	}
+	return q
+}
+
+// Result describes how a search was resolved
+type Result struct {
+	Query Query
+	Total int64
+	// Corrected is the typo-corrected text when the original query had no matches
+	Corrected string
+}
+
+var (
+	mu     sync.RWMutex
+	engine Engine
+)
+
+// Setup installs the engine used by the product handlers
+func Setup(e Engine) {
+	mu.Lock()
+	defer mu.Unlock()
+	engine = e
+}
+
+// Default returns the installed engine
+func Default() Engine {
+	mu.RLock()
+	defer mu.RUnlock()
+	return engine
+}
+
+// Match filters db with the search text and counts the matches. When nothing
+// matches and the engine can correct a typo, it retries once with the corrected
+// query. The returned db still needs ordering and pagination.
+func Match(db *gorm.DB, text string) (*gorm.DB, Result) {
+	e := Default()
+	q := Parse(text)
+	result := Result{Query: q}
+	if e == nil || q.IsEmpty() {
+		db.Count(&result.Total)
+		return db, result
+	}
+
+	base := db.Session(&gorm.Session{})
+	matched := e.Filter(base.Session(&gorm.Session{}), q)
+	matched.Count(&result.Total)
+
+	if result.Total == 0 {
+		if corrected, ok := e.Suggest(q); ok {
+			retry := e.Filter(base.Session(&gorm.Session{}), corrected)
+			var total int64
+			retry.Count(&total)
+			if total > 0 {
+				return retry, Result{Query: corrected, Total: total, Corrected: corrected.Text}
+			}
+		}
+	}
+
+	return matched, result
+}
+
+// OrderByRelevance orders db by the installed engine's relevance for q
+func OrderByRelevance(db *gorm.DB, q Query) *gorm.DB {
+	e := Default()
+	if e == nil || q.IsEmpty() {
+		return db
+	}
+	return e.OrderByRelevance(db, q)
+}
+
+// Filter applies the installed engine's filter for an already parsed query,
+// e.g. the Result.Query of an earlier Match when computing facets
+func Filter(db *gorm.DB, q Query) *gorm.DB {
+	e := Default()
+	if e == nil || q.IsEmpty() {
+		return db
+	}
+	return e.Filter(db, q)
+}
@@ -0,0 +1,43 @@
+package search
+
+// synonymGroups lists words customers use interchangeably. Every word in a
+// group matches the others; entries must already be normalized (lowercase).
+var synonymGroups = [][]string{
+	{"ban", "tire", "tyre"},
+	{"oli", "oil", "pelumas"},
+	{"aki", "accu", "battery", "baterai"},
+	{"kampas", "pad", "pads"},
+	{"rem", "brake"},
+	{"rantai", "chain"},
+	{"gir", "gear", "sproket", "sprocket"},
+	{"lampu", "lamp", "light"},
+	{"spion", "mirror"},
+	{"knalpot", "exhaust", "muffler"},
+	{"busi", "sparkplug"},
+	{"saringan", "filter"},
+	{"kabel", "cable"},
+	{"velg", "rim", "pelek"},
+	{"shock", "shockbreaker", "suspensi"},
+	{"jok", "seat"},
+}
+
+var synonymIndex = buildSynonymIndex(synonymGroups)
+
+func buildSynonymIndex(groups [][]string) map[string][]string {
+	index := make(map[string][]string)
+	for _, group := range groups {
+		for _, word := range group {
+			for _, other := range group {
+				if other != word {
+					index[word] = append(index[word], other)
+				}
+			}
+		}
+	}
+	return index
+}
+
+// Synonyms returns the other words of the word's synonym group
+func Synonyms(word string) []string {
+	return synonymIndex[word]
+}
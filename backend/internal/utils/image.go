@@ -51,6 +51,12 @@ func (ip *ImageProcessor) ProcessAndSave(file *multipart.FileHeader, subDir stri
 	}
 	defer src.Close()
 
+	return ip.ProcessReaderAndSave(src, subDir, addWatermark)
+}
+
+// ProcessReaderAndSave is ProcessAndSave for images that do not come from a form
+// upload, such as downloaded or unzipped files
+func (ip *ImageProcessor) ProcessReaderAndSave(src io.Reader, subDir string, addWatermark bool) (string, error) {
 	// Decode image
 	img, err := imaging.Decode(src)
 	if err != nil {
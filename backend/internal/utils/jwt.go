@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
+	"slices"
 	"time"
 
 	"gsm-motor/internal/config"
@@ -8,67 +11,127 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenType tells access and refresh tokens apart, so one cannot be used as the other
+type TokenType string
+
+const (
+	TokenAccess  TokenType = "access"
+	TokenRefresh TokenType = "refresh"
+
+	// Issuers of tokens signed before tokens carried typ and aud. They still
+	// identify the token type until those tokens have expired.
+	legacyAccessIssuer  = "gsm-motor"
+	legacyRefreshIssuer = "gsm-motor-refresh"
+)
+
+// ErrWrongTokenType is returned when a token of the other type is presented
+var ErrWrongTokenType = errors.New("token has the wrong type")
+
 // Claims represents JWT claims
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID uint      `json:"user_id"`
+	Email  string    `json:"email"`
+	Role   string    `json:"role"`
+	Type   TokenType `json:"typ,omitempty"`
+	// SessionID is the auth session family the token belongs to
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateAccessToken generates a new access token
-func GenerateAccessToken(userID uint, email, role string) (string, error) {
-	cfg := config.AppConfig
-
-	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(cfg.JWTExpireMinutes) * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "gsm-motor",
-		},
-	}
+// GenerateAccessToken generates a new access token for a session
+func GenerateAccessToken(userID uint, email, role, sessionID string) (string, error) {
+	expiresAt := time.Now().Add(time.Duration(config.AppConfig.JWTExpireMinutes) * time.Minute)
+	return signToken(TokenAccess, userID, email, role, sessionID, "", expiresAt)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JWTSecret))
+// GenerateRefreshToken generates a refresh token with longer expiry. jti
+// identifies the token in the auth session store.
+func GenerateRefreshToken(userID uint, email, role, sessionID, jti string, expiresAt time.Time) (string, error) {
+	return signToken(TokenRefresh, userID, email, role, sessionID, jti, expiresAt)
 }
 
-// GenerateRefreshToken generates a refresh token with longer expiry
-func GenerateRefreshToken(userID uint, email, role string) (string, error) {
+// signToken signs a token with the active key. The key ID goes into the kid
+// header so the token can still be verified after the active key changes.
+func signToken(typ TokenType, userID uint, email, role, sessionID, jti string, expiresAt time.Time) (string, error) {
 	cfg := config.AppConfig
 
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		Type:      typ,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(cfg.RefreshExpireDays) * 24 * time.Hour)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "gsm-motor-refresh",
+			Issuer:    cfg.JWTIssuer,
+			Audience:  jwt.ClaimStrings{cfg.JWTAudience},
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JWTSecret))
+	key := cfg.JWTSecret
+	if cfg.JWTActiveKeyID != "" {
+		token.Header["kid"] = cfg.JWTActiveKeyID
+		key = cfg.JWTKeys[cfg.JWTActiveKeyID]
+	}
+	return token.SignedString([]byte(key))
 }
 
-// ParseToken parses and validates a JWT token
-func ParseToken(tokenString string) (*Claims, error) {
+// ParseToken parses and validates a JWT token of the expected type. Only HS256
+// is accepted. Tokens without a kid header are verified with JWTSecret.
+func ParseToken(tokenString string, expected TokenType) (*Claims, error) {
+	cfg := config.AppConfig
 	claims := &Claims{}
-	
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.AppConfig.JWTSecret), nil
-	})
 
+	token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
 		return nil, err
 	}
-
 	if !token.Valid {
 		return nil, jwt.ErrTokenNotValidYet
 	}
 
+	if claims.Type == "" {
+		// Signed before typed tokens; the issuer tells the type
+		legacyType := map[string]TokenType{legacyAccessIssuer: TokenAccess, legacyRefreshIssuer: TokenRefresh}
+		if legacyType[claims.Issuer] != expected {
+			return nil, ErrWrongTokenType
+		}
+		return claims, nil
+	}
+
+	if claims.Type != expected {
+		return nil, ErrWrongTokenType
+	}
+	if claims.Issuer != cfg.JWTIssuer {
+		return nil, jwt.ErrTokenInvalidIssuer
+	}
+	if !slices.Contains(claims.Audience, cfg.JWTAudience) {
+		return nil, jwt.ErrTokenInvalidAudience
+	}
 	return claims, nil
 }
+
+// verificationKey picks the key a token was signed with by its kid header
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	cfg := config.AppConfig
+	kid, hasKid := token.Header["kid"]
+	if !hasKid {
+		return []byte(cfg.JWTSecret), nil
+	}
+
+	id, ok := kid.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid kid header")
+	}
+	key, ok := cfg.JWTKeys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+	return []byte(key), nil
+}
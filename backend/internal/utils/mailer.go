@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math/big"
 	"net/smtp"
+	"strings"
+	"time"
 
 	"gsm-motor/internal/config"
 )
@@ -67,6 +69,116 @@ Tim GSM Motor
 	return smtp.SendMail(addr, auth, cfg.SMTPFrom, []string{toEmail}, []byte(message))
 }
 
+// SendPasswordResetEmail sends the password reset code and link
+func SendPasswordResetEmail(toEmail, userName, code, link string, validFor time.Duration) error {
+	subject := "Reset Password GSM Motor"
+	body := fmt.Sprintf(`
+Halo %s,
+
+Kami menerima permintaan untuk mereset password akun Anda.
+
+Kode reset password Anda adalah: %s
+
+Atau buka tautan berikut untuk membuat password baru:
+%s
+
+Kode dan tautan ini berlaku selama %d menit dan hanya dapat digunakan sekali.
+Jangan bagikan kode ini kepada siapapun.
+
+Jika Anda tidak meminta reset password, abaikan email ini. Password Anda tidak akan berubah.
+
+Terima kasih,
+Tim GSM Motor
+	`, userName, code, link, int(validFor.Minutes()))
+
+	return sendPlainEmail(toEmail, subject, body)
+}
+
+// SendPasswordChangedEmail tells the user their password was changed
+func SendPasswordChangedEmail(toEmail, userName string) error {
+	cfg := config.AppConfig
+
+	subject := "Password Anda Telah Diubah - GSM Motor"
+	body := fmt.Sprintf(`
+Halo %s,
+
+Password akun GSM Motor Anda baru saja diubah pada %s.
+Sesi login di perangkat lain telah diakhiri.
+
+Jika Anda tidak melakukan perubahan ini, segera reset password Anda
+dan hubungi kami via WhatsApp: %s
+
+Terima kasih,
+Tim GSM Motor
+	`, userName, time.Now().Format("02 Jan 2006 15:04"), cfg.StoreWhatsApp)
+
+	return sendPlainEmail(toEmail, subject, body)
+}
+
+// SendAccountLockedEmail tells the user their account was locked after too many failed logins
+func SendAccountLockedEmail(toEmail, userName string, until time.Time) error {
+	cfg := config.AppConfig
+
+	subject := "Akun Anda Dikunci Sementara - GSM Motor"
+	body := fmt.Sprintf(`
+Halo %s,
+
+Terdapat terlalu banyak percobaan login atau kode verifikasi yang salah pada akun Anda.
+Demi keamanan, akun Anda dikunci sementara hingga %s.
+
+Jika itu bukan Anda, sebaiknya reset password Anda melalui fitur lupa password.
+
+Butuh bantuan? Hubungi kami via WhatsApp: %s
+
+Terima kasih,
+Tim GSM Motor
+	`, userName, until.Format("02 Jan 2006 15:04"), cfg.StoreWhatsApp)
+
+	return sendPlainEmail(toEmail, subject, body)
+}
+
+// SendEmailChangeOTP sends the code that confirms a new email address
+func SendEmailChangeOTP(toEmail, otpCode, userName string) error {
+	subject := "Konfirmasi Email Baru - GSM Motor"
+	body := fmt.Sprintf(`
+Halo %s,
+
+Anda meminta untuk mengganti email akun GSM Motor Anda ke alamat ini.
+
+Kode verifikasi Anda adalah: %s
+
+Kode ini berlaku selama 10 menit.
+Jangan bagikan kode ini kepada siapapun.
+
+Jika Anda tidak meminta perubahan ini, abaikan email ini.
+
+Terima kasih,
+Tim GSM Motor
+	`, userName, otpCode)
+
+	return sendPlainEmail(toEmail, subject, body)
+}
+
+// SendEmailChangedEmail tells the previous address that the account moved to a new email
+func SendEmailChangedEmail(toEmail, newEmail, userName string) error {
+	cfg := config.AppConfig
+
+	subject := "Email Akun Anda Telah Diubah - GSM Motor"
+	body := fmt.Sprintf(`
+Halo %s,
+
+Email akun GSM Motor Anda telah diubah menjadi %s pada %s.
+Email ini tidak lagi dapat digunakan untuk login.
+
+Jika Anda tidak melakukan perubahan ini, segera hubungi kami via WhatsApp: %s
+
+Terima kasih,
+Tim GSM Motor
+	`, userName, newEmail, time.Now().Format("02 Jan 2006 15:04"), cfg.StoreWhatsApp)
+
+	return sendPlainEmail(toEmail, subject, body)
+}
+
 // SendOrderNotificationEmail sends order confirmation email to customer
 func SendOrderNotificationEmail(toEmail, orderNumber, userName string, totalAmount float64) error {
 	cfg := config.AppConfig
@@ -168,7 +280,7 @@ type OrderItemInfo struct {
 }
 
 // SendOrderNotificationToAdmins sends email notification when customer places an order
-func SendOrderNotificationToAdmins(orderNumber, customerName, customerEmail, customerPhone, shippingAddress string, items []OrderItemInfo, totalAmount, shippingCost float64, paymentMethod string) error {
+func SendOrderNotificationToAdmins(orderNumber, customerName, customerEmail, customerPhone, shippingAddress string, items []OrderItemInfo, totalAmount, shippingCost, discountAmount float64, paymentMethod string) error {
 	cfg := config.AppConfig
 
 	if cfg.SMTPUser == "" || cfg.SMTPPassword == "" {
@@ -202,6 +314,7 @@ DETAIL PESANAN:
 ----------------------------------------
 Subtotal Produk: Rp %s
 Ongkos Kirim: Rp %s
+Diskon Voucher: -Rp %s
 ----------------------------------------
 TOTAL PEMBAYARAN: Rp %s
 
@@ -218,7 +331,7 @@ Silakan cek dashboard admin untuk detail lebih lanjut.
 Salam,
 Sistem GSM Motor
 	`, orderNumber, customerName, customerEmail, customerPhone, shippingAddress,
-		itemsList, FormatRupiah(totalAmount-shippingCost), FormatRupiah(shippingCost), FormatRupiah(totalAmount), paymentMethod)
+		itemsList, FormatRupiah(totalAmount-shippingCost+discountAmount), FormatRupiah(shippingCost), FormatRupiah(discountAmount), FormatRupiah(totalAmount), paymentMethod)
 
 	auth := smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPHost)
 	addr := fmt.Sprintf("%s:%s", cfg.SMTPHost, cfg.SMTPPort)
@@ -261,3 +374,240 @@ func FormatRupiah(amount float64) string {
 
 	return string(result)
 }
+
+// sendPlainEmail sends a plain-text email to a single recipient
+func sendPlainEmail(toEmail, subject, body string) error {
+	cfg := config.AppConfig
+
+	if cfg.SMTPUser == "" || cfg.SMTPPassword == "" {
+		return fmt.Errorf("SMTP credentials not configured")
+	}
+
+	message := fmt.Sprintf("To: %s\r\n"+
+		"From: %s\r\n"+
+		"Subject: %s\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n"+
+		"\r\n"+
+		"%s", toEmail, cfg.SMTPFrom, subject, body)
+
+	auth := smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPHost)
+	addr := fmt.Sprintf("%s:%s", cfg.SMTPHost, cfg.SMTPPort)
+
+	return smtp.SendMail(addr, auth, cfg.SMTPFrom, []string{toEmail}, []byte(message))
+}
+
+// sendToAdmins sends the same plain-text email to every admin notification address
+func sendToAdmins(subject, body string) error {
+	cfg := config.AppConfig
+
+	if cfg.SMTPUser == "" || cfg.SMTPPassword == "" {
+		return fmt.Errorf("SMTP credentials not configured")
+	}
+
+	for _, email := range adminNotificationEmails {
+		// Send email (continue even if one fails)
+		go sendPlainEmail(email, subject, body)
+	}
+
+	return nil
+}
+
+// SendOrderCancelledEmail notifies the customer that their order was cancelled
+func SendOrderCancelledEmail(toEmail, orderNumber, userName, reason string) error {
+	if reason == "" {
+		reason = "-"
+	}
+
+	subject := fmt.Sprintf("Pesanan %s Dibatalkan - GSM Motor", orderNumber)
+	body := fmt.Sprintf(`
+Halo %s,
+
+Pesanan Anda dengan nomor %s telah dibatalkan.
+
+Alasan: %s
+
+Jika Anda sudah melakukan pembayaran atau merasa ini adalah kesalahan,
+silakan hubungi kami via WhatsApp: %s
+
+Terima kasih,
+Tim GSM Motor
+	`, userName, orderNumber, reason, config.AppConfig.StoreWhatsApp)
+
+	return sendPlainEmail(toEmail, subject, body)
+}
+
+// SendOrderCancelledToAdmins notifies admins that a customer cancelled their order
+func SendOrderCancelledToAdmins(orderNumber, customerName, customerEmail, reason string) error {
+	if reason == "" {
+		reason = "-"
+	}
+
+	subject := fmt.Sprintf("[GSM Motor] Pesanan #%s Dibatalkan oleh Pelanggan", orderNumber)
+	body := fmt.Sprintf(`
+=====================================
+NOTIFIKASI PEMBATALAN PESANAN
+=====================================
+
+Nomor Pesanan: %s
+
+Dibatalkan oleh:
+- Nama: %s
+- Email: %s
+
+Alasan: %s
+
+Stok produk pada pesanan ini telah dikembalikan secara otomatis.
+
+Salam,
+Sistem GSM Motor
+	`, orderNumber, customerName, customerEmail, reason)
+
+	return sendToAdmins(subject, body)
+}
+
+// SendPaymentMismatchToAdmins alerts admins that a gateway payment does not match
+// the charge, so the order has to be checked by hand
+func SendPaymentMismatchToAdmins(orderNumber, provider string, expected, paid float64) error {
+	subject := fmt.Sprintf("[GSM Motor] Pembayaran #%s Tidak Sesuai", orderNumber)
+	body := fmt.Sprintf(`
+=====================================
+PEMBAYARAN TIDAK SESUAI
+=====================================
+
+Nomor Pesanan: %s
+Metode: %s
+
+Tagihan: Rp %s
+Dibayar: Rp %s
+
+Pesanan belum diverifikasi. Silakan periksa pembayaran ini dan
+proses pesanan atau refund secara manual.
+
+Salam,
+Sistem GSM Motor
+	`, orderNumber, provider, FormatRupiah(expected), FormatRupiah(paid))
+
+	return sendToAdmins(subject, body)
+}
+
+// SendPaymentRefundRequiredToAdmins alerts admins that a gateway payment arrived
+// for a cancelled order and has to be refunded
+func SendPaymentRefundRequiredToAdmins(orderNumber, provider string, paid float64) error {
+	subject := fmt.Sprintf("[GSM Motor] Pembayaran #%s Perlu Direfund", orderNumber)
+	body := fmt.Sprintf(`
+=====================================
+PEMBAYARAN UNTUK PESANAN DIBATALKAN
+=====================================
+
+Nomor Pesanan: %s
+Metode: %s
+Dibayar: Rp %s
+
+Pembayaran diterima setelah pesanan dibatalkan. Silakan hubungi
+pelanggan dan lakukan refund secara manual.
+
+Salam,
+Sistem GSM Motor
+	`, orderNumber, provider, FormatRupiah(paid))
+
+	return sendToAdmins(subject, body)
+}
+
+// SendPaymentReminderEmail reminds the customer to pay before the order expires
+func SendPaymentReminderEmail(toEmail, orderNumber, userName string, totalAmount float64, deadline time.Time) error {
+	cfg := config.AppConfig
+
+	subject := fmt.Sprintf("Pengingat Pembayaran Pesanan %s - GSM Motor", orderNumber)
+	body := fmt.Sprintf(`
+Halo %s,
+
+Pesanan Anda dengan nomor %s belum dibayar.
+
+Total Pembayaran: Rp %s
+Batas Waktu: %s
+
+Silakan lakukan pembayaran ke rekening berikut:
+Bank: %s
+Atas Nama: %s
+Nomor Rekening: %s
+
+Lalu upload bukti transfer melalui halaman detail pesanan Anda.
+Pesanan yang belum dibayar sampai batas waktu akan dibatalkan otomatis.
+
+Terima kasih,
+Tim GSM Motor
+	`, userName, orderNumber, FormatRupiah(totalAmount), deadline.Format("02 Jan 2006 15:04"),
+		cfg.BankName, cfg.BankAccount, cfg.BankNumber)
+
+	return sendPlainEmail(toEmail, subject, body)
+}
+
+// LowStockItemInfo is one line of the low-stock digest
+type LowStockItemInfo struct {
+	Name          string
+	SKU           string
+	Stock         int
+	ReorderPoint  int
+	ReorderAmount int
+	Supplier      string
+}
+
+// lowStockRecipients returns the configured low-stock recipients, or the admin notification list
+func lowStockRecipients() []string {
+	var recipients []string
+	for _, email := range strings.Split(config.AppConfig.LowStockAlertEmails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			recipients = append(recipients, email)
+		}
+	}
+	if len(recipients) == 0 {
+		return adminNotificationEmails
+	}
+	return recipients
+}
+
+// SendLowStockDigest emails the list of products that fell below their reorder point
+func SendLowStockDigest(items []LowStockItemInfo) error {
+	cfg := config.AppConfig
+
+	if cfg.SMTPUser == "" || cfg.SMTPPassword == "" {
+		return fmt.Errorf("SMTP credentials not configured")
+	}
+
+	itemsList := ""
+	for i, item := range items {
+		name := item.Name
+		if item.SKU != "" {
+			name += " (" + item.SKU + ")"
+		}
+		supplier := item.Supplier
+		if supplier == "" {
+			supplier = "-"
+		}
+		itemsList += fmt.Sprintf("   %d. %s\n      Stok: %d (batas %d), saran pesan: %d, supplier: %s\n",
+			i+1, name, item.Stock, item.ReorderPoint, item.ReorderAmount, supplier)
+	}
+
+	subject := fmt.Sprintf("[GSM Motor] %d Produk Stok Menipis", len(items))
+	body := fmt.Sprintf(`
+=====================================
+LAPORAN STOK MENIPIS
+=====================================
+
+Produk berikut baru saja berada di bawah batas stok minimum:
+
+%s
+--
+Buat PO dari halaman admin untuk melakukan restock.
+
+Salam,
+Sistem GSM Motor
+	`, itemsList)
+
+	for _, email := range lowStockRecipients() {
+		// Send email (continue even if one fails)
+		go sendPlainEmail(email, subject, body)
+	}
+
+	return nil
+}
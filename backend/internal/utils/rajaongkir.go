@@ -191,3 +191,18 @@ func (c *RajaOngkirClient) CalculateAllCouriers(originSubdistrictID, destSubdist
 func (c *RajaOngkirClient) GetStoreOrigin() string {
 	return config.AppConfig.StoreOriginSubdistrictID
 }
+
+// FindServiceCost looks up the cost of a courier service in a cost calculation result
+func FindServiceCost(costs []CourierCost, courier, service string) (int, bool) {
+	for _, cc := range costs {
+		if !strings.EqualFold(cc.Code, courier) {
+			continue
+		}
+		for _, cost := range cc.Costs {
+			if strings.EqualFold(cost.Service, service) {
+				return cost.Cost, true
+			}
+		}
+	}
+	return 0, false
+}
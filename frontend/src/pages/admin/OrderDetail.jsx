@@ -120,7 +120,8 @@ export default function OrderDetail() {
                         <div className="space-y-2">
                             <div className="flex justify-between text-gray-600"><span>Subtotal</span><span>Rp {formatPrice(order.total_price)}</span></div>
                             <div className="flex justify-between text-gray-600"><span>Ongkir</span><span>Rp {formatPrice(order.shipping_cost || 0)}</span></div>
-                            <div className="flex justify-between font-bold text-lg"><span>Total</span><span className="text-gsm-orange">Rp {formatPrice(order.total_price + (order.shipping_cost || 0))}</span></div>
+                            {order.discount_amount > 0 && <div className="flex justify-between text-green-600"><span>Diskon{order.voucher_code ? ` (${order.voucher_code})` : ''}</span><span>-Rp {formatPrice(order.discount_amount)}</span></div>}
+                            <div className="flex justify-between font-bold text-lg"><span>Total</span><span className="text-gsm-orange">Rp {formatPrice(order.grand_total)}</span></div>
                         </div>
                     </div>
 
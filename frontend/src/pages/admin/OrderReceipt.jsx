@@ -59,7 +59,7 @@ export default function OrderReceipt() {
 
     if (!order) return null;
 
-    const totalAmount = order.total_price + (order.shipping_cost || 0);
+    const totalAmount = order.grand_total;
 
     return (
         <>
@@ -379,6 +379,12 @@ export default function OrderReceipt() {
                                     <span className="summary-label">Ongkir:</span>
                                     <span className="summary-value">Rp {formatPrice(order.shipping_cost || 0)}</span>
                                 </div>
+                                {order.discount_amount > 0 && (
+                                    <div className="summary-row">
+                                        <span className="summary-label">Diskon{order.voucher_code ? ` (${order.voucher_code})` : ''}:</span>
+                                        <span className="summary-value">-Rp {formatPrice(order.discount_amount)}</span>
+                                    </div>
+                                )}
                                 <div className="summary-row total-row">
                                     <span className="summary-label">TOTAL:</span>
                                     <span className="summary-value">Rp {formatPrice(totalAmount)}</span>
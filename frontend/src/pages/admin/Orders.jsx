@@ -85,7 +85,7 @@ export default function Orders() {
                                     <tr key={o.id} className="hover:bg-gray-50">
                                         <td className="px-4 py-3 font-medium">{o.order_number}</td>
                                         <td className="px-4 py-3 text-gray-600">{o.user?.name || '-'}</td>
-                                        <td className="px-4 py-3 font-medium">Rp {formatPrice(o.total_price + (o.shipping_cost || 0))}</td>
+                                        <td className="px-4 py-3 font-medium">Rp {formatPrice(o.grand_total)}</td>
                                         <td className="px-4 py-3">{getStatusBadge(o.status)}</td>
                                         <td className="px-4 py-3">{getPaymentBadge(o.payment_status)}</td>
                                         <td className="px-4 py-3 text-gray-500 text-sm">{formatDate(o.created_at)}</td>
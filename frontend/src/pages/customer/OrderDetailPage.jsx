@@ -172,7 +172,7 @@ export default function OrderDetailPage() {
                                 <div className="flex justify-between">
                                     <span>Total Transfer:</span>
                                     <span className="font-bold text-gsm-orange text-lg">
-                                        Rp {formatPrice(order.total_price + (order.shipping_cost || 0))}
+                                        Rp {formatPrice(order.grand_total)}
                                     </span>
                                 </div>
                             </div>
@@ -272,11 +272,17 @@ export default function OrderDetailPage() {
                             <span>Ongkos Kirim</span>
                             <span>Rp {formatPrice(order.shipping_cost || 0)}</span>
                         </div>
+                        {order.discount_amount > 0 && (
+                            <div className="flex justify-between text-green-600">
+                                <span>Diskon{order.voucher_code ? ` (${order.voucher_code})` : ''}</span>
+                                <span>-Rp {formatPrice(order.discount_amount)}</span>
+                            </div>
+                        )}
                         <hr />
                         <div className="flex justify-between font-semibold text-lg">
                             <span>Total</span>
                             <span className="text-gsm-orange">
-                                Rp {formatPrice(order.total_price + (order.shipping_cost || 0))}
+                                Rp {formatPrice(order.grand_total)}
                             </span>
                         </div>
                     </div>
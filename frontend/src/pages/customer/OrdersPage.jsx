@@ -147,7 +147,7 @@ export default function OrdersPage() {
                                         {order.items?.length || 0} produk
                                     </span>
                                     <span className="font-bold text-primary" style={{ fontSize: '1.125rem' }}>
-                                        Rp {formatPrice(order.total_price + (order.shipping_cost || 0))}
+                                        Rp {formatPrice(order.grand_total)}
                                     </span>
                                 </div>
                             </Link>
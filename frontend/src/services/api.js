@@ -22,6 +22,25 @@ api.interceptors.request.use(
     (error) => Promise.reject(error)
 );
 
+// Refresh tokens are single-use, so concurrent 401s share one refresh request
+let refreshPromise = null;
+
+const refreshTokens = (refreshToken) => {
+    if (!refreshPromise) {
+        refreshPromise = axios.post(`${API_URL}/auth/refresh`, {
+            refresh_token: refreshToken,
+        }, { withCredentials: true }).then((response) => {
+            const { access_token, refresh_token } = response.data;
+            localStorage.setItem('access_token', access_token);
+            localStorage.setItem('refresh_token', refresh_token);
+            return access_token;
+        }).finally(() => {
+            refreshPromise = null;
+        });
+    }
+    return refreshPromise;
+};
+
 // Response interceptor for handling token refresh
 api.interceptors.response.use(
     (response) => response,
@@ -34,13 +53,7 @@ api.interceptors.response.use(
             try {
                 const refreshToken = localStorage.getItem('refresh_token');
                 if (refreshToken) {
-                    const response = await axios.post(`${API_URL}/auth/refresh`, {
-                        refresh_token: refreshToken,
-                    }, { withCredentials: true });
-
-                    const { access_token, refresh_token } = response.data;
-                    localStorage.setItem('access_token', access_token);
-                    localStorage.setItem('refresh_token', refresh_token);
+                    const access_token = await refreshTokens(refreshToken);
 
                     originalRequest.headers.Authorization = `Bearer ${access_token}`;
                     return api(originalRequest);
@@ -60,9 +73,12 @@ api.interceptors.response.use(
 export const authAPI = {
     register: (data) => api.post('/auth/register', data),
     login: (data) => api.post('/auth/login', data),
-    logout: () => api.post('/auth/logout'),
+    logout: () => api.post('/auth/logout', { refresh_token: localStorage.getItem('refresh_token') }),
+    logoutAll: () => api.post('/auth/logout-all'),
     verifyOTP: (data) => api.post('/auth/verify-otp', data),
     resendOTP: (data) => api.post('/auth/resend-otp', data),
+    forgotPassword: (data) => api.post('/auth/forgot-password', data),
+    resetPassword: (data) => api.post('/auth/reset-password', data),
     getMe: () => api.get('/auth/me'),
     refresh: () => api.post('/auth/refresh'),
 };
@@ -119,6 +135,12 @@ export const bannersAPI = {
 export const profileAPI = {
     update: (data) => api.patch('/profile', data),
     updateAddress: (data) => api.patch('/profile/address', data),
+    sendSecurityCode: () => api.post('/profile/security-code'),
+    changePassword: (data) => api.put('/profile/password', data),
+    requestEmailChange: (data) => api.post('/profile/email', data),
+    verifyEmailChange: (data) => api.post('/profile/email/verify', data),
+    sessions: () => api.get('/profile/sessions'),
+    revokeSession: (id) => api.delete(`/profile/sessions/${id}`),
 };
 
 // Admin API